@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+// alarmSeverityRequest is a severity mapping. Vendor and code take the
+// wildcard *, severity is the trap severity from warning (3) to critical (6),
+// indeterminate being 2.
+type alarmSeverityRequest struct {
+	Vendor   string `json:"vendor" validate:"required,oneof=* huawei kstar growatt solarman"`
+	Code     string `json:"code" validate:"required,max=255"`
+	Level    *int   `json:"level" validate:"omitempty,min=0"`
+	Severity string `json:"severity" validate:"required,oneof=2 3 4 5 6"`
+}
+
+func (r alarmSeverityRequest) toModel() *model.AlarmSeverityMapping {
+	return &model.AlarmSeverityMapping{
+		Vendor:   r.Vendor,
+		Code:     strings.TrimSpace(r.Code),
+		Level:    r.Level,
+		Severity: r.Severity,
+	}
+}
+
+func (s *Server) registerAlarmSeverityRoutes() {
+	s.mux.HandleFunc("GET /api/v1/alarm-severities", s.listAlarmSeverities)
+	s.mux.HandleFunc("GET /api/v1/alarm-severities/{id}", s.getAlarmSeverity)
+	s.mux.HandleFunc("POST /api/v1/alarm-severities", s.createAlarmSeverity)
+	s.mux.HandleFunc("PUT /api/v1/alarm-severities/{id}", s.updateAlarmSeverity)
+	s.mux.HandleFunc("DELETE /api/v1/alarm-severities/{id}", s.deleteAlarmSeverity)
+}
+
+func (s *Server) listAlarmSeverities(w http.ResponseWriter, r *http.Request) {
+	data, err := repo.NewAlarmSeverityMappingRepo(s.db).FindAll()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listResponse{Data: data, Total: int64(len(data))})
+}
+
+func (s *Server) getAlarmSeverity(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := repo.NewAlarmSeverityMappingRepo(s.db).FindByID(id)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) createAlarmSeverity(w http.ResponseWriter, r *http.Request) {
+	var req alarmSeverityRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	data := req.toModel()
+	if err := repo.NewAlarmSeverityMappingRepo(s.db).Create(data); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, data)
+}
+
+func (s *Server) updateAlarmSeverity(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req alarmSeverityRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	severityRepo := repo.NewAlarmSeverityMappingRepo(s.db)
+	if _, err := severityRepo.FindByID(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	if err := severityRepo.Update(id, req.toModel()); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	data, err := severityRepo.FindByID(id)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) deleteAlarmSeverity(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	severityRepo := repo.NewAlarmSeverityMappingRepo(s.db)
+	if _, err := severityRepo.FindByID(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	if err := severityRepo.Delete(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,268 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+const encryptedSecretError = "secret fields must not start with " + secret.Prefix
+
+// credentialStore is the CRUD surface shared by every vendor credential repo.
+type credentialStore[T any] interface {
+	FindAll() ([]T, error)
+	FindByID(id int64) (*T, error)
+	Create(credential *T) error
+	Update(id int64, credential *T) error
+	Delete(id int64) error
+}
+
+// secretHolder is implemented by credential models with secret columns.
+type secretHolder interface {
+	SecretFields() []*string
+}
+
+// credentialRequest is the validated request body of a vendor credential.
+type credentialRequest[T any] interface {
+	toModel() *T
+}
+
+type growattCredentialRequest struct {
+	Username string `json:"username" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=255"`
+	Token    string `json:"token" validate:"max=255"`
+	Owner    string `json:"owner" validate:"required,max=255"`
+}
+
+func (r growattCredentialRequest) toModel() *model.GrowattCredential {
+	return &model.GrowattCredential{
+		Username: r.Username,
+		Password: r.Password,
+		Token:    r.Token,
+		Owner:    r.Owner,
+	}
+}
+
+type huaweiCredentialRequest struct {
+	Username string `json:"username" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=255"`
+	Owner    string `json:"owner" validate:"required,max=255"`
+	Version  int    `json:"version" validate:"required,oneof=1 2"`
+}
+
+func (r huaweiCredentialRequest) toModel() *model.HuaweiCredential {
+	return &model.HuaweiCredential{
+		Username: r.Username,
+		Password: r.Password,
+		Owner:    r.Owner,
+		Version:  r.Version,
+	}
+}
+
+type kstarCredentialRequest struct {
+	Username string `json:"username" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=255"`
+	Owner    string `json:"owner" validate:"required,max=255"`
+}
+
+func (r kstarCredentialRequest) toModel() *model.KstarCredential {
+	return &model.KstarCredential{
+		Username: r.Username,
+		Password: r.Password,
+		Owner:    r.Owner,
+	}
+}
+
+type solarmanCredentialRequest struct {
+	Username  string `json:"username" validate:"required,max=255"`
+	Password  string `json:"password" validate:"required,max=255"`
+	AppSecret string `json:"app_secret" validate:"required,max=255"`
+	AppID     string `json:"app_id" validate:"required,max=255"`
+	Owner     string `json:"owner" validate:"required,max=255"`
+}
+
+func (r solarmanCredentialRequest) toModel() *model.SolarmanCredential {
+	return &model.SolarmanCredential{
+		Username:  r.Username,
+		Password:  r.Password,
+		AppSecret: r.AppSecret,
+		AppID:     r.AppID,
+		Owner:     r.Owner,
+	}
+}
+
+func (s *Server) registerCredentialRoutes() {
+	registerCredentialRoutes[model.GrowattCredential, growattCredentialRequest](s, model.VendorTypeGrowatt, repo.NewGrowattCredentialRepo(s.db, s.cipher))
+	registerCredentialRoutes[model.HuaweiCredential, huaweiCredentialRequest](s, model.VendorTypeHuawei, repo.NewHuaweiCredentialRepo(s.db, s.cipher))
+	registerCredentialRoutes[model.KstarCredential, kstarCredentialRequest](s, model.VendorTypeKstar, repo.NewKStarCredentialRepo(s.db, s.cipher))
+	registerCredentialRoutes[model.SolarmanCredential, solarmanCredentialRequest](s, model.VendorTypeSolarman, repo.NewSolarmanCredentialRepo(s.db, s.cipher))
+}
+
+// registerCredentialRoutes serves /api/v1/credentials/<vendor> for one vendor.
+func registerCredentialRoutes[T any, R credentialRequest[T]](s *Server, vendor string, store credentialStore[T]) {
+	base := "/api/v1/credentials/" + vendor
+
+	s.mux.HandleFunc("GET "+base, func(w http.ResponseWriter, r *http.Request) {
+		credentials, err := store.FindAll()
+		if err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, listResponse{Data: credentials, Total: int64(len(credentials))})
+	})
+
+	s.mux.HandleFunc("GET "+base+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+
+		credential, err := store.FindByID(id)
+		if err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, credential)
+	})
+
+	s.mux.HandleFunc("POST "+base, func(w http.ResponseWriter, r *http.Request) {
+		var req R
+		if !s.decode(w, r, &req) {
+			return
+		}
+
+		credential := req.toModel()
+		if hasRedactedSecret(credential) {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "secret fields must not be the redaction placeholder"})
+			return
+		}
+
+		if hasEncryptedSecret(credential) {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: encryptedSecretError})
+			return
+		}
+
+		if err := store.Create(credential); err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, credential)
+	})
+
+	s.mux.HandleFunc("PUT "+base+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+
+		var req R
+		if !s.decode(w, r, &req) {
+			return
+		}
+
+		stored, err := store.FindByID(id)
+		if err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		// The update replaces every field. Secrets are returned redacted, so
+		// echoing them back keeps the stored value while an empty optional
+		// secret, like the Growatt token, clears it.
+		credential := req.toModel()
+		if hasEncryptedSecret(credential) {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: encryptedSecretError})
+			return
+		}
+		keepRedactedSecrets(credential, stored)
+		if err := store.Update(id, credential); err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		updated, err := store.FindByID(id)
+		if err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, updated)
+	})
+
+	s.mux.HandleFunc("DELETE "+base+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+
+		if _, err := store.FindByID(id); err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		if err := store.Delete(id); err != nil {
+			s.writeRepoError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func hasRedactedSecret(credential any) bool {
+	holder, ok := credential.(secretHolder)
+	if !ok {
+		return false
+	}
+
+	for _, field := range holder.SecretFields() {
+		if *field == model.RedactedSecret {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasEncryptedSecret reports a secret field sent with the prefix of encrypted
+// values. The repo would store it as is, sealed with an unknown key.
+func hasEncryptedSecret(credential any) bool {
+	holder, ok := credential.(secretHolder)
+	if !ok {
+		return false
+	}
+
+	for _, field := range holder.SecretFields() {
+		if secret.IsEncrypted(*field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepRedactedSecrets replaces the secret fields of credential left as the
+// redaction placeholder with those of stored.
+func keepRedactedSecrets(credential any, stored any) {
+	holder, ok := credential.(secretHolder)
+	if !ok {
+		return
+	}
+
+	storedHolder, ok := stored.(secretHolder)
+	if !ok {
+		return
+	}
+
+	storedFields := storedHolder.SecretFields()
+	for i, field := range holder.SecretFields() {
+		if *field == model.RedactedSecret {
+			*field = *storedFields[i]
+		}
+	}
+}
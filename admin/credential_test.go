@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/repo"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newCredentialServer(t *testing.T) (*httptest.Server, *gorm.DB, *secret.Cipher) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	cipher, err := secret.NewCipherFromKey(strings.Repeat("11", 32))
+	if err != nil {
+		t.Fatalf("NewCipherFromKey() error = %v", err)
+	}
+
+	server, err := NewServer(db, cipher, "token")
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+
+	return httpServer, db, cipher
+}
+
+func send(t *testing.T, server *httptest.Server, method, path, body string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestCredentialPutReplacesSecrets(t *testing.T) {
+	server, db, cipher := newCredentialServer(t)
+	credentials := repo.NewGrowattCredentialRepo(db, cipher)
+
+	credential := &model.GrowattCredential{Username: "user", Password: "p4ss", Token: "t0ken", Owner: "owner"}
+	if err := credentials.Create(credential); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	path := "/api/v1/credentials/growatt/1"
+
+	// The redacted password keeps its value, the emptied token is cleared.
+	if status := send(t, server, http.MethodPut, path, `{"username":"user2","password":"******","token":"","owner":"owner"}`); status != http.StatusOK {
+		t.Fatalf("PUT status = %d, want 200", status)
+	}
+
+	got, err := credentials.FindByID(credential.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+
+	if got.Username != "user2" || got.Password != "p4ss" || got.Token != "" {
+		t.Errorf("credential = %+v, want user2 with the stored password and no token", got)
+	}
+
+	encrypted, err := cipher.Encrypt("other")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	// Encrypted values are refused, whether they decrypt or not.
+	for _, password := range []string{encrypted, secret.Prefix + "garbage"} {
+		body := `{"username":"user","password":"` + password + `","owner":"owner"}`
+		if status := send(t, server, http.MethodPut, path, body); status != http.StatusUnprocessableEntity {
+			t.Errorf("PUT with password %q status = %d, want 422", password, status)
+		}
+
+		if status := send(t, server, http.MethodPost, "/api/v1/credentials/growatt", body); status != http.StatusUnprocessableEntity {
+			t.Errorf("POST with password %q status = %d, want 422", password, status)
+		}
+	}
+}
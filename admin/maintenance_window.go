@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+// maintenanceWindowRequest is a maintenance window. The scope fields are
+// optional but at least one is set, the vendor is checked along with that
+// since it comes first. The window ends after it starts.
+type maintenanceWindowRequest struct {
+	Vendor   string    `json:"vendor" validate:"required_without_all=SiteID Plant DeviceSN Area,omitempty,oneof=huawei kstar growatt solarman"`
+	SiteID   string    `json:"site_id" validate:"max=64"`
+	Plant    string    `json:"plant" validate:"max=255"`
+	DeviceSN string    `json:"device_sn" validate:"max=255"`
+	Area     string    `json:"area" validate:"max=255"`
+	StartAt  time.Time `json:"start_at" validate:"required"`
+	EndAt    time.Time `json:"end_at" validate:"required,gtfield=StartAt"`
+	Reason   string    `json:"reason" validate:"required,max=255"`
+}
+
+func (r maintenanceWindowRequest) toModel() *model.MaintenanceWindow {
+	return &model.MaintenanceWindow{
+		Vendor:   r.Vendor,
+		SiteID:   strings.TrimSpace(r.SiteID),
+		Plant:    strings.TrimSpace(r.Plant),
+		DeviceSN: strings.TrimSpace(r.DeviceSN),
+		Area:     strings.TrimSpace(r.Area),
+		StartAt:  r.StartAt,
+		EndAt:    r.EndAt,
+		Reason:   strings.TrimSpace(r.Reason),
+	}
+}
+
+func (s *Server) registerMaintenanceWindowRoutes() {
+	s.mux.HandleFunc("GET /api/v1/maintenance-windows", s.listMaintenanceWindows)
+	s.mux.HandleFunc("GET /api/v1/maintenance-windows/{id}", s.getMaintenanceWindow)
+	s.mux.HandleFunc("POST /api/v1/maintenance-windows", s.createMaintenanceWindow)
+	s.mux.HandleFunc("PUT /api/v1/maintenance-windows/{id}", s.updateMaintenanceWindow)
+	s.mux.HandleFunc("DELETE /api/v1/maintenance-windows/{id}", s.deleteMaintenanceWindow)
+}
+
+// listMaintenanceWindows lists every window, or with active=true the ones
+// open now.
+func (s *Server) listMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	maintenanceRepo := repo.NewMaintenanceWindowRepo(s.db)
+
+	var data []model.MaintenanceWindow
+	var err error
+	switch r.URL.Query().Get("active") {
+	case "", "false":
+		data, err = maintenanceRepo.FindAll()
+	case "true":
+		data, err = maintenanceRepo.FindActive(time.Now())
+	default:
+		writeError(w, http.StatusBadRequest, "active must be true or false")
+		return
+	}
+
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listResponse{Data: data, Total: int64(len(data))})
+}
+
+func (s *Server) getMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := repo.NewMaintenanceWindowRepo(s.db).FindByID(id)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) createMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceWindowRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	data := req.toModel()
+	if err := repo.NewMaintenanceWindowRepo(s.db).Create(data); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, data)
+}
+
+func (s *Server) updateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req maintenanceWindowRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	maintenanceRepo := repo.NewMaintenanceWindowRepo(s.db)
+	if _, err := maintenanceRepo.FindByID(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	if err := maintenanceRepo.Update(id, req.toModel()); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	data, err := maintenanceRepo.FindByID(id)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) deleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	maintenanceRepo := repo.NewMaintenanceWindowRepo(s.db)
+	if _, err := maintenanceRepo.FindByID(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	if err := maintenanceRepo.Delete(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+type installedCapacityRequest struct {
+	EfficiencyFactor float64 `json:"efficiency_factor" validate:"required,gt=0,lte=1"`
+	FocusHour        *int    `json:"focus_hour" validate:"required,min=0,max=23"`
+}
+
+type performanceAlarmConfigRequest struct {
+	Interval   int     `json:"interval" validate:"required,min=1"`
+	HitDay     *int    `json:"hit_day" validate:"omitempty,min=1"`
+	Percentage float64 `json:"percentage" validate:"required,gt=0,lte=100"`
+	Duration   *int    `json:"duration" validate:"omitempty,min=1"`
+}
+
+func (s *Server) registerPerformanceRoutes() {
+	s.mux.HandleFunc("GET /api/v1/installed-capacity", s.getInstalledCapacity)
+	s.mux.HandleFunc("PUT /api/v1/installed-capacity", s.updateInstalledCapacity)
+	s.mux.HandleFunc("GET /api/v1/performance-alarm-configs", s.listPerformanceAlarmConfigs)
+	s.mux.HandleFunc("PUT /api/v1/performance-alarm-configs/{name}", s.updatePerformanceAlarmConfig)
+}
+
+func (s *Server) getInstalledCapacity(w http.ResponseWriter, r *http.Request) {
+	data, err := repo.NewInstalledCapacityRepo(s.db).FindOne()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) updateInstalledCapacity(w http.ResponseWriter, r *http.Request) {
+	var req installedCapacityRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	data := &model.InstalledCapacity{
+		EfficiencyFactor: req.EfficiencyFactor,
+		FocusHour:        *req.FocusHour,
+	}
+
+	if err := repo.NewInstalledCapacityRepo(s.db).Save(data); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+// listPerformanceAlarmConfigs returns both alarm configs, falling back to
+// the built-in defaults like the alarms themselves do.
+func (s *Server) listPerformanceAlarmConfigs(w http.ResponseWriter, r *http.Request) {
+	configRepo := repo.NewPerformanceAlarmConfigRepo(s.db)
+	low, err := configRepo.GetLowPerformanceAlarmConfig()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	sum, err := configRepo.GetSumPerformanceAlarmConfig()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	data := []*model.PerformanceAlarmConfig{low, sum}
+	writeJSON(w, http.StatusOK, listResponse{Data: data, Total: int64(len(data))})
+}
+
+func (s *Server) updatePerformanceAlarmConfig(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name != config.LowPerformanceAlarm && name != config.SumPerformanceAlarm {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	var req performanceAlarmConfigRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	data := &model.PerformanceAlarmConfig{
+		Name:       name,
+		Interval:   req.Interval,
+		HitDay:     req.HitDay,
+		Percentage: req.Percentage,
+		Duration:   req.Duration,
+	}
+
+	if err := repo.NewPerformanceAlarmConfigRepo(s.db).Save(data); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// maxBodySize caps request bodies; admin payloads are small JSON objects.
+const maxBodySize = 1 << 20
+
+// Server serves the admin REST API over the credential, site region,
+// performance alarm, alarm severity and maintenance window tables.
+type Server struct {
+	db       *gorm.DB
+	cipher   *secret.Cipher
+	token    string
+	validate *validator.Validate
+	logger   zerolog.Logger
+	mux      *http.ServeMux
+}
+
+func NewServer(db *gorm.DB, cipher *secret.Cipher, token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("admin token is required")
+	}
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	s := &Server{
+		db:       db,
+		cipher:   cipher,
+		token:    token,
+		validate: validate,
+		logger:   zerolog.New(logger.NewWriter("admin.log")).With().Timestamp().Caller().Logger(),
+		mux:      http.NewServeMux(),
+	}
+
+	s.registerCredentialRoutes()
+	s.registerSiteRegionRoutes()
+	s.registerPerformanceRoutes()
+	s.registerAlarmSeverityRoutes()
+	s.registerMaintenanceWindowRoutes()
+	return s, nil
+}
+
+// Handler returns the authenticated root handler.
+func (s *Server) Handler() http.Handler {
+	return s.authenticate(s.mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type errorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type listResponse struct {
+	Data  interface{} `json:"data"`
+	Total int64       `json:"total"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// writeRepoError maps a repository error to a response, hiding internals
+// behind a generic message while logging the cause.
+func (s *Server) writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	s.logger.Error().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("Server::writeRepoError() - repository error")
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+// decode reads a JSON body into dst and validates it. It writes the error
+// response itself and reports whether the caller may continue.
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid json body: %v", err))
+		return false
+	}
+
+	if err := s.validate.Struct(dst); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return false
+		}
+
+		fields := make(map[string]string, len(validationErrors))
+		for _, fieldErr := range validationErrors {
+			fields[fieldErr.Field()] = validationMessage(fieldErr)
+		}
+
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "validation failed", Fields: fields})
+		return false
+	}
+
+	return true
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	if fieldErr.Param() == "" {
+		return fieldErr.Tag()
+	}
+
+	return fieldErr.Tag() + "=" + fieldErr.Param()
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func queryInt(r *http.Request, key string, fallback, max int) (int, error) {
+	raw := r.URL.Query().Get(key)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 || value > max {
+		return 0, fmt.Errorf("%s must be an integer between 0 and %d", key, max)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,257 @@
+package admin
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type siteRegionRequest struct {
+	Code string  `json:"code" validate:"required,max=50"`
+	Name string  `json:"name" validate:"required,max=255"`
+	Area *string `json:"area" validate:"omitempty,max=255"`
+}
+
+func (r siteRegionRequest) toModel() *model.SiteRegionMapping {
+	return &model.SiteRegionMapping{
+		Code: strings.TrimSpace(r.Code),
+		Name: strings.TrimSpace(r.Name),
+		Area: r.Area,
+	}
+}
+
+type areaCitiesRequest struct {
+	Codes []string `json:"codes" validate:"required,min=1,dive,required,max=50"`
+}
+
+func (s *Server) registerSiteRegionRoutes() {
+	s.mux.HandleFunc("GET /api/v1/site-regions", s.listSiteRegions)
+	s.mux.HandleFunc("GET /api/v1/site-regions/{id}", s.getSiteRegion)
+	s.mux.HandleFunc("POST /api/v1/site-regions", s.createSiteRegion)
+	s.mux.HandleFunc("PUT /api/v1/site-regions/{id}", s.updateSiteRegion)
+	s.mux.HandleFunc("DELETE /api/v1/site-regions/{id}", s.deleteSiteRegion)
+
+	s.mux.HandleFunc("GET /api/v1/regions", s.getRegions)
+	s.mux.HandleFunc("PUT /api/v1/regions/{area}", s.updateArea)
+	s.mux.HandleFunc("DELETE /api/v1/regions/{area}", s.deleteArea)
+}
+
+func (s *Server) listSiteRegions(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultPageLimit, maxPageLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0, math.MaxInt32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	siteRegionRepo := repo.NewSiteRegionMappingRepo(s.db)
+	total, err := siteRegionRepo.Count()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	data, err := siteRegionRepo.GetSiteRegionMappingsWithPagination(limit, offset)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listResponse{Data: data, Total: total})
+}
+
+func (s *Server) getSiteRegion(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := repo.NewSiteRegionMappingRepo(s.db).FindByID(id)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) createSiteRegion(w http.ResponseWriter, r *http.Request) {
+	var req siteRegionRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	data := req.toModel()
+	if err := repo.NewSiteRegionMappingRepo(s.db).CreateCity(data); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, data)
+}
+
+func (s *Server) updateSiteRegion(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req siteRegionRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	siteRegionRepo := repo.NewSiteRegionMappingRepo(s.db)
+	if _, err := siteRegionRepo.FindByID(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	if err := siteRegionRepo.UpdateCity(id, req.toModel()); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	data, err := siteRegionRepo.FindByID(id)
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) deleteSiteRegion(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	siteRegionRepo := repo.NewSiteRegionMappingRepo(s.db)
+	if _, err := siteRegionRepo.FindByID(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	if err := siteRegionRepo.DeleteCity(id); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getRegions serves the grouped area view. Areas come from the EMPTY-*
+// placeholder rows and from the cities assigned to them.
+func (s *Server) getRegions(w http.ResponseWriter, r *http.Request) {
+	siteRegionRepo := repo.NewSiteRegionMappingRepo(s.db)
+	placeholders, err := siteRegionRepo.GetAreaNotNull()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	cities, err := siteRegionRepo.GetSiteRegionMappings()
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, groupRegions(placeholders, cities))
+}
+
+func groupRegions(placeholders, cities []model.SiteRegionMapping) model.Regions {
+	areas := make([]string, 0)
+	citiesByArea := make(map[string][]model.SiteRegionMapping)
+	addArea := func(area string) {
+		if _, ok := citiesByArea[area]; !ok {
+			citiesByArea[area] = make([]model.SiteRegionMapping, 0)
+			areas = append(areas, area)
+		}
+	}
+
+	for _, placeholder := range placeholders {
+		if placeholder.Area != nil && *placeholder.Area != "" {
+			addArea(*placeholder.Area)
+		}
+	}
+
+	for _, city := range cities {
+		if city.Area == nil || *city.Area == "" {
+			continue
+		}
+
+		addArea(*city.Area)
+		citiesByArea[*city.Area] = append(citiesByArea[*city.Area], city)
+	}
+
+	sort.Strings(areas)
+	regions := model.Regions{Regions: make([]model.AreaWithCity, 0, len(areas))}
+	for _, area := range areas {
+		regions.Regions = append(regions.Regions, model.AreaWithCity{
+			Area:   area,
+			Cities: citiesByArea[area],
+		})
+	}
+
+	return regions
+}
+
+// updateArea replaces the cities of an area with the given site codes.
+func (s *Server) updateArea(w http.ResponseWriter, r *http.Request) {
+	area := strings.TrimSpace(r.PathValue("area"))
+	if area == "" {
+		writeError(w, http.StatusBadRequest, "invalid area")
+		return
+	}
+
+	var req areaCitiesRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		siteRegionRepo := repo.NewSiteRegionMappingRepo(tx)
+		if err := siteRegionRepo.UpdateCityToNullArea(area); err != nil {
+			return err
+		}
+
+		return siteRegionRepo.UpdateSiteRegionMapping(req.Codes, area)
+	})
+	if err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	s.getRegions(w, r)
+}
+
+func (s *Server) deleteArea(w http.ResponseWriter, r *http.Request) {
+	area := strings.TrimSpace(r.PathValue("area"))
+	if area == "" {
+		writeError(w, http.StatusBadRequest, "invalid area")
+		return
+	}
+
+	if err := repo.NewSiteRegionMappingRepo(s.db).UpdateCityToNullArea(area); err != nil {
+		s.writeRepoError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
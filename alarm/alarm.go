@@ -0,0 +1,17 @@
+package alarm
+
+import (
+	"fmt"
+
+	"github.com/HavvokLab/true-solar/model"
+)
+
+// Handler checks the devices of a single credential for alarm conditions
+// and sends the matching SNMP traps.
+type Handler interface {
+	Run(credential model.Credential) error
+}
+
+func invalidCredentialError(handler string, credential model.Credential) error {
+	return fmt.Errorf("%s: unsupported credential type %T", handler, credential)
+}
@@ -0,0 +1,148 @@
+package alarm_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"go.openly.dev/pointy"
+)
+
+func TestClearAlarm(t *testing.T) {
+	receiver := newTrapReceiver(t)
+	solarRepo := repo.NewSolarMemoryRepo()
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	plants := []interface{}{
+		dailyPlant(1, model.VendorTypeSolarman, "S1", "CNX02", 10, 5),
+		dailyPlant(1, model.VendorTypeGrowatt, "G1", "BKK01", 10, 5),
+		dailyPlant(1, model.VendorTypeKstar, "K1", "KKN01", 10, 5),
+		// ATV plants and unknown vendors get no clear trap.
+		dailyPlant(1, model.VendorTypeHuawei, "H1", "ATV-AYA01", 10, 5),
+		dailyPlant(1, "unknown", "U1", "PKT01", 10, 5),
+	}
+	if _, err := solarRepo.BulkIndex(fmt.Sprintf("%s-%s", model.SolarIndex, yesterday.Format("2006.01.02")), plants); err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	// Only the plants collected yesterday are cleared.
+	today := []interface{}{dailyPlant(0, model.VendorTypeGrowatt, "G2", "BKK02", 10, 5)}
+	if _, err := solarRepo.BulkIndex(fmt.Sprintf("%s-%s", model.SolarIndex, time.Now().Format("2006.01.02")), today); err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	handler := alarm.NewClearAlarm(solarRepo, receiver.orchestrator(t, infra.TrapTypeClearAlarm))
+	if err := handler.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Plants come by name, the description ends with the date of the run.
+	want := []trap{
+		{DeviceName: "BKK01", AlertName: "Growatt-Solarcell-Inverter_Error_0", Description: "Growatt, BKK01, Clear all alarms, Date:", Severity: infra.ClearSeverity},
+		{DeviceName: "CNX02", AlertName: "INVT-Solarcell-INVERTER_Disconnect", Description: "INVT-Ipanda, CNX02, Clear all alarms, Date:", Severity: infra.ClearSeverity},
+		{DeviceName: "CNX02", AlertName: "INVT-Solarcell-COLLECTOR_Disconnect", Description: "INVT-Ipanda, CNX02, Clear all alarms, Date:", Severity: infra.ClearSeverity},
+		{DeviceName: "KKN01", AlertName: "Huawei-Solarcell-Disconnect", Description: "Kstar, KKN01, Clear all alarms, Date:", Severity: infra.ClearSeverity},
+	}
+
+	got := receiver.wait(len(want))
+	if len(got) != len(want) {
+		t.Fatalf("traps = %+v, want %+v", got, want)
+	}
+
+	for i := range want {
+		prefix, date, found := strings.Cut(got[i].Description, "Date:")
+		if found {
+			got[i].Description = prefix + "Date:"
+		}
+
+		if _, err := time.Parse("2006-01-02 15:04:05", date); err != nil || got[i] != want[i] {
+			t.Errorf("trap %d = %+v with date %q, want %+v", i, got[i], date, want[i])
+		}
+	}
+}
+
+func TestClearPerformanceAlarm(t *testing.T) {
+	receiver := newTrapReceiver(t)
+	solarRepo := repo.NewSolarMemoryRepo()
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	raised := []interface{}{
+		model.NewSnmpPerformanceAlarmItem("low", "BKK01", "SolarCell-PerformanceLow", "Growatt, Performance Low", infra.MajorSeverity, yesterday.Format(time.RFC3339Nano)),
+		model.NewSnmpPerformanceAlarmItem("sum", "CNX01", "SolarCell-SumPerformanceLow", "Kstar, Sum Performance Low", infra.MajorSeverity, yesterday.Format(time.RFC3339Nano)),
+	}
+	if _, err := solarRepo.BulkIndex(fmt.Sprintf("%s-%s", model.PerformanceAlarmIndex, yesterday.Format("2006.01.02")), raised); err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	// Alarms raised today stay until tomorrow's clear.
+	today := []interface{}{
+		model.NewSnmpPerformanceAlarmItem("low", "KKN01", "SolarCell-PerformanceLow", "Huawei, Performance Low", infra.MajorSeverity, time.Now().Format(time.RFC3339Nano)),
+	}
+	if _, err := solarRepo.BulkIndex(fmt.Sprintf("%s-%s", model.PerformanceAlarmIndex, time.Now().Format("2006.01.02")), today); err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	handler := alarm.NewClearAlarm(solarRepo, receiver.orchestrator(t, infra.TrapTypeClearAlarm))
+	if err := handler.ClearPerformanceAlarm(); err != nil {
+		t.Fatalf("ClearPerformanceAlarm() error = %v", err)
+	}
+
+	want := []trap{
+		{DeviceName: "BKK01", AlertName: "SolarCell-PerformanceLow", Description: "Growatt, Performance Low", Severity: infra.ClearSeverity},
+		{DeviceName: "CNX01", AlertName: "SolarCell-SumPerformanceLow", Description: "Kstar, Sum Performance Low", Severity: infra.ClearSeverity},
+	}
+
+	got := receiver.wait(len(want) + 1)
+	if len(got) != len(want) {
+		t.Fatalf("traps = %+v, want %+v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("trap %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestClearAlarmPayload(t *testing.T) {
+	handler := alarm.NewClearAlarm(repo.NewSolarMemoryRepo(), nil)
+	date := time.Date(2024, 1, 15, 6, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		vendorType string
+		plantName  string
+		want       []string
+		wantErr    bool
+	}{
+		{name: "growatt", vendorType: "GROWATT", plantName: "BKK01", want: []string{"Growatt-Solarcell-Inverter_Error_0"}},
+		{name: "huawei", vendorType: model.VendorTypeHuawei, plantName: "BKK01", want: []string{"Huawei-Solarcell-HUW_Disconnect"}},
+		{name: "invt", vendorType: model.VendorTypeInvt, plantName: "BKK01", want: []string{"INVT-Solarcell-INVERTER_Disconnect", "INVT-Solarcell-COLLECTOR_Disconnect"}},
+		{name: "atv plant", vendorType: model.VendorTypeHuawei, plantName: "ATV-BKK01", wantErr: true},
+		{name: "unknown vendor", vendorType: "unknown", plantName: "BKK01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payloads, err := handler.Payload(&date, &model.PlantItem{VendorType: tt.vendorType, Name: pointy.String(tt.plantName)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Payload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(payloads) != len(tt.want) {
+				t.Fatalf("Payload() = %d payloads, want %d", len(payloads), len(tt.want))
+			}
+
+			for i, payload := range payloads {
+				if payload.PlantName != tt.plantName || payload.AlarmName != tt.want[i] || !strings.HasSuffix(payload.Payload, ", "+tt.plantName+", Clear all alarms, Date:2024-01-15 06:30:00") {
+					t.Errorf("payload %d = %+v, want %s of %s", i, payload, tt.want[i], tt.plantName)
+				}
+			}
+		})
+	}
+}
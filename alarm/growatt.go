@@ -3,6 +3,7 @@ package alarm
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,36 +11,55 @@ import (
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
-	"github.com/HavvokLab/true-solar/pkg/util"
 	"github.com/HavvokLab/true-solar/repo"
-	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 type GrowattAlarm struct {
-	vendorType string
-	solarRepo  repo.SolarRepo
-	snmp       *infra.SnmpOrchestrator
-	rdb        *redis.Client
-	logger     zerolog.Logger
+	vendorType      string
+	solarRepo       repo.SolarRepo
+	siteRegionRepo  repo.SiteRegionMappingRepo
+	severityRepo    repo.AlarmSeverityMappingRepo
+	maintenanceRepo repo.MaintenanceWindowRepo
+	snmp            *infra.SnmpOrchestrator
+	state           *StateStore
+	logger          zerolog.Logger
+	clientOptions   []growatt.Option
 }
 
-func NewGrowattAlarm(solarRepo repo.SolarRepo, snmp *infra.SnmpOrchestrator, rdb *redis.Client) *GrowattAlarm {
+func NewGrowattAlarm(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *StateStore, clientOptions ...growatt.Option) *GrowattAlarm {
 	return &GrowattAlarm{
-		vendorType: strings.ToUpper(model.VendorTypeGrowatt),
-		solarRepo:  solarRepo,
-		snmp:       snmp,
-		rdb:        rdb,
-		logger:     zerolog.New(logger.NewWriter("growatt_alarm.log")).With().Timestamp().Caller().Logger(),
+		vendorType:      strings.ToUpper(model.VendorTypeGrowatt),
+		solarRepo:       solarRepo,
+		siteRegionRepo:  siteRegionRepo,
+		severityRepo:    severityRepo,
+		maintenanceRepo: maintenanceRepo,
+		snmp:            snmp,
+		state:           state,
+		logger:          zerolog.New(logger.NewWriter("growatt_alarm.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:   clientOptions,
 	}
 }
 
-func (s *GrowattAlarm) Run(credential *model.GrowattCredential) error {
+func (s *GrowattAlarm) Run(cred model.Credential) error {
+	credential, ok := cred.(*model.GrowattCredential)
+	if !ok {
+		return invalidCredentialError("GrowattAlarm", cred)
+	}
+
 	now := time.Now().UTC()
-	documents := make([]interface{}, 0)
 	ctx := context.Background()
-	client := growatt.NewGrowattClient(credential.Username, credential.Token)
+	maintenance, err := LoadMaintenance(s.maintenanceRepo, s.siteRegionRepo, time.Now())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to load maintenance windows, no alarm is silenced")
+	}
+	tracker := newTracker(s.vendorType, s.state, s.snmp, maintenance)
+	severities, err := LoadSeverityTable(s.severityRepo)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to load severity mapping, alarms are major")
+	}
+	client := growatt.NewGrowattClient(credential.Username, credential.Token, s.clientOptions...)
 	plants, err := client.GetPlantList()
 	if err != nil {
 		s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to get plant list")
@@ -67,48 +87,29 @@ func (s *GrowattAlarm) Run(credential *model.GrowattCredential) error {
 			deviceStatus := growatt.GrowattInverterStatusMapper[status]
 			deviceType := growatt.GrowattEquipmentTypeMapper[dtype]
 			deviceName := fmt.Sprintf("%s_%d_%s", plantName, plantID, deviceSN)
-			var document interface{}
+			deviceKey := DeviceKey{Vendor: model.VendorTypeGrowatt, Plant: strconv.Itoa(plantID), Device: deviceSN}
 
 			s.logger.Info().Str("username", credential.Username).Str("device_sn", deviceSN).Str("device_model", deviceModel).Str("device_status", deviceStatus).Str("device_type", deviceType).Str("device_name", deviceName).Msg("GrowattAlarm::Run() - retrieve alarm")
+			// A device has one alarm at a time, raising one clears the other.
 			switch deviceStatus {
 			case "Online":
-				key := fmt.Sprintf("%d,%s,%s,%s", plantID, plantName, deviceType, deviceSN)
-				val, err := s.rdb.Get(ctx, key).Result()
-				if err != nil {
-					if err == redis.Nil {
-						continue
-					}
-					s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to get redis key")
-					continue
-				}
-
-				if !util.IsEmpty(val) {
-					vals := strings.Split(val, ",")
-					alarmName := fmt.Sprintf("Growatt,%s,%s", vals[1], deviceModel)
-					payload := fmt.Sprintf("%s-Error-%s", alarmName, vals[0])
-					severity := infra.ClearSeverity
-					document = model.NewSnmpAlarmItem(s.vendorType, deviceName, payload, alarmName, severity, deviceLastUpdateTime)
-					s.snmp.SendTrap(deviceName, payload, alarmName, severity, deviceLastUpdateTime)
+				if _, err := tracker.clearAs(ctx, deviceKey, growattClearTrap(deviceLastUpdateTime)); err != nil {
+					s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to clear alarms")
 				}
-
-				if err := s.rdb.Del(ctx, key).Err(); err != nil {
-					s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to delete redis key")
-					continue
-				}
-
 			case "Disconnect":
-				rkey := fmt.Sprintf("%d,%s,%s,%s", plantID, plantName, deviceType, deviceSN)
-				val := "0,Disconnect"
-				if err := s.rdb.Set(ctx, rkey, val, 0).Err(); err != nil {
-					s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to set redis key")
-					continue
+				state := AlarmState{
+					Vendor:      deviceKey.Vendor,
+					Plant:       deviceKey.Plant,
+					Device:      deviceKey.Device,
+					Code:        "Disconnect",
+					PlantName:   plantName,
+					Name:        deviceName,
+					Alert:       fmt.Sprintf("%s-Error-0", deviceType),
+					Description: fmt.Sprintf("Growatt,Disconnect,%s", deviceModel),
+					Severity:    severities.Resolve(SeverityKey{Vendor: model.VendorTypeGrowatt, Code: "Disconnect"}),
+					AlarmTime:   deviceLastUpdateTime,
 				}
-
-				alarmName := fmt.Sprintf("Growatt,Disconnect,%s", deviceModel)
-				payload := fmt.Sprintf("%s-Error-0", deviceType)
-				severity := "4"
-				document = model.NewSnmpAlarmItem(s.vendorType, deviceName, payload, alarmName, severity, deviceLastUpdateTime)
-				s.snmp.SendTrap(deviceName, payload, alarmName, severity, deviceLastUpdateTime)
+				s.raise(ctx, tracker, state, deviceLastUpdateTime)
 			default:
 				date := now.AddDate(0, 0, -1).Format("2006-01-02")
 				alarms, err := client.GetInverterAlertList(deviceSN, now.AddDate(0, 0, -1))
@@ -119,29 +120,30 @@ func (s *GrowattAlarm) Run(credential *model.GrowattCredential) error {
 
 				if len(alarms) > 0 {
 					alarm := alarms[0]
-					rkey := fmt.Sprintf("%d,%s,%s,%s", plantID, plantName, deviceType, deviceSN)
-					val := fmt.Sprintf("%d,%s", pointy.IntValue(alarm.AlarmCode, 0), pointy.StringValue(alarm.AlarmMessage, ""))
-					if err := s.rdb.Set(ctx, rkey, val, 0).Err(); err != nil {
-						s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to set redis key")
-						continue
+					alarmCode := pointy.IntValue(alarm.AlarmCode, 0)
+					state := AlarmState{
+						Vendor:      deviceKey.Vendor,
+						Plant:       deviceKey.Plant,
+						Device:      deviceKey.Device,
+						Code:        strconv.Itoa(alarmCode),
+						PlantName:   plantName,
+						Name:        deviceName,
+						Alert:       fmt.Sprintf("%s-Error-%d", deviceType, alarmCode),
+						Description: fmt.Sprintf("Growatt,%s,%s", pointy.StringValue(alarm.AlarmMessage, ""), deviceModel),
+						Severity:    severities.Resolve(SeverityKey{Vendor: model.VendorTypeGrowatt, Code: strconv.Itoa(alarmCode), Name: pointy.StringValue(alarm.AlarmMessage, "")}),
+						AlarmTime:   date,
 					}
-
-					alarmName := fmt.Sprintf("Growatt,%s,%s", pointy.StringValue(alarm.AlarmMessage, ""), deviceModel)
-					payload := fmt.Sprintf("%s-Error-%d", deviceType, pointy.IntValue(alarm.AlarmCode, 0))
-					severity := infra.MajorSeverity
-					document = model.NewSnmpAlarmItem(s.vendorType, deviceName, payload, alarmName, severity, date)
-					s.snmp.SendTrap(deviceName, payload, alarmName, severity, date)
+					s.raise(ctx, tracker, state, deviceLastUpdateTime)
 				}
 			}
-
-			documents = append(documents, document)
 		}
 
 		time.Sleep(10 * time.Second)
 	}
 
 	index := fmt.Sprintf("%s-%s", model.AlarmIndex, now.Format("2006.01.02"))
-	if err := s.solarRepo.BulkIndex(index, documents); err != nil {
+	documents := tracker.documents
+	if _, err := s.solarRepo.BulkIndex(index, documents); err != nil {
 		s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to bulk index")
 		return err
 	}
@@ -150,3 +152,32 @@ func (s *GrowattAlarm) Run(credential *model.GrowattCredential) error {
 	return nil
 
 }
+
+// raise raises state and clears the other alarm of its device, updated at
+// updateTime. Failures are logged, the run goes on with the next device.
+func (s *GrowattAlarm) raise(ctx context.Context, tracker *tracker, state AlarmState, updateTime string) {
+	if _, err := tracker.raise(ctx, state); err != nil {
+		s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to raise alarm")
+		return
+	}
+
+	if _, err := tracker.clearAs(ctx, state.DeviceKey(), growattClearTrap(updateTime), state.Code); err != nil {
+		s.logger.Error().Err(err).Msg("GrowattAlarm::Run() - failed to clear alarms")
+	}
+}
+
+// growattClearTrap builds the Growatt clear traps the way the handler always
+// sent them: the alert is the description suffixed with the error code, 0
+// for a disconnect, and the time is updateTime, the last update of the device.
+func growattClearTrap(updateTime string) func(AlarmState) AlarmState {
+	return func(state AlarmState) AlarmState {
+		code := state.Code
+		if code == "Disconnect" {
+			code = "0"
+		}
+
+		state.Alert = fmt.Sprintf("%s-Error-%s", state.Description, code)
+		state.AlarmTime = updateTime
+		return state
+	}
+}
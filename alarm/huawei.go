@@ -11,41 +11,60 @@ import (
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
-	"github.com/HavvokLab/true-solar/pkg/util"
 	"github.com/HavvokLab/true-solar/repo"
-	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 type HuaweiAlarm struct {
-	vendorType string
-	solarRepo  repo.SolarRepo
-	snmp       *infra.SnmpOrchestrator
-	rdb        *redis.Client
-	logger     zerolog.Logger
+	vendorType      string
+	solarRepo       repo.SolarRepo
+	siteRegionRepo  repo.SiteRegionMappingRepo
+	severityRepo    repo.AlarmSeverityMappingRepo
+	maintenanceRepo repo.MaintenanceWindowRepo
+	snmp            *infra.SnmpOrchestrator
+	state           *StateStore
+	logger          zerolog.Logger
+	clientOptions   []huawei.Option
 }
 
-func NewHuaweiAlarm(solarRepo repo.SolarRepo, snmp *infra.SnmpOrchestrator, rdb *redis.Client) *HuaweiAlarm {
+func NewHuaweiAlarm(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *StateStore, clientOptions ...huawei.Option) *HuaweiAlarm {
 	return &HuaweiAlarm{
-		vendorType: strings.ToUpper(model.VendorTypeHuawei),
-		solarRepo:  solarRepo,
-		snmp:       snmp,
-		rdb:        rdb,
-		logger:     zerolog.New(logger.NewWriter("huawei_alarm.log")).With().Timestamp().Caller().Logger(),
+		vendorType:      strings.ToUpper(model.VendorTypeHuawei),
+		solarRepo:       solarRepo,
+		siteRegionRepo:  siteRegionRepo,
+		severityRepo:    severityRepo,
+		maintenanceRepo: maintenanceRepo,
+		snmp:            snmp,
+		state:           state,
+		logger:          zerolog.New(logger.NewWriter("huawei_alarm.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:   append([]huawei.Option{huawei.WithRetryCount(0)}, clientOptions...),
 	}
 }
 
-func (s *HuaweiAlarm) Run(credential *model.HuaweiCredential) error {
+func (s *HuaweiAlarm) Run(cred model.Credential) error {
+	credential, ok := cred.(*model.HuaweiCredential)
+	if !ok {
+		return invalidCredentialError("HuaweiAlarm", cred)
+	}
+
 	s.logger.Info().Str("username", credential.Username).Msg("HuaweiAlarm::Run() - start alarm")
 
 	now := time.Now().UTC()
 	ctx := context.Background()
 	beginTime := time.Date(now.Year(), now.Month(), now.Day(), 6, 0, 0, 0, time.Local).UnixNano() / 1e6
 	endTime := now.UnixNano() / 1e6
-	documents := make([]interface{}, 0)
+	maintenance, err := LoadMaintenance(s.maintenanceRepo, s.siteRegionRepo, time.Now())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to load maintenance windows, no alarm is silenced")
+	}
+	tracker := newTracker(s.vendorType, s.state, s.snmp, maintenance)
+	severities, err := LoadSeverityTable(s.severityRepo)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to load severity mapping, alarms are major")
+	}
 
-	client, err := huawei.NewHuaweiClient(credential.Username, credential.Password, huawei.WithRetryCount(0))
+	client, err := huawei.NewHuaweiClient(credential.Username, credential.Password, s.clientOptions...)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to create huawei client")
 		return err
@@ -187,102 +206,68 @@ func (s *HuaweiAlarm) Run(credential *model.HuaweiCredential) error {
 						}
 					}
 
-					key := fmt.Sprintf("Huawei,%s,%s,%s,%s", plantCode, deviceSN, deviceName, "Disconnect")
-					val := fmt.Sprintf("%s,%s,%s", plantName, "Disconnect", shutdownTime)
-					err := s.rdb.Set(ctx, key, val, 0).Err()
-					if err != nil {
-						s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to set redis")
+					state := AlarmState{
+						Vendor:      model.VendorTypeHuawei,
+						Plant:       plantCode,
+						Device:      deviceSN,
+						Code:        "Disconnect",
+						PlantName:   plantName,
+						Name:        plantName,
+						Alert:       fmt.Sprintf("HUW-%s", "Disconnect"),
+						Description: fmt.Sprintf("Huawei,%s,%s", deviceName, "Disconnect"),
+						Severity:    severities.Resolve(SeverityKey{Vendor: model.VendorTypeHuawei, Code: "Disconnect"}),
+						AlarmTime:   shutdownTime,
+					}
+					if _, err := tracker.raise(ctx, state); err != nil {
+						s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to raise alarm")
 						return err
 					}
-
-					alarmName := fmt.Sprintf("HUW-%s", "Disconnect")
-					payload := fmt.Sprintf("Huawei,%s,%s", deviceName, "Disconnect")
-					document := model.NewSnmpAlarmItem(s.vendorType, plantName, alarmName, payload, infra.MajorSeverity, shutdownTime)
-					s.snmp.SendTrap(plantName, alarmName, payload, infra.MajorSeverity, shutdownTime)
-					documents = append(documents, document)
 					continue
 				}
 			}
 
-			if len(mapDeviceSNToAlarm[deviceSN]) > 0 {
-				for _, alarm := range mapDeviceSNToAlarm[deviceSN] {
-					alarmName := pointy.StringValue(alarm.AlarmName, "")
-					alarmCause := pointy.StringValue(alarm.AlarmCause, "")
-					alarmTime := strconv.Itoa(int(pointy.Int64Value(alarm.RaiseTime, 0)))
-
-					key := fmt.Sprintf("Huawei,%s,%s,%s,%s", plantCode, deviceSN, deviceName, alarmName)
-					val := fmt.Sprintf("%s,%s,%s", plantName, alarmCause, alarmTime)
-					err := s.rdb.Set(ctx, key, val, 0).Err()
-					if err != nil {
-						s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to set redis")
-						return err
-					}
-
-					alarmName = strings.ReplaceAll(fmt.Sprintf("HUW-%s", alarmName), " ", "-")
-					payload := fmt.Sprintf("Huawei,%s,%s", deviceName, alarmCause)
-
-					document := model.NewSnmpAlarmItem(s.vendorType, plantName, alarmName, payload, infra.MajorSeverity, alarmTime)
-					s.snmp.SendTrap(plantName, alarmName, payload, infra.MajorSeverity, alarmTime)
-					documents = append(documents, document)
+			// The alarm list holds every alarm the device still has, the
+			// others are missed and cleared once debounced.
+			codes := make([]string, 0, len(mapDeviceSNToAlarm[deviceSN]))
+			for _, alarm := range mapDeviceSNToAlarm[deviceSN] {
+				alarmName := pointy.StringValue(alarm.AlarmName, "")
+				alarmCause := pointy.StringValue(alarm.AlarmCause, "")
+				alarmTime := strconv.Itoa(int(pointy.Int64Value(alarm.RaiseTime, 0)))
+				alarmID := ""
+				if alarm.AlarmID != nil {
+					alarmID = strconv.Itoa(*alarm.AlarmID)
 				}
 
-				continue
-			}
-
-			var keys []string
-			var cursor uint64
-			for {
-				var scanKeys []string
-				match := fmt.Sprintf("Huawei,%s,%s,%s,*", plantCode, deviceSN, deviceName)
-				scanKeys, cursor, err = s.rdb.Scan(ctx, cursor, match, 10).Result()
-				if err != nil {
-					s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to scan redis")
-					return err
+				state := AlarmState{
+					Vendor:      model.VendorTypeHuawei,
+					Plant:       plantCode,
+					Device:      deviceSN,
+					Code:        alarmName,
+					PlantName:   plantName,
+					Name:        plantName,
+					Alert:       strings.ReplaceAll(fmt.Sprintf("HUW-%s", alarmName), " ", "-"),
+					Description: fmt.Sprintf("Huawei,%s,%s", deviceName, alarmCause),
+					Severity:    severities.Resolve(SeverityKey{Vendor: model.VendorTypeHuawei, Code: alarmID, Name: alarmName, Level: alarm.Level}),
+					AlarmTime:   alarmTime,
 				}
-
-				keys = append(keys, scanKeys...)
-				if cursor == 0 {
-					break
+				if _, err := tracker.raise(ctx, state); err != nil {
+					s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to raise alarm")
+					return err
 				}
+				codes = append(codes, alarmName)
 			}
 
-			for _, key := range keys {
-				val, err := s.rdb.Get(ctx, key).Result()
-				if err != nil {
-					if err != redis.Nil {
-						s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to get redis")
-						return err
-					}
-					continue
-				}
-
-				if !util.IsEmpty(val) {
-					splitKey := strings.Split(key, ",")
-					splitVal := strings.Split(val, ",")
-
-					alarmName := strings.ReplaceAll(fmt.Sprintf("HUW-%s", splitKey[4]), " ", "-")
-					payload := fmt.Sprintf("Huawei,%s,%s", deviceName, splitVal[1])
-					document := model.NewSnmpAlarmItem(s.vendorType, plantName, alarmName, payload, infra.ClearSeverity, splitVal[2])
-					s.snmp.SendTrap(
-						splitVal[0],
-						alarmName,
-						payload,
-						infra.ClearSeverity,
-						splitVal[2],
-					)
-					documents = append(documents, document)
-
-					if err := s.rdb.Del(ctx, key).Err(); err != nil {
-						s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to delete redis")
-						return err
-					}
-				}
+			deviceKey := DeviceKey{Vendor: model.VendorTypeHuawei, Plant: plantCode, Device: deviceSN}
+			if _, err := tracker.clear(ctx, deviceKey, codes...); err != nil {
+				s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to clear alarms")
+				return err
 			}
 		}
 	}
 
 	index := fmt.Sprintf("%s-%s", model.AlarmIndex, now.Format("2006.01.02"))
-	if err := s.solarRepo.BulkIndex(index, documents); err != nil {
+	documents := tracker.documents
+	if _, err := s.solarRepo.BulkIndex(index, documents); err != nil {
 		s.logger.Error().Err(err).Msg("HuaweiAlarm::Run() - failed to bulk index")
 		return err
 	}
@@ -0,0 +1,164 @@
+package alarm_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/api/fake"
+	"github.com/HavvokLab/true-solar/api/huawei"
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// huaweiDevice is the online inverter of the Huawei fixtures.
+var huaweiDevice = alarm.DeviceKey{Vendor: model.VendorTypeHuawei, Plant: "NE=1001", Device: "HWINV0001"}
+
+// huaweiAlarmEnv runs the Huawei alarm handler against the fake Huawei
+// server, an in-memory Redis and SQLite store and the memory SolarRepo,
+// serving the alarms of HWINV0001 set by the test.
+type huaweiAlarmEnv struct {
+	server    *fake.Server
+	solarRepo memoryRepo
+	state     *alarm.StateStore
+	handler   *alarm.HuaweiAlarm
+
+	mu     sync.Mutex
+	alarms []string
+}
+
+func newHuaweiAlarmEnv(t *testing.T) *huaweiAlarmEnv {
+	t.Helper()
+	env := &huaweiAlarmEnv{server: fake.NewHuaweiServer()}
+	t.Cleanup(env.server.Close)
+	env.server.HandleFunc("/thirdData/getAlarmList", env.serveAlarmList)
+
+	db := newStore(t)
+	redisServer := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeHuaweiAlarm, nil)
+	if err != nil {
+		t.Fatalf("create snmp orchestrator: %v", err)
+	}
+
+	env.solarRepo = repo.NewSolarMemoryRepo()
+	env.state = alarm.NewStateStore(rdb, config.AlarmConfig{})
+	env.handler = alarm.NewHuaweiAlarm(
+		env.solarRepo,
+		repo.NewSiteRegionMappingRepo(db),
+		repo.NewAlarmSeverityMappingRepo(db),
+		repo.NewMaintenanceWindowRepo(db),
+		snmp,
+		env.state,
+		huawei.WithBaseURL(env.server.URL),
+	)
+
+	return env
+}
+
+func (e *huaweiAlarmEnv) serveAlarmList(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := ""
+	for i, name := range e.alarms {
+		if list != "" {
+			list += ","
+		}
+		list += fmt.Sprintf(`{"stationCode":"NE=1001","stationName":"BKK02-AN-1P-5.00","esnCode":"HWINV0001","devName":"Inverter-1","devTypeId":1,"alarmId":%d,"alarmName":%q,"alarmCause":"cause","raiseTime":1705316400000,"lev":2,"status":1}`,
+			2032+i, name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"success":true,"failCode":0,"params":{},"message":null,"data":[%s]}`, list)
+}
+
+// set replaces the alarms reported for HWINV0001.
+func (e *huaweiAlarmEnv) set(alarms ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.alarms = alarms
+}
+
+// run runs the handler and returns the alerts of the traps sent for
+// HWINV0001 with their severities.
+func (e *huaweiAlarmEnv) run(t *testing.T) map[string]string {
+	t.Helper()
+	index := fmt.Sprintf("%s-%s", model.AlarmIndex, time.Now().Format("2006.01.02"))
+	before := len(e.solarRepo.Documents(index))
+	if err := e.handler.Run(&model.HuaweiCredential{Username: "fake", Password: "fake", Version: 1}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	traps := make(map[string]string)
+	for _, doc := range e.solarRepo.Documents(index)[before:] {
+		item, ok := doc.(model.SnmpAlarmItem)
+		if !ok {
+			t.Fatalf("alarm document %T, want model.SnmpAlarmItem", doc)
+		}
+
+		if item.AlertName != "HUW-Disconnect" {
+			traps[item.AlertName] = item.Severity
+		}
+	}
+
+	return traps
+}
+
+func (e *huaweiAlarmEnv) activeCodes(t *testing.T) []string {
+	t.Helper()
+	states, err := e.state.Active(context.Background(), huaweiDevice)
+	if err != nil {
+		t.Fatalf("Active() error = %v", err)
+	}
+
+	codes := make([]string, 0, len(states))
+	for _, state := range states {
+		codes = append(codes, state.Code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+func TestHuaweiAlarmClearsMissedAlarmOfActiveDevice(t *testing.T) {
+	env := newHuaweiAlarmEnv(t)
+
+	env.set("Grid Loss", "Low Insulation")
+	if traps := env.run(t); len(traps) != 2 || traps["HUW-Grid-Loss"] == infra.ClearSeverity || traps["HUW-Low-Insulation"] == infra.ClearSeverity {
+		t.Fatalf("traps = %v, want the raise traps of both alarms", traps)
+	}
+
+	// Grid Loss stays, Low Insulation is no longer reported and clears.
+	env.set("Grid Loss")
+	traps := env.run(t)
+	if len(traps) != 1 || traps["HUW-Low-Insulation"] != infra.ClearSeverity {
+		t.Fatalf("traps = %v, want the clear trap of Low Insulation only", traps)
+	}
+
+	if codes := env.activeCodes(t); len(codes) != 1 || codes[0] != "Grid Loss" {
+		t.Fatalf("active = %v, want Grid Loss", codes)
+	}
+
+	env.set()
+	traps = env.run(t)
+	if len(traps) != 1 || traps["HUW-Grid-Loss"] != infra.ClearSeverity {
+		t.Fatalf("traps = %v, want the clear trap of Grid Loss", traps)
+	}
+
+	if codes := env.activeCodes(t); len(codes) != 0 {
+		t.Fatalf("active = %v, want none", codes)
+	}
+}
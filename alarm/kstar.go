@@ -11,40 +11,52 @@ import (
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
-	"github.com/HavvokLab/true-solar/pkg/util"
 	"github.com/HavvokLab/true-solar/repo"
-	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 type KstarAlarm struct {
-	vendorType string
-	solarRepo  repo.SolarRepo
-	snmp       *infra.SnmpOrchestrator
-	rdb        *redis.Client
-	logger     zerolog.Logger
+	vendorType      string
+	solarRepo       repo.SolarRepo
+	siteRegionRepo  repo.SiteRegionMappingRepo
+	severityRepo    repo.AlarmSeverityMappingRepo
+	maintenanceRepo repo.MaintenanceWindowRepo
+	snmp            *infra.SnmpOrchestrator
+	state           *StateStore
+	logger          zerolog.Logger
+	clientOptions   []kstar.Option
 }
 
-func NewKstarAlarm(solarRepo repo.SolarRepo, snmp *infra.SnmpOrchestrator, rdb *redis.Client) *KstarAlarm {
+func NewKstarAlarm(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *StateStore, clientOptions ...kstar.Option) *KstarAlarm {
 	return &KstarAlarm{
-		vendorType: strings.ToUpper(model.VendorTypeKstar),
-		solarRepo:  solarRepo,
-		snmp:       snmp,
-		rdb:        rdb,
-		logger:     zerolog.New(logger.NewWriter("kstar_alarm.log")).With().Timestamp().Caller().Logger(),
+		vendorType:      strings.ToUpper(model.VendorTypeKstar),
+		solarRepo:       solarRepo,
+		siteRegionRepo:  siteRegionRepo,
+		severityRepo:    severityRepo,
+		maintenanceRepo: maintenanceRepo,
+		snmp:            snmp,
+		state:           state,
+		logger:          zerolog.New(logger.NewWriter("kstar_alarm.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:   append([]kstar.Option{kstar.WithRetryCount(0)}, clientOptions...),
 	}
 }
 
-func (s *KstarAlarm) Run(credential *model.KstarCredential) error {
+func (s *KstarAlarm) Run(cred model.Credential) (err error) {
+	credential, ok := cred.(*model.KstarCredential)
+	if !ok {
+		return invalidCredentialError("KstarAlarm", cred)
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
-			s.logger.Warn().Str("username", credential.Username).Any("error", r).Msg("KstarAlarm::Run() - failed to run")
+			s.logger.Error().Str("username", credential.Username).Any("recover", r).Msg("KstarAlarm::Run() - panic")
+			err = fmt.Errorf("KstarAlarm::Run() - panic: %v", r)
 		}
 	}()
 
 	ctx := context.Background()
-	client := kstar.NewKstarClient(credential.Username, credential.Password, kstar.WithRetryCount(0))
+	client := kstar.NewKstarClient(credential.Username, credential.Password, s.clientOptions...)
 	deviceList, err := client.GetDeviceList()
 	if err != nil {
 		s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to get device list")
@@ -58,10 +70,19 @@ func (s *KstarAlarm) Run(credential *model.KstarCredential) error {
 
 	deviceCount := 1
 	deviceSize := len(deviceList)
-	documents := make([]interface{}, 0)
+	maintenance, err := LoadMaintenance(s.maintenanceRepo, s.siteRegionRepo, time.Now())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to load maintenance windows, no alarm is silenced")
+	}
+	tracker := newTracker(s.vendorType, s.state, s.snmp, maintenance)
+	severities, err := LoadSeverityTable(s.severityRepo)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to load severity mapping, alarms are major")
+	}
 	for _, device := range deviceList {
 		deviceID := pointy.StringValue(device.ID, "")
 		deviceName := pointy.StringValue(device.Name, "")
+		deviceSN := pointy.StringValue(device.SN, "")
 		plantID := pointy.StringValue(device.PlantID, "")
 		plantName := pointy.StringValue(device.PlantName, "")
 		saveTime := pointy.StringValue(device.SaveTime, "")
@@ -84,132 +105,67 @@ func (s *KstarAlarm) Run(credential *model.KstarCredential) error {
 			saveTime = pointy.StringValue(realtimeDeviceDataResp.Data.SaveTime, "")
 		}
 
-		if device.Status != nil {
-			var document interface{}
-			switch *device.Status {
-			case 0:
-				key := fmt.Sprintf("Kstar,%s,%s,%s,%s", plantID, deviceID, deviceName, "Kstar-Disconnect")
-				val := fmt.Sprintf("%s,%s", plantName, saveTime)
-				if err := s.rdb.Set(ctx, key, val, 0).Err(); err != nil {
-					s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to set redis key")
-					return err
-				}
-
-				alarmName := "Kstar-Disconnect"
-				payload := fmt.Sprintf("Kstar,%s,%s,%s", plantID, deviceID, deviceName)
-				document = model.NewSnmpAlarmItem(s.vendorType, plantName, alarmName, payload, infra.MajorSeverity, saveTime)
-				s.snmp.SendTrap(plantName, alarmName, payload, infra.MajorSeverity, saveTime)
-			case 1:
-				realtimeAlarmResp, err := client.GetRealtimeAlarmListOfDevice(deviceID)
-				if err != nil {
-					s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to get realtime alarm list of device")
-					return err
-				}
+		if device.Status == nil {
+			continue
+		}
 
-				if len(realtimeAlarmResp.Data) > 0 {
-					alarmName := "Kstar-Disconnect"
-					payload := fmt.Sprintf("Kstar,%s,%s,%s", plantID, deviceID, deviceName)
-					document = model.NewSnmpAlarmItem(s.vendorType, plantName, alarmName, payload, infra.MajorSeverity, saveTime)
-					s.snmp.SendTrap(plantName, alarmName, payload, infra.MajorSeverity, saveTime)
-
-					if err := s.rdb.Del(ctx, alarmName).Err(); err != nil {
-						s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to delete redis key")
-						return err
-					}
-
-					for _, alarm := range realtimeAlarmResp.Data {
-						alarmTime := pointy.StringValue(alarm.SaveTime, "")
-						alarmMessage := strings.ReplaceAll(pointy.StringValue(alarm.Message, ""), " ", "-")
-
-						key := fmt.Sprintf("Kstar,%s,%s,%s,%s", plantID, deviceID, deviceName, alarmMessage)
-						val := fmt.Sprintf("%s,%s", plantName, alarmTime)
-						if err := s.rdb.Set(ctx, key, val, 0).Err(); err != nil {
-							s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to set redis key")
-							return err
-						}
-
-						payload := fmt.Sprintf("Kstar,%s,%s,%s", plantID, deviceID, deviceName)
-						document = model.NewSnmpAlarmItem(s.vendorType, plantName, alarmMessage, payload, infra.MajorSeverity, alarmTime)
-						s.snmp.SendTrap(plantName, alarmMessage, payload, infra.MajorSeverity, alarmTime)
-					}
-					continue
-				}
+		deviceKey := DeviceKey{Vendor: model.VendorTypeKstar, Plant: plantID, Device: deviceID}
+		newState := func(code, alarmTime, severity string) AlarmState {
+			return AlarmState{
+				Vendor:      deviceKey.Vendor,
+				Plant:       deviceKey.Plant,
+				Device:      deviceKey.Device,
+				DeviceSN:    deviceSN,
+				Code:        code,
+				PlantName:   plantName,
+				Name:        plantName,
+				Alert:       code,
+				Description: fmt.Sprintf("Kstar,%s,%s,%s", plantID, deviceID, deviceName),
+				Severity:    severity,
+				AlarmTime:   alarmTime,
+			}
+		}
 
-				var keys []string
-				var cursor uint64
-				for {
-					var scanKeys []string
-					match := fmt.Sprintf("Kstar,%s,%s,%s,*", plantID, deviceID, deviceName)
-					scanKeys, cursor, err = s.rdb.Scan(ctx, cursor, match, 10).Result()
-					if err != nil {
-						s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to scan redis keys")
-						return err
-					}
-
-					keys = append(keys, scanKeys...)
-					if cursor == 0 {
-						break
-					}
-				}
+		switch *device.Status {
+		case 0:
+			if _, err := tracker.raise(ctx, newState("Kstar-Disconnect", saveTime, severities.Resolve(SeverityKey{Vendor: model.VendorTypeKstar, Code: "Disconnect", Name: "Kstar-Disconnect"}))); err != nil {
+				s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to raise alarm")
+				return err
+			}
+		case 1, 2:
+			realtimeAlarmResp, err := client.GetRealtimeAlarmListOfDevice(deviceID)
+			if err != nil {
+				s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to get realtime alarm list of device")
+				return err
+			}
 
-				for _, key := range keys {
-					val, err := s.rdb.Get(ctx, key).Result()
-					if err != nil {
-						s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to get redis key")
-						if err != redis.Nil {
-							return err
-						}
-						continue
-					}
-
-					if !util.IsEmpty(val) {
-						splitKey := strings.Split(key, ",")
-						splitVal := strings.Split(val, ",")
-
-						plantName := splitVal[0]
-						alarmName := strings.ReplaceAll(splitKey[4], " ", "-")
-						payload := fmt.Sprintf("Kstar,%s,%s,%s", plantID, deviceID, deviceName)
-						document = model.NewSnmpAlarmItem(s.vendorType, plantName, alarmName, payload, infra.ClearSeverity, splitVal[1])
-						s.snmp.SendTrap(plantName, alarmName, payload, infra.ClearSeverity, splitVal[1])
-
-						if err := s.rdb.Del(ctx, key).Err(); err != nil {
-							s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to delete redis key")
-							return err
-						}
-					}
-				}
-			case 2:
-				realtimeAlarmResp, err := client.GetRealtimeAlarmListOfDevice(deviceID)
-				if err != nil {
-					s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to get realtime alarm list of device")
+			codes := make([]string, 0, len(realtimeAlarmResp.Data))
+			for _, alarm := range realtimeAlarmResp.Data {
+				alarmTime := pointy.StringValue(alarm.SaveTime, "")
+				alarmMessage := strings.ReplaceAll(pointy.StringValue(alarm.Message, ""), " ", "-")
+				severity := severities.Resolve(SeverityKey{Vendor: model.VendorTypeKstar, Name: pointy.StringValue(alarm.Message, ""), Level: alarm.ErrorLevel})
+				if _, err := tracker.raise(ctx, newState(alarmMessage, alarmTime, severity)); err != nil {
+					s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to raise alarm")
 					return err
 				}
+				codes = append(codes, alarmMessage)
+			}
 
-				if len(realtimeAlarmResp.Data) > 0 {
-					for _, alarm := range realtimeAlarmResp.Data {
-						alarmTime := pointy.StringValue(alarm.SaveTime, "")
-						alarmMessage := strings.ReplaceAll(pointy.StringValue(alarm.Message, ""), " ", "-")
-
-						key := fmt.Sprintf("Kstar,%s,%s,%s,%s", plantID, deviceID, deviceName, alarmMessage)
-						val := fmt.Sprintf("%s,%s", plantName, alarmTime)
-						if err := s.rdb.Set(ctx, key, val, 0).Err(); err != nil {
-							s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to set redis key")
-							return err
-						}
-
-						payload := fmt.Sprintf("Kstar,%s,%s,%s", plantID, deviceID, deviceName)
-						document = model.NewSnmpAlarmItem(s.vendorType, plantName, alarmMessage, payload, infra.MajorSeverity, alarmTime)
-						s.snmp.SendTrap(plantName, alarmMessage, payload, infra.MajorSeverity, alarmTime)
-					}
+			// An online device is connected again and the realtime list holds
+			// every alarm it still has, the others are cleared.
+			if *device.Status == 1 {
+				if _, err := tracker.clear(ctx, deviceKey, codes...); err != nil {
+					s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to clear alarms")
+					return err
 				}
-			default:
 			}
-			documents = append(documents, document)
+		default:
 		}
 	}
 
 	index := fmt.Sprintf("%s-%s", model.AlarmIndex, time.Now().Format("2006.01.02"))
-	if err := s.solarRepo.BulkIndex(index, documents); err != nil {
+	documents := tracker.documents
+	if _, err := s.solarRepo.BulkIndex(index, documents); err != nil {
 		s.logger.Error().Err(err).Msg("KstarAlarm::Run() - failed to bulk index")
 		return err
 	}
@@ -0,0 +1,254 @@
+package alarm_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/api/fake"
+	"github.com/HavvokLab/true-solar/api/kstar"
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// kstarDevice is the faulty inverter of the Kstar fixtures.
+var kstarDevice = alarm.DeviceKey{Vendor: model.VendorTypeKstar, Plant: "P4001", Device: "D4102"}
+
+// memoryRepo is the SolarRepo of repo.NewSolarMemoryRepo.
+type memoryRepo interface {
+	repo.SolarRepo
+	Documents(index string) []interface{}
+}
+
+// kstarAlarmEnv runs the Kstar alarm handler against the fake Kstar server,
+// an in-memory Redis, a migrated SQLite store and the memory SolarRepo. The
+// SNMP orchestrator has no targets, the traps are checked through the alarm
+// documents.
+type kstarAlarmEnv struct {
+	server    *fake.Server
+	solarRepo memoryRepo
+	db        *gorm.DB
+	state     *alarm.StateStore
+	handler   *alarm.KstarAlarm
+
+	mu      sync.Mutex
+	devices map[string]int
+	alarms  map[string][]string
+}
+
+func newKstarAlarmEnv(t *testing.T) *kstarAlarmEnv {
+	t.Helper()
+	env := &kstarAlarmEnv{
+		server:  fake.NewKstarServer(),
+		devices: map[string]int{"D4101": 1, "D4102": 2},
+		alarms:  map[string][]string{"D4102": {"Grid Overvoltage"}},
+	}
+	t.Cleanup(env.server.Close)
+	env.server.HandleFunc("/inverter/list", env.serveDeviceList)
+	env.server.HandleFunc("/alarm/device/list", env.serveAlarmList)
+
+	db := newStore(t)
+	redisServer := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeKstarAlarm, nil)
+	if err != nil {
+		t.Fatalf("create snmp orchestrator: %v", err)
+	}
+
+	env.db = db
+	env.solarRepo = repo.NewSolarMemoryRepo()
+	env.state = alarm.NewStateStore(rdb, config.AlarmConfig{})
+	env.handler = alarm.NewKstarAlarm(
+		env.solarRepo,
+		repo.NewSiteRegionMappingRepo(db),
+		repo.NewAlarmSeverityMappingRepo(db),
+		repo.NewMaintenanceWindowRepo(db),
+		snmp,
+		env.state,
+		kstar.WithBaseURL(env.server.URL),
+	)
+
+	return env
+}
+
+func (e *kstarAlarmEnv) serveDeviceList(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := ""
+	for _, id := range []string{"D4101", "D4102"} {
+		if list != "" {
+			list += ","
+		}
+		list += fmt.Sprintf(`{"deviceId":%q,"inverterId":"KSINV%s","deviceName":"KSINV%s","deviceStatus":%d,"powerId":"P4001","powerName":"CNX01-AN-1P-5.00","saveTime":"2024-01-15 12:00:00"}`,
+			id, id[1:], id[1:], e.devices[id])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"meta":{"success":true,"code":"0"},"data":{"code":0,"count":2,"list":[%s]}}`, list)
+}
+
+func (e *kstarAlarmEnv) serveAlarmList(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	deviceID := r.URL.Query().Get("deviceId")
+	list := ""
+	for _, message := range e.alarms[deviceID] {
+		if list != "" {
+			list += ","
+		}
+		list += fmt.Sprintf(`{"deviceId":%q,"deviceName":"KSINV%s","saveTime":"2024-01-15 11:30:00","message":%q,"errorLevel":2,"powerId":"P4001","powerName":"CNX01-AN-1P-5.00"}`,
+			deviceID, deviceID[1:], message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"meta":{"success":true,"code":"0"},"data":[%s]}`, list)
+}
+
+// set replaces the status and the realtime alarms of a device.
+func (e *kstarAlarmEnv) set(deviceID string, status int, alarms ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.devices[deviceID] = status
+	e.alarms[deviceID] = alarms
+}
+
+// run runs the handler and returns the alarm documents it indexed.
+func (e *kstarAlarmEnv) run(t *testing.T) []model.SnmpAlarmItem {
+	t.Helper()
+	index := fmt.Sprintf("%s-%s", model.AlarmIndex, time.Now().Format("2006.01.02"))
+	before := len(e.solarRepo.Documents(index))
+	if err := e.handler.Run(&model.KstarCredential{Username: "fake", Password: "fake"}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	docs := e.solarRepo.Documents(index)
+	items := make([]model.SnmpAlarmItem, 0, len(docs)-before)
+	for _, doc := range docs[before:] {
+		item, ok := doc.(model.SnmpAlarmItem)
+		if !ok {
+			t.Fatalf("alarm document %T, want model.SnmpAlarmItem", doc)
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (e *kstarAlarmEnv) active(t *testing.T) []alarm.AlarmState {
+	t.Helper()
+	states, err := e.state.Active(context.Background(), kstarDevice)
+	if err != nil {
+		t.Fatalf("Active() error = %v", err)
+	}
+
+	return states
+}
+
+func assertTraps(t *testing.T, got []model.SnmpAlarmItem, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("traps = %+v, want %d with severities %v", got, len(want), want)
+	}
+
+	for i, item := range got {
+		if item.AlertName != "Grid-Overvoltage" || item.DeviceName != "CNX01-AN-1P-5.00" || item.Severity != want[i] {
+			t.Errorf("trap %d = %+v, want Grid-Overvoltage of CNX01-AN-1P-5.00 with severity %s", i, item, want[i])
+		}
+	}
+}
+
+func TestKstarAlarmRaiseRepeatClear(t *testing.T) {
+	env := newKstarAlarmEnv(t)
+
+	assertTraps(t, env.run(t), infra.MajorSeverity)
+	if states := env.active(t); len(states) != 1 || states[0].Code != "Grid-Overvoltage" || states[0].DeviceSN != "KSINV4102" {
+		t.Fatalf("active = %+v, want Grid-Overvoltage of KSINV4102", states)
+	}
+
+	assertTraps(t, env.run(t))
+
+	env.set("D4102", 1)
+	assertTraps(t, env.run(t), infra.ClearSeverity)
+	if states := env.active(t); len(states) != 0 {
+		t.Fatalf("active = %+v, want none", states)
+	}
+}
+
+func TestKstarAlarmRunPanicFails(t *testing.T) {
+	env := newKstarAlarmEnv(t)
+
+	// Without a state store the run panics on the first alarm observed.
+	handler := alarm.NewKstarAlarm(
+		env.solarRepo,
+		repo.NewSiteRegionMappingRepo(env.db),
+		repo.NewAlarmSeverityMappingRepo(env.db),
+		repo.NewMaintenanceWindowRepo(env.db),
+		nil,
+		nil,
+		kstar.WithBaseURL(env.server.URL),
+	)
+
+	err := handler.Run(&model.KstarCredential{Username: "fake", Password: "fake"})
+	if err == nil || !strings.Contains(err.Error(), "panic") {
+		t.Fatalf("Run() error = %v, want the panic", err)
+	}
+}
+
+func TestKstarAlarmMaintenanceWindow(t *testing.T) {
+	env := newKstarAlarmEnv(t)
+	now := time.Now()
+	window := &model.MaintenanceWindow{SiteID: "CNX01", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour), Reason: "inverter swap"}
+	maintenanceRepo := repo.NewMaintenanceWindowRepo(env.db)
+	if err := maintenanceRepo.Create(window); err != nil {
+		t.Fatalf("create maintenance window: %v", err)
+	}
+
+	// The held back raise is recorded, the alarm is kept silenced.
+	assertTraps(t, env.run(t), infra.MajorSeverity)
+	if states := env.active(t); len(states) != 1 || !states[0].Silenced {
+		t.Fatalf("active = %+v, want one silenced alarm", states)
+	}
+
+	if err := maintenanceRepo.Delete(window.ID); err != nil {
+		t.Fatalf("delete maintenance window: %v", err)
+	}
+
+	// Still active once the window is gone, the raise trap is sent.
+	assertTraps(t, env.run(t), infra.MajorSeverity)
+	if states := env.active(t); len(states) != 1 || states[0].Silenced {
+		t.Fatalf("active = %+v, want one notified alarm", states)
+	}
+}
+
+func TestKstarAlarmMaintenanceWindowClearNeverRaised(t *testing.T) {
+	env := newKstarAlarmEnv(t)
+	now := time.Now()
+	window := &model.MaintenanceWindow{Vendor: model.VendorTypeKstar, DeviceSN: "KSINV4102", StartAt: now.Add(-time.Hour), EndAt: now.Add(time.Hour), Reason: "inverter swap"}
+	if err := repo.NewMaintenanceWindowRepo(env.db).Create(window); err != nil {
+		t.Fatalf("create maintenance window: %v", err)
+	}
+
+	assertTraps(t, env.run(t), infra.MajorSeverity)
+
+	// The NMS never saw the alarm raised, it gets no clear trap.
+	env.set("D4102", 1)
+	assertTraps(t, env.run(t))
+	if states := env.active(t); len(states) != 0 {
+		t.Fatalf("active = %+v, want none", states)
+	}
+}
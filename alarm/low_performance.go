@@ -170,7 +170,7 @@ func (p LowPerformanceAlarm) Run() error {
 	}
 
 	index := fmt.Sprintf("%s-%s", model.PerformanceAlarmIndex, now.Format("2006.01.02"))
-	if err := p.solarRepo.BulkIndex(index, documents); err != nil {
+	if _, err := p.solarRepo.BulkIndex(index, documents); err != nil {
 		p.logger.Error().Err(err).Msg("LowPerformanceAlarm::Run() - failed to bulk index")
 		return err
 	}
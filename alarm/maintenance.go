@@ -0,0 +1,106 @@
+package alarm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/util"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+// Maintenance tells which alarms the maintenance windows open during a
+// handler run cover. The nil Maintenance covers none.
+type Maintenance struct {
+	windows     []model.MaintenanceWindow
+	siteRegions []model.SiteRegionMapping
+}
+
+func NewMaintenance(windows []model.MaintenanceWindow, siteRegions []model.SiteRegionMapping) *Maintenance {
+	return &Maintenance{windows: windows, siteRegions: siteRegions}
+}
+
+// LoadMaintenance reads the windows open at now, and the site regions when a
+// window is scoped by area. On error the returned Maintenance covers no alarm.
+func LoadMaintenance(maintenanceRepo repo.MaintenanceWindowRepo, siteRegionRepo repo.SiteRegionMappingRepo, now time.Time) (*Maintenance, error) {
+	windows, err := maintenanceRepo.FindActive(now)
+	if err != nil {
+		return nil, err
+	}
+
+	var siteRegions []model.SiteRegionMapping
+	for _, window := range windows {
+		if window.Area == "" {
+			continue
+		}
+
+		siteRegions, err = siteRegionRepo.GetSiteRegionMappings()
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	return NewMaintenance(windows, siteRegions), nil
+}
+
+// Covers tells whether an open window scopes alarm. The site ID and area come
+// from the plant name, falling back to the plant ID, as the collectors parse
+// them.
+func (m *Maintenance) Covers(alarm AlarmState) bool {
+	if m == nil {
+		return false
+	}
+
+	for _, window := range m.windows {
+		if m.covers(window, alarm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Maintenance) covers(window model.MaintenanceWindow, alarm AlarmState) bool {
+	if window.Vendor != "" && !strings.EqualFold(window.Vendor, alarm.Vendor) {
+		return false
+	}
+
+	if window.Plant != "" && !strings.EqualFold(window.Plant, alarm.Plant) && !strings.EqualFold(window.Plant, alarm.PlantName) {
+		return false
+	}
+
+	deviceSN := alarm.DeviceSN
+	if deviceSN == "" {
+		deviceSN = alarm.Device
+	}
+	if window.DeviceSN != "" && !strings.EqualFold(window.DeviceSN, deviceSN) {
+		return false
+	}
+
+	if window.SiteID == "" && window.Area == "" {
+		return true
+	}
+
+	for _, plant := range []string{alarm.PlantName, alarm.Plant} {
+		id, err := util.ParsePlantID(plant)
+		if err != nil || id.SiteID == "" {
+			continue
+		}
+
+		if window.SiteID != "" && !strings.EqualFold(window.SiteID, id.SiteID) {
+			continue
+		}
+
+		if window.Area != "" {
+			_, _, area := util.ParseSiteID(m.siteRegions, id.SiteID)
+			if !strings.EqualFold(window.Area, area) {
+				continue
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,320 @@
+package alarm_test
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/gosnmp/gosnmp"
+	"go.openly.dev/pointy"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// trap is the content of a trap sent by infra.SnmpClient.
+type trap struct {
+	DeviceName  string
+	AlertName   string
+	Description string
+	Severity    string
+}
+
+// trapReceiver listens on a local UDP port and decodes the SNMP traps sent
+// to it.
+type trapReceiver struct {
+	conn  net.PacketConn
+	mu    sync.Mutex
+	traps []trap
+}
+
+func newTrapReceiver(t *testing.T) *trapReceiver {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+
+	r := &trapReceiver{conn: conn}
+	t.Cleanup(func() { _ = conn.Close() })
+	go r.serve(t)
+
+	return r
+}
+
+func (r *trapReceiver) serve(t *testing.T) {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		packet, err := gosnmp.Default.UnmarshalTrap(buf[:n], false)
+		if err != nil {
+			t.Errorf("decode trap: %v", err)
+			continue
+		}
+
+		values := make(map[string]string)
+		for _, pdu := range packet.Variables {
+			if value, ok := pdu.Value.([]byte); ok {
+				values[strings.TrimPrefix(pdu.Name, ".1.3.6.1.4.1.30378.2.")] = string(value)
+			}
+		}
+
+		r.mu.Lock()
+		r.traps = append(r.traps, trap{DeviceName: values["2"], AlertName: values["3"], Description: values["4"], Severity: values["5"]})
+		r.mu.Unlock()
+	}
+}
+
+// orchestrator returns an orchestrator sending to the receiver.
+func (r *trapReceiver) orchestrator(t *testing.T, trapType infra.TrapType) *infra.SnmpOrchestrator {
+	t.Helper()
+	addr := r.conn.LocalAddr().(*net.UDPAddr)
+	snmp, err := infra.NewSnmpOrchestrator(trapType, []config.SnmpConfig{{AgentHost: "127.0.0.1", TargetHost: addr.IP.String(), TargetPort: addr.Port}})
+	if err != nil {
+		t.Fatalf("create snmp orchestrator: %v", err)
+	}
+
+	return snmp
+}
+
+// wait returns the traps received once want of them arrived, or after a
+// short grace period when fewer were sent.
+func (r *trapReceiver) wait(want int) []trap {
+	deadline := time.Now().Add(2 * time.Second)
+	if want == 0 {
+		deadline = time.Now().Add(200 * time.Millisecond)
+	}
+
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		n := len(r.traps)
+		r.mu.Unlock()
+		if want > 0 && n >= want {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]trap(nil), r.traps...)
+}
+
+// newStore returns a migrated SQLite store, with the seeded installed
+// capacity (efficiency 0.8, 4 hours) and performance alarm configs.
+func newStore(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "database.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	return db
+}
+
+// dailyPlant is the plant document collected daysAgo days before today.
+func dailyPlant(daysAgo int, vendorType, id, name string, daily, capacity float64) model.PlantItem {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return model.PlantItem{
+		Timestamp:         today.AddDate(0, 0, -daysAgo).Add(6 * time.Hour),
+		VendorType:        vendorType,
+		DataType:          model.DataTypePlant,
+		ID:                pointy.String(id),
+		Name:              pointy.String(name),
+		DailyProduction:   pointy.Float64(daily),
+		InstalledCapacity: pointy.Float64(capacity),
+		Owner:             string(model.OwnerTrue),
+	}
+}
+
+// indexDays indexes the plant documents of the given days ago into their
+// daily solarcell index.
+func indexDays(t *testing.T, solarRepo repo.SolarRepo, vendorType, id, name string, daily, capacity float64, daysAgo ...int) {
+	t.Helper()
+	for _, days := range daysAgo {
+		doc := dailyPlant(days, vendorType, id, name, daily, capacity)
+		index := fmt.Sprintf("%s-%s", model.SolarIndex, doc.Timestamp.Format("2006.01.02"))
+		if _, err := solarRepo.BulkIndex(index, []interface{}{doc}); err != nil {
+			t.Fatalf("BulkIndex(%s) error = %v", index, err)
+		}
+	}
+}
+
+// periods returns the accepted Period of a description, the run may cross
+// midnight.
+func periods(before, after time.Time, duration int) []string {
+	period := func(now time.Time) string {
+		return fmt.Sprintf("%s - %s", now.AddDate(0, 0, -duration).Format("02Jan2006"), now.AddDate(0, 0, -1).Format("02Jan2006"))
+	}
+
+	return []string{period(before), period(after)}
+}
+
+// performanceAlarms returns the documents of today's performance alarm index.
+func performanceAlarms(t *testing.T, solarRepo memoryRepo) []model.SnmpPerformanceAlarmItem {
+	t.Helper()
+	index := fmt.Sprintf("%s-%s", model.PerformanceAlarmIndex, time.Now().Format("2006.01.02"))
+	items := make([]model.SnmpPerformanceAlarmItem, 0)
+	for _, doc := range solarRepo.Documents(index) {
+		item, ok := doc.(model.SnmpPerformanceAlarmItem)
+		if !ok {
+			t.Fatalf("performance alarm document %T, want model.SnmpPerformanceAlarmItem", doc)
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func assertPerformanceTrap(t *testing.T, got []trap, want trap, periods []string) {
+	t.Helper()
+	if len(got) != 1 {
+		t.Fatalf("traps = %+v, want one", got)
+	}
+
+	for _, period := range periods {
+		if got[0] == (trap{want.DeviceName, want.AlertName, want.Description + period, want.Severity}) {
+			return
+		}
+	}
+
+	t.Errorf("trap = %+v, want %+v with period %s", got[0], want, periods[0])
+}
+
+func TestLowPerformanceAlarm(t *testing.T) {
+	t.Parallel()
+	db := newStore(t)
+	receiver := newTrapReceiver(t)
+	solarRepo := repo.NewSolarMemoryRepo()
+
+	// capacity 10 kW * 0.8 * 4 hours is 32 kWh a day, 60% of it is 19.2 kWh.
+	indexDays(t, solarRepo, model.VendorTypeGrowatt, "G1", "BKK01-LOW", 19.2, 10, 1, 2, 3, 5, 7)
+	indexDays(t, solarRepo, model.VendorTypeGrowatt, "G1", "BKK01-LOW", 19.3, 10, 4, 6)
+	// Low for 4 of the 7 days, below the hit day of 5.
+	indexDays(t, solarRepo, model.VendorTypeHuawei, "H1", "CNX01-FEW", 5, 10, 1, 2, 3, 4)
+	indexDays(t, solarRepo, model.VendorTypeHuawei, "H1", "CNX01-FEW", 30, 10, 5, 6, 7)
+	// Low every day but before the 7 day duration.
+	indexDays(t, solarRepo, model.VendorTypeKstar, "K1", "KKN01-OLD", 1, 10, 8, 9, 10, 11, 12)
+
+	handler := alarm.NewLowPerformanceAlarm(
+		solarRepo,
+		repo.NewInstalledCapacityRepo(db),
+		repo.NewPerformanceAlarmConfigRepo(db),
+		receiver.orchestrator(t, infra.TrapTypePerformanceAlarm),
+	)
+
+	before := time.Now()
+	if err := handler.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := trap{
+		DeviceName:  "BKK01-LOW",
+		AlertName:   "SolarCell-PerformanceLow",
+		Description: "Growatt, Performance Low, Less than or equal 60.00%, Expected Daily Production:32.00 KWH, Actual Production less than:19.20 KWH, Duration:5 days, Period:",
+		Severity:    infra.MajorSeverity,
+	}
+	periods := periods(before, time.Now(), 7)
+	assertPerformanceTrap(t, receiver.wait(1), want, periods)
+
+	docs := performanceAlarms(t, solarRepo)
+	if len(docs) != 1 || docs[0].Type != "low" || docs[0].DeviceName != want.DeviceName || docs[0].AlertName != want.AlertName || docs[0].Severity != want.Severity ||
+		(docs[0].Description != want.Description+periods[0] && docs[0].Description != want.Description+periods[1]) {
+		t.Errorf("performance alarm documents = %+v, want one low alarm of %s", docs, want.DeviceName)
+	}
+}
+
+func TestSumPerformanceAlarm(t *testing.T) {
+	t.Parallel()
+	db := newStore(t)
+	receiver := newTrapReceiver(t)
+	solarRepo := repo.NewSolarMemoryRepo()
+
+	// capacity 10 kW * 0.8 * 4 hours * 30 days is 960 kWh, 50% of it is 480 kWh.
+	lowDays := make([]int, 0, 20)
+	for day := 1; day <= 20; day++ {
+		lowDays = append(lowDays, day)
+	}
+	indexDays(t, solarRepo, model.VendorTypeKstar, "K1", "CNX01-SUM", 24, 10, lowDays...)
+	// 480 kWh over 30 days is still low, 481 kWh is not.
+	indexDays(t, solarRepo, model.VendorTypeHuawei, "H1", "BKK01-EDGE", 24, 10, lowDays...)
+	indexDays(t, solarRepo, model.VendorTypeSolarman, "S1", "KKN01-OK", 24.05, 10, lowDays...)
+	// Before the 30 day duration.
+	indexDays(t, solarRepo, model.VendorTypeKstar, "K1", "CNX01-SUM", 1, 10, 40)
+
+	handler := alarm.NewSumPerformanceAlarm(
+		solarRepo,
+		repo.NewInstalledCapacityRepo(db),
+		repo.NewPerformanceAlarmConfigRepo(db),
+		receiver.orchestrator(t, infra.TrapTypeSumPerformanceAlarm),
+	)
+
+	before := time.Now()
+	if err := handler.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := receiver.wait(2)
+	if len(got) != 2 {
+		t.Fatalf("traps = %+v, want two", got)
+	}
+
+	wants := map[string]trap{
+		"CNX01-SUM": {
+			DeviceName:  "CNX01-SUM",
+			AlertName:   "SolarCell-SumPerformanceLow",
+			Description: "Kstar, Sum Performance Low, Less than or equal 50.00%, Expected Production:960.00 KWH, Actual Production:480.00 KWH (less than 480.00 KWH), Duration:30 days, Period:",
+			Severity:    infra.MajorSeverity,
+		},
+		"BKK01-EDGE": {
+			DeviceName:  "BKK01-EDGE",
+			AlertName:   "SolarCell-SumPerformanceLow",
+			Description: "HUA, Sum Performance Low, Less than or equal 50.00%, Expected Production:960.00 KWH, Actual Production:480.00 KWH (less than 480.00 KWH), Duration:30 days, Period:",
+			Severity:    infra.MajorSeverity,
+		},
+	}
+
+	periods := periods(before, time.Now(), 30)
+	for _, item := range got {
+		want, ok := wants[item.DeviceName]
+		if !ok {
+			t.Errorf("unexpected trap %+v", item)
+			continue
+		}
+
+		assertPerformanceTrap(t, []trap{item}, want, periods)
+		delete(wants, item.DeviceName)
+	}
+
+	docs := performanceAlarms(t, solarRepo)
+	if len(docs) != 2 {
+		t.Fatalf("performance alarm documents = %+v, want two", docs)
+	}
+
+	for _, doc := range docs {
+		if doc.Type != "sum" || doc.Severity != infra.MajorSeverity || (doc.DeviceName != "CNX01-SUM" && doc.DeviceName != "BKK01-EDGE") {
+			t.Errorf("performance alarm document = %+v, want a major sum alarm", doc)
+		}
+	}
+}
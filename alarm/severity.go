@@ -0,0 +1,119 @@
+package alarm
+
+import (
+	"strings"
+
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+// SeverityKey describes a vendor alarm to SeverityTable. Code and Name are
+// the alarm code and name the vendor reports, either may be empty. Level is
+// the vendor severity level, nil when the vendor has none.
+type SeverityKey struct {
+	Vendor string
+	Code   string
+	Name   string
+	Level  *int
+}
+
+// SeverityTable resolves the trap severity of vendor alarms from the rows of
+// tbl_alarm_severity_mapping.
+type SeverityTable struct {
+	mappings []model.AlarmSeverityMapping
+}
+
+func NewSeverityTable(mappings []model.AlarmSeverityMapping) *SeverityTable {
+	return &SeverityTable{mappings: mappings}
+}
+
+// LoadSeverityTable reads the mappings. On error the returned table resolves
+// every alarm to infra.MajorSeverity.
+func LoadSeverityTable(severityRepo repo.AlarmSeverityMappingRepo) (*SeverityTable, error) {
+	mappings, err := severityRepo.FindAll()
+	if err != nil {
+		return NewSeverityTable(nil), err
+	}
+
+	return NewSeverityTable(mappings), nil
+}
+
+// Resolve returns the severity of the most specific mapping matching key,
+// infra.MajorSeverity when none does. A mapping of the vendor beats a wildcard
+// vendor, an exact code beats a pattern, a pattern with more literal
+// characters beats one with fewer and a level beats none. Ties go to the
+// oldest mapping.
+func (t *SeverityTable) Resolve(key SeverityKey) string {
+	severity := infra.MajorSeverity
+	best := -1
+	for _, mapping := range t.mappings {
+		rank, ok := matchSeverity(mapping, key)
+		if ok && rank > best {
+			best = rank
+			severity = mapping.Severity
+		}
+	}
+
+	return severity
+}
+
+// matchSeverity tells whether mapping matches key and ranks how specific it
+// is, see Resolve.
+func matchSeverity(mapping model.AlarmSeverityMapping, key SeverityKey) (int, bool) {
+	rank := 0
+	switch {
+	case strings.EqualFold(mapping.Vendor, key.Vendor):
+		rank += 1 << 20
+	case mapping.Vendor != model.AlarmSeverityWildcard:
+		return 0, false
+	}
+
+	if mapping.Level != nil {
+		if key.Level == nil || *mapping.Level != *key.Level {
+			return 0, false
+		}
+		rank++
+	}
+
+	literals := len(strings.ReplaceAll(mapping.Code, model.AlarmSeverityWildcard, ""))
+	if !strings.Contains(mapping.Code, model.AlarmSeverityWildcard) {
+		rank += 1 << 19
+	}
+	rank += min(literals, 1<<16) << 1
+
+	for _, value := range []string{key.Code, key.Name} {
+		if value != "" && matchWildcard(mapping.Code, value) {
+			return rank, true
+		}
+	}
+
+	// A bare wildcard also matches alarms reported without code and name.
+	return rank, mapping.Code == model.AlarmSeverityWildcard
+}
+
+// matchWildcard matches value against pattern ignoring case, where every
+// wildcard stands for any run of characters.
+func matchWildcard(pattern, value string) bool {
+	pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	parts := strings.Split(pattern, model.AlarmSeverityWildcard)
+	if len(parts) == 1 {
+		return pattern == value
+	}
+
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+
+	return len(value) >= len(last) && strings.HasSuffix(value, last)
+}
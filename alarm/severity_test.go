@@ -0,0 +1,126 @@
+package alarm
+
+import (
+	"testing"
+
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"go.openly.dev/pointy"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "Grid Loss", value: "grid loss", want: true},
+		{pattern: "Grid Loss", value: "Grid Loss 2", want: false},
+		{pattern: "*", value: "anything", want: true},
+		{pattern: "Grid*", value: "Grid Overvoltage", want: true},
+		{pattern: "Grid*", value: "Off Grid", want: false},
+		{pattern: "*Loss", value: "Grid Loss", want: true},
+		{pattern: "*Loss", value: "Loss of Grid", want: false},
+		{pattern: "*volt*", value: "Grid Overvoltage", want: true},
+		{pattern: "a*b*c", value: "axxbyyc", want: true},
+		{pattern: "a*b*c", value: "acb", want: false},
+		// The prefix and suffix must not overlap.
+		{pattern: "ab*ba", value: "aba", want: false},
+		{pattern: "ab*ba", value: "abba", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := matchWildcard(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("matchWildcard(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSeverityTableResolvePrecedence(t *testing.T) {
+	// The severities name the mapping, so a test shows which one won.
+	table := NewSeverityTable([]model.AlarmSeverityMapping{
+		{Vendor: "*", Code: "*", Severity: "any"},
+		{Vendor: "*", Code: "Grid Loss", Severity: "any grid loss"},
+		{Vendor: "huawei", Code: "*", Severity: "huawei"},
+		{Vendor: "huawei", Code: "*", Level: pointy.Int(1), Severity: "huawei level 1"},
+		{Vendor: "huawei", Code: "Grid*", Severity: "huawei grid"},
+		{Vendor: "huawei", Code: "Grid Over*", Severity: "huawei grid over"},
+		{Vendor: "huawei", Code: "2032", Severity: "huawei 2032"},
+		{Vendor: "huawei", Code: "2033", Severity: "huawei 2033 older"},
+		{Vendor: "huawei", Code: "2033", Severity: "huawei 2033 newer"},
+	})
+
+	tests := []struct {
+		name string
+		key  SeverityKey
+		want string
+	}{
+		{name: "exact code over pattern and level", key: SeverityKey{Vendor: "huawei", Code: "2032", Name: "Grid Overvoltage", Level: pointy.Int(1)}, want: "huawei 2032"},
+		{name: "longer pattern over shorter", key: SeverityKey{Vendor: "huawei", Name: "Grid Overvoltage"}, want: "huawei grid over"},
+		{name: "shorter pattern", key: SeverityKey{Vendor: "huawei", Name: "Grid Undervoltage"}, want: "huawei grid"},
+		{name: "vendor over exact code of any vendor", key: SeverityKey{Vendor: "HUAWEI", Name: "grid loss"}, want: "huawei grid"},
+		{name: "level over no level", key: SeverityKey{Vendor: "huawei", Name: "Low Insulation", Level: pointy.Int(1)}, want: "huawei level 1"},
+		{name: "other level", key: SeverityKey{Vendor: "huawei", Name: "Low Insulation", Level: pointy.Int(2)}, want: "huawei"},
+		{name: "exact code of any vendor over wildcard", key: SeverityKey{Vendor: "kstar", Name: "Grid Loss"}, want: "any grid loss"},
+		{name: "wildcard vendor and code", key: SeverityKey{Vendor: "kstar", Name: "Low Insulation"}, want: "any"},
+		{name: "no code and name", key: SeverityKey{Vendor: "growatt"}, want: "any"},
+		{name: "tie to the oldest", key: SeverityKey{Vendor: "huawei", Code: "2033"}, want: "huawei 2033 older"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := table.Resolve(tt.key); got != tt.want {
+				t.Errorf("Resolve(%+v) = %s, want %s", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	if got := NewSeverityTable(nil).Resolve(SeverityKey{Vendor: "huawei", Code: "2032"}); got != infra.MajorSeverity {
+		t.Errorf("Resolve() of an empty table = %s, want %s", got, infra.MajorSeverity)
+	}
+}
+
+func TestSeverityTableSeededDefaults(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	table, err := LoadSeverityTable(repo.NewAlarmSeverityMappingRepo(db))
+	if err != nil {
+		t.Fatalf("LoadSeverityTable() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  SeverityKey
+		want string
+	}{
+		{name: "huawei level 1", key: SeverityKey{Vendor: model.VendorTypeHuawei, Code: "2032", Level: pointy.Int(1)}, want: infra.CriticalSeverity},
+		{name: "huawei level 2", key: SeverityKey{Vendor: model.VendorTypeHuawei, Code: "2032", Level: pointy.Int(2)}, want: infra.MajorSeverity},
+		{name: "huawei level 3", key: SeverityKey{Vendor: model.VendorTypeHuawei, Code: "2032", Level: pointy.Int(3)}, want: infra.MinorSeverity},
+		{name: "huawei level 4", key: SeverityKey{Vendor: model.VendorTypeHuawei, Code: "2032", Level: pointy.Int(4)}, want: infra.WarningSeverity},
+		{name: "huawei disconnect", key: SeverityKey{Vendor: model.VendorTypeHuawei, Code: "Disconnect"}, want: infra.MajorSeverity},
+		{name: "growatt disconnect", key: SeverityKey{Vendor: model.VendorTypeGrowatt, Code: "Disconnect"}, want: infra.MinorSeverity},
+		{name: "growatt alarm", key: SeverityKey{Vendor: model.VendorTypeGrowatt, Code: "101", Name: "No utility"}, want: infra.MajorSeverity},
+		{name: "kstar alarm", key: SeverityKey{Vendor: model.VendorTypeKstar, Name: "Grid Loss", Level: pointy.Int(1)}, want: infra.MajorSeverity},
+		{name: "solarman alarm", key: SeverityKey{Vendor: model.VendorTypeSolarman, Code: "E01", Name: "Grid Loss", Level: pointy.Int(2)}, want: infra.MajorSeverity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := table.Resolve(tt.key); got != tt.want {
+				t.Errorf("Resolve(%+v) = %s, want %s", tt.key, got, tt.want)
+			}
+		})
+	}
+}
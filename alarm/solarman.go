@@ -12,49 +12,69 @@ import (
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
-	"github.com/HavvokLab/true-solar/pkg/util"
 	"github.com/HavvokLab/true-solar/repo"
-	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 type SolarmanAlarm struct {
-	vendorType string
-	solarRepo  repo.SolarRepo
-	snmp       *infra.SnmpOrchestrator
-	rdb        *redis.Client
-	logger     zerolog.Logger
+	vendorType      string
+	solarRepo       repo.SolarRepo
+	siteRegionRepo  repo.SiteRegionMappingRepo
+	severityRepo    repo.AlarmSeverityMappingRepo
+	maintenanceRepo repo.MaintenanceWindowRepo
+	snmp            *infra.SnmpOrchestrator
+	state           *StateStore
+	logger          zerolog.Logger
+	clientOptions   []solarman.Option
 }
 
-func NewSolarmanAlarm(solarRepo repo.SolarRepo, snmp *infra.SnmpOrchestrator, rdb *redis.Client) *SolarmanAlarm {
+func NewSolarmanAlarm(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *StateStore, clientOptions ...solarman.Option) *SolarmanAlarm {
 	return &SolarmanAlarm{
-		vendorType: "INVT-Ipanda",
-		solarRepo:  solarRepo,
-		snmp:       snmp,
-		rdb:        rdb,
-		logger:     zerolog.New(logger.NewWriter("solarman_alarm.log")).With().Timestamp().Caller().Logger(),
+		vendorType:      "INVT-Ipanda",
+		solarRepo:       solarRepo,
+		siteRegionRepo:  siteRegionRepo,
+		severityRepo:    severityRepo,
+		maintenanceRepo: maintenanceRepo,
+		snmp:            snmp,
+		state:           state,
+		logger:          zerolog.New(logger.NewWriter("solarman_alarm.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:   clientOptions,
 	}
 }
 
-func (s *SolarmanAlarm) Run(credential *model.SolarmanCredential) error {
+func (s *SolarmanAlarm) Run(cred model.Credential) (err error) {
+	credential, ok := cred.(*model.SolarmanCredential)
+	if !ok {
+		return invalidCredentialError("SolarmanAlarm", cred)
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
-			s.logger.Warn().Str("username", credential.Username).Any("error", r).Msg("SolarmanAlarm::Run() - failed to run")
+			s.logger.Error().Str("username", credential.Username).Any("recover", r).Msg("SolarmanAlarm::Run() - panic")
+			err = fmt.Errorf("SolarmanAlarm::Run() - panic: %v", r)
 		}
 	}()
 
 	ctx := context.Background()
 	now := time.Now().UTC()
 	beginningOfDay := time.Date(now.Year(), now.Month(), now.Day(), 6, 0, 0, 0, time.Local)
-	documents := make([]interface{}, 0)
+	maintenance, err := LoadMaintenance(s.maintenanceRepo, s.siteRegionRepo, time.Now())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to load maintenance windows, no alarm is silenced")
+	}
+	tracker := newTracker(s.vendorType, s.state, s.snmp, maintenance)
+	severities, err := LoadSeverityTable(s.severityRepo)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to load severity mapping, alarms are major")
+	}
 
 	if credential == nil {
 		s.logger.Error().Msg("credential should not be empty")
 		return errors.New("credential should not be empty")
 	}
 
-	client := solarman.NewSolarmanClient(credential.Username, credential.Password, credential.AppID, credential.AppSecret)
+	client := solarman.NewSolarmanClient(credential.Username, credential.Password, credential.AppID, credential.AppSecret, s.clientOptions...)
 	basicTokenResp, err := client.GetBasicToken()
 	if err != nil {
 		s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to get basic token")
@@ -130,111 +150,65 @@ func (s *SolarmanAlarm) Run(credential *model.SolarmanCredential) error {
 				deviceCollectionTime := pointy.Int64Value(device.CollectionTime, 0)
 				deviceCollectionTimeStr := strconv.FormatInt(deviceCollectionTime, 10)
 
-				if device.ConnectStatus != nil {
-					var document interface{}
-					connectStatus := pointy.IntValue(device.ConnectStatus, -1)
-					switch connectStatus {
-					case 0:
-						rkey := fmt.Sprintf("%s,%d,%s,%s,%d,%s", s.vendorType, stationID, deviceType, deviceSN, deviceID, "Disconnect")
-						val := fmt.Sprintf("%s,%s", stationName, deviceCollectionTimeStr)
-
-						err := s.rdb.Set(ctx, rkey, val, 0).Err()
-						if err != nil {
-							s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to set redis")
-							return err
-						}
+				if device.ConnectStatus == nil {
+					continue
+				}
 
-						name := fmt.Sprintf("%s-%s", stationName, deviceSN)
-						alert := strings.ReplaceAll(fmt.Sprintf("%s-%s", deviceType, "Disconnect"), " ", "-")
-						description := fmt.Sprintf("%s,%d,%s,%d", s.vendorType, stationID, deviceSN, deviceID)
-						document = model.NewSnmpAlarmItem(s.vendorType, name, alert, description, infra.MajorSeverity, deviceCollectionTimeStr)
-						s.snmp.SendTrap(name, alert, description, infra.MajorSeverity, deviceCollectionTimeStr)
-					case 1:
-						var keys []string
-						var cursor uint64
-
-						for {
-							var scanKeys []string
-							match := fmt.Sprintf("%s,%d,%s,%s,%d,*", s.vendorType, stationID, deviceType, deviceSN, deviceID)
-							scanKeys, cursor, err = s.rdb.Scan(ctx, cursor, match, 10).Result()
-							if err != nil {
-								s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to scan redis")
-								return err
-							}
-
-							keys = append(keys, scanKeys...)
-							if cursor == 0 {
-								break
-							}
-						}
+				deviceKey := DeviceKey{Vendor: model.VendorTypeSolarman, Plant: strconv.Itoa(stationID), Device: deviceSN}
+				newState := func(code, alarmTime, severity string) AlarmState {
+					return AlarmState{
+						Vendor:      deviceKey.Vendor,
+						Plant:       deviceKey.Plant,
+						Device:      deviceKey.Device,
+						Code:        code,
+						PlantName:   stationName,
+						Name:        fmt.Sprintf("%s-%s", stationName, deviceSN),
+						Alert:       strings.ReplaceAll(fmt.Sprintf("%s-%s", deviceType, code), " ", "-"),
+						Description: fmt.Sprintf("%s,%d,%s,%d", s.vendorType, stationID, deviceSN, deviceID),
+						Severity:    severity,
+						AlarmTime:   alarmTime,
+					}
+				}
 
-						for _, key := range keys {
-							val, err := s.rdb.Get(ctx, key).Result()
-							if err == redis.Nil {
-								s.logger.Warn().Msg("SolarmanAlarm::Run() - redis key not found")
-								continue
-							}
-
-							if err != nil {
-								s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to get redis key")
-								return err
-							}
-
-							if !util.IsEmpty(val) {
-								splitKey := strings.Split(key, ",")
-								splitVal := strings.Split(val, ",")
-
-								name := fmt.Sprintf("%s-%s", stationName, deviceSN)
-								alert := strings.ReplaceAll(fmt.Sprintf("%s-%s", deviceType, splitKey[5]), " ", "-")
-								description := fmt.Sprintf("%s,%d,%s,%d", s.vendorType, stationID, deviceSN, deviceID)
-								document = model.NewSnmpAlarmItem(s.vendorType, name, alert, description, infra.ClearSeverity, splitVal[1])
-								s.snmp.SendTrap(name, alert, description, infra.ClearSeverity, splitVal[1])
-							}
-
-							if err := s.rdb.Del(ctx, key).Err(); err != nil {
-								s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to delete redis key")
-								return err
-							}
-						}
-					case 2:
-						alertList, err := client.GetDeviceAlertList(deviceSN, beginningOfDay.Unix(), now.Unix())
-						if err != nil {
-							s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to get device alert list")
-							return err
+				switch pointy.IntValue(device.ConnectStatus, -1) {
+				case 0:
+					if _, err := tracker.raise(ctx, newState("Disconnect", deviceCollectionTimeStr, severities.Resolve(SeverityKey{Vendor: model.VendorTypeSolarman, Code: "Disconnect"}))); err != nil {
+						s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to raise alarm")
+						return err
+					}
+				case 1:
+					if _, err := tracker.clear(ctx, deviceKey); err != nil {
+						s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to clear alarms")
+						return err
+					}
+				case 2:
+					alertList, err := client.GetDeviceAlertList(deviceSN, beginningOfDay.Unix(), now.Unix())
+					if err != nil {
+						s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to get device alert list")
+						return err
+					}
+
+					for _, alert := range alertList {
+						if alert.AlertNameInPAAS == nil || alert.AlertTime == nil {
+							continue
 						}
 
-						for _, alert := range alertList {
-							alertName := pointy.StringValue(alert.AlertNameInPAAS, "")
-							alertTime := pointy.Int64Value(alert.AlertTime, 0)
-							alertTimeStr := strconv.FormatInt(alertTime, 10)
-
-							if alert.AlertNameInPAAS != nil && alert.AlertTime != nil {
-								rkey := fmt.Sprintf("%s,%d,%s,%s,%d,%s", s.vendorType, stationID, deviceType, deviceSN, deviceID, alertName)
-								val := fmt.Sprintf("%s,%s", stationName, alertTimeStr)
-
-								err := s.rdb.Set(ctx, rkey, val, 0).Err()
-								if err != nil {
-									s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to set redis")
-									return err
-								}
-
-								name := fmt.Sprintf("%s-%s", stationName, deviceSN)
-								alert := strings.ReplaceAll(fmt.Sprintf("%s-%s", deviceType, alertName), " ", "-")
-								description := fmt.Sprintf("%s,%d,%s,%d", s.vendorType, stationID, deviceSN, deviceID)
-								document = model.NewSnmpAlarmItem(s.vendorType, name, alert, description, infra.MajorSeverity, alertTimeStr)
-								s.snmp.SendTrap(name, alert, description, infra.MajorSeverity, alertTimeStr)
-							}
+						alertTimeStr := strconv.FormatInt(*alert.AlertTime, 10)
+						severity := severities.Resolve(SeverityKey{Vendor: model.VendorTypeSolarman, Code: pointy.StringValue(alert.Code, ""), Name: *alert.AlertNameInPAAS, Level: alert.Level})
+						if _, err := tracker.raise(ctx, newState(*alert.AlertNameInPAAS, alertTimeStr, severity)); err != nil {
+							s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to raise alarm")
+							return err
 						}
-					default:
 					}
-					documents = append(documents, document)
+				default:
 				}
 			}
 		}
 	}
 
 	index := fmt.Sprintf("%s-%s", model.AlarmIndex, time.Now().Format("2006.01.02"))
-	if err := s.solarRepo.BulkIndex(index, documents); err != nil {
+	documents := tracker.documents
+	if _, err := s.solarRepo.BulkIndex(index, documents); err != nil {
 		s.logger.Error().Err(err).Msg("SolarmanAlarm::Run() - failed to bulk index")
 		return err
 	}
@@ -0,0 +1,423 @@
+package alarm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/go-redis/redis/v8"
+)
+
+// stateKeyPrefix starts the Redis keys of the alarm states. Each device has
+// one hash, its fields are the alarm codes and its values the JSON states.
+const stateKeyPrefix = "alarm:state:"
+
+// maxStateRetries bounds the attempts of a state update racing the updates
+// of other runners.
+const maxStateRetries = 10
+
+// Transition is the change of an alarm between two runs of its handler.
+type Transition int
+
+const (
+	// TransitionNone is an observation or miss still within the debounce.
+	TransitionNone Transition = iota
+	// TransitionRaise is an alarm observed that was not active.
+	TransitionRaise
+	// TransitionRepeat is an active alarm observed again unchanged.
+	TransitionRepeat
+	// TransitionChange is an active alarm observed with other trap fields.
+	TransitionChange
+	// TransitionClear is an active alarm no longer observed.
+	TransitionClear
+)
+
+func (t Transition) String() string {
+	switch t {
+	case TransitionNone:
+		return "none"
+	case TransitionRaise:
+		return "raise"
+	case TransitionRepeat:
+		return "repeat"
+	case TransitionChange:
+		return "change"
+	case TransitionClear:
+		return "clear"
+	default:
+		return "unknown"
+	}
+}
+
+// DeviceKey identifies a device of a vendor. Plant and Device are the IDs
+// the vendor API reports, e.g. the plant code and the device SN.
+type DeviceKey struct {
+	Vendor string
+	Plant  string
+	Device string
+}
+
+// redisKey escapes the parts so names with separators cannot collide.
+func (k DeviceKey) redisKey() string {
+	escape := strings.NewReplacer("%", "%25", ":", "%3A")
+	return stateKeyPrefix + escape.Replace(k.Vendor) + ":" + escape.Replace(k.Plant) + ":" + escape.Replace(k.Device)
+}
+
+// AlarmState is an alarm of a device. Code identifies the alarm on its device.
+// Name, Alert, Description, Severity and AlarmTime are the fields of its
+// raise trap, the clear trap repeats them with the clear severity.
+// LastNotifiedAt is when the last raise trap was sent. DeviceSN is the serial
+// number of the device when Device is another ID.
+//
+// A Pending alarm is not raised: it was observed in fewer than the debounce
+// runs, or it was cleared and is kept for flap detection. Hits and Misses
+// count the consecutive runs observing a pending and missing a raised alarm.
+// Transitions are the raise and clear times within the flap window.
+// A Silenced alarm is raised but a maintenance window held back its last
+// raise trap. The NMS never saw it raised when LastNotifiedAt is before
+// RaisedAt, otherwise it only missed a change or renotify.
+type AlarmState struct {
+	Vendor         string      `json:"vendor"`
+	Plant          string      `json:"plant"`
+	Device         string      `json:"device"`
+	DeviceSN       string      `json:"device_sn,omitempty"`
+	Code           string      `json:"code"`
+	PlantName      string      `json:"plant_name"`
+	Name           string      `json:"name"`
+	Alert          string      `json:"alert"`
+	Description    string      `json:"description"`
+	Severity       string      `json:"severity"`
+	AlarmTime      string      `json:"alarm_time"`
+	FirstSeenAt    time.Time   `json:"first_seen_at"`
+	LastSeenAt     time.Time   `json:"last_seen_at"`
+	LastNotifiedAt time.Time   `json:"last_notified_at"`
+	RaisedAt       time.Time   `json:"raised_at"`
+	Pending        bool        `json:"pending,omitempty"`
+	Hits           int         `json:"hits,omitempty"`
+	Misses         int         `json:"misses,omitempty"`
+	Flapping       bool        `json:"flapping,omitempty"`
+	Transitions    []time.Time `json:"transitions,omitempty"`
+	Silenced       bool        `json:"silenced,omitempty"`
+}
+
+func (a AlarmState) DeviceKey() DeviceKey {
+	return DeviceKey{Vendor: a.Vendor, Plant: a.Plant, Device: a.Device}
+}
+
+// sameTrap tells whether b sends the raise trap of a. The alarm time is left
+// out, vendors move it while an alarm lasts.
+func (a AlarmState) sameTrap(b AlarmState) bool {
+	return a.Name == b.Name &&
+		a.Alert == b.Alert &&
+		a.Description == b.Description &&
+		a.Severity == b.Severity
+}
+
+// Update is the outcome of observing or missing an alarm. Notify tells
+// whether the trap of State is due, the raise trap while it is raised and the
+// clear trap once pending. FlapStart and FlapEnd tell whether the alarm
+// started or stopped flapping, no trap of State is due while it flaps.
+// Silenced tells whether a maintenance window held back the raise trap due.
+type Update struct {
+	State      AlarmState
+	Transition Transition
+	Notify     bool
+	FlapStart  bool
+	FlapEnd    bool
+	Silenced   bool
+}
+
+// StateStore keeps the alarms of every handler in Redis.
+type StateStore struct {
+	rdb  *redis.Client
+	conf config.AlarmConfig
+}
+
+func NewStateStore(rdb *redis.Client, conf config.AlarmConfig) *StateStore {
+	return &StateStore{rdb: rdb, conf: conf.WithDefaults()}
+}
+
+// Raise records alarm as observed at now. A new alarm is raised once observed
+// in the debounce runs. New and changed alarms are due for a raise trap,
+// repeated ones only once the renotify interval has passed since their last
+// one. The first seen time is kept across repeats. Silenced tells whether a
+// maintenance window covers the alarm at now, see silence.
+func (s *StateStore) Raise(ctx context.Context, alarm AlarmState, now time.Time, silenced bool) (Update, error) {
+	var update Update
+	err := s.transact(ctx, alarm.DeviceKey().redisKey(), alarm.Code, func(current AlarmState, ok bool) *AlarmState {
+		if !ok {
+			current = AlarmState{Pending: true}
+		}
+
+		next := alarm
+		next.FirstSeenAt = current.FirstSeenAt
+		next.LastSeenAt = now
+		next.LastNotifiedAt = current.LastNotifiedAt
+		next.RaisedAt = current.RaisedAt
+		next.Pending = current.Pending
+		next.Flapping = current.Flapping
+		next.Transitions = s.window(current.Transitions, now)
+		next.Silenced = current.Silenced
+
+		update = Update{}
+		switch {
+		case current.Pending:
+			if current.Hits == 0 {
+				next.FirstSeenAt = now
+			}
+
+			next.Hits = current.Hits + 1
+			if next.Hits >= s.conf.Debounce {
+				next.Pending = false
+				next.Hits = 0
+				next.RaisedAt = now
+				next.Transitions = s.record(next.Transitions, now)
+				update.Transition = TransitionRaise
+				update.Notify = true
+			}
+		case !current.sameTrap(alarm):
+			update.Transition = TransitionChange
+			update.Notify = true
+		default:
+			update.Transition = TransitionRepeat
+			update.Notify = s.conf.RenotifyInterval > 0 && now.Sub(current.LastNotifiedAt) >= s.conf.RenotifyInterval
+		}
+
+		s.flap(&next, &update)
+		s.silence(&next, &update, silenced)
+		if update.Notify && !next.Pending {
+			next.LastNotifiedAt = now
+		}
+
+		update.State = next
+		return &next
+	})
+
+	return update, err
+}
+
+// Miss records a stored alarm as not observed at now. A raised alarm is
+// cleared once missed in the debounce runs and due for a clear trap. Pending
+// alarms are removed once their transitions left the flap window. Silenced
+// is as for Raise. The state is read again, alarm only identifies it; an
+// alarm removed in the meantime is left alone.
+func (s *StateStore) Miss(ctx context.Context, alarm AlarmState, now time.Time, silenced bool) (Update, error) {
+	update := Update{State: alarm}
+	err := s.transact(ctx, alarm.DeviceKey().redisKey(), alarm.Code, func(current AlarmState, ok bool) *AlarmState {
+		update = Update{State: alarm}
+		if !ok {
+			return nil
+		}
+
+		next := current
+		next.Transitions = s.window(current.Transitions, now)
+		if current.Pending {
+			next.Hits = 0
+		} else {
+			next.Misses = current.Misses + 1
+			if next.Misses >= s.conf.Debounce {
+				next.Pending = true
+				next.Misses = 0
+				next.Transitions = s.record(next.Transitions, now)
+				update.Transition = TransitionClear
+				update.Notify = true
+			}
+		}
+
+		s.flap(&next, &update)
+		s.silence(&next, &update, silenced)
+		if update.Notify && !next.Pending {
+			next.LastNotifiedAt = now
+		}
+
+		update.State = next
+		if next.Pending && !next.Flapping && len(next.Transitions) == 0 {
+			return nil
+		}
+
+		return &next
+	})
+
+	return update, err
+}
+
+// transact replaces the state of code under key with the one fn computes
+// from the stored state, nil removing it. The key is watched from the read to
+// the write, so handlers of several runners updating the same device do not
+// overwrite each other; fn runs again on the new state when the key changed.
+func (s *StateStore) transact(ctx context.Context, key, code string, fn func(current AlarmState, ok bool) *AlarmState) error {
+	update := func(tx *redis.Tx) error {
+		current, ok, err := s.get(ctx, tx, key, code)
+		if err != nil {
+			return err
+		}
+
+		next := fn(current, ok)
+		var value []byte
+		if next != nil {
+			if value, err = json.Marshal(next); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if next == nil {
+				pipe.HDel(ctx, key, code)
+			} else {
+				pipe.HSet(ctx, key, code, value)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxStateRetries; attempt++ {
+		err := s.rdb.Watch(ctx, update, key)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("update alarm state %s of %s: %w", code, key, err)
+		}
+	}
+
+	return fmt.Errorf("update alarm state %s of %s: changed concurrently %d times", code, key, maxStateRetries)
+}
+
+// flap starts flapping once the transitions in the window reach the limit and
+// stops once the window has none left. Stopping makes the trap of the state
+// due, the ones held back while flapping may have left the NMS behind.
+func (s *StateStore) flap(next *AlarmState, update *Update) {
+	if s.conf.FlapTransitions <= 0 {
+		return
+	}
+
+	switch {
+	case !next.Flapping && len(next.Transitions) >= s.conf.FlapTransitions:
+		next.Flapping = true
+		update.FlapStart = true
+	case next.Flapping && len(next.Transitions) == 0:
+		next.Flapping = false
+		update.FlapEnd = true
+		update.Notify = true
+	}
+
+	if next.Flapping {
+		update.Notify = false
+	}
+}
+
+// silence holds back the raise traps, the flapping one included, while a
+// maintenance window covers the alarm and marks the alarm silenced. Once no
+// window covers a silenced alarm still raised its raise trap is due, after it
+// stops flapping. A silenced alarm cleared gets no clear trap when the NMS
+// never saw it raised, one raised before the window gets its clear trap.
+func (s *StateStore) silence(next *AlarmState, update *Update, silenced bool) {
+	switch {
+	case next.Pending:
+		if next.Silenced && next.LastNotifiedAt.Before(next.RaisedAt) {
+			update.Notify = false
+		}
+		next.Silenced = false
+	case silenced:
+		if update.Notify {
+			next.Silenced = true
+			update.Silenced = true
+		}
+		update.Notify = false
+		update.FlapStart = false
+	case next.Silenced && !next.Flapping:
+		next.Silenced = false
+		update.Notify = true
+	}
+}
+
+// window drops the transitions older than the flap window.
+func (s *StateStore) window(transitions []time.Time, now time.Time) []time.Time {
+	if s.conf.FlapTransitions <= 0 {
+		return nil
+	}
+
+	kept := make([]time.Time, 0, len(transitions))
+	for _, at := range transitions {
+		if now.Sub(at) < s.conf.FlapWindow {
+			kept = append(kept, at)
+		}
+	}
+
+	return kept
+}
+
+// record adds a transition at now, only kept while flap detection is on.
+func (s *StateStore) record(transitions []time.Time, now time.Time) []time.Time {
+	if s.conf.FlapTransitions <= 0 {
+		return nil
+	}
+
+	return append(transitions, now)
+}
+
+// Active returns the raised alarms of a device, sorted by code.
+func (s *StateStore) Active(ctx context.Context, device DeviceKey) ([]AlarmState, error) {
+	states, err := s.states(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]AlarmState, 0, len(states))
+	for _, state := range states {
+		if !state.Pending {
+			active = append(active, state)
+		}
+	}
+
+	return active, nil
+}
+
+// states returns the raised and pending alarms of a device, sorted by code.
+func (s *StateStore) states(ctx context.Context, device DeviceKey) ([]AlarmState, error) {
+	key := device.redisKey()
+	values, err := s.rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get alarm states of %s: %w", key, err)
+	}
+
+	states := make([]AlarmState, 0, len(values))
+	for code, value := range values {
+		var state AlarmState
+		if err := json.Unmarshal([]byte(value), &state); err != nil {
+			return nil, fmt.Errorf("decode alarm state %s of %s: %w", code, key, err)
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Code < states[j].Code
+	})
+
+	return states, nil
+}
+
+func (s *StateStore) get(ctx context.Context, rdb redis.Cmdable, key, code string) (AlarmState, bool, error) {
+	var state AlarmState
+	value, err := rdb.HGet(ctx, key, code).Result()
+	if err == redis.Nil {
+		return state, false, nil
+	}
+
+	if err != nil {
+		return state, false, fmt.Errorf("get alarm state %s of %s: %w", code, key, err)
+	}
+
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return state, false, fmt.Errorf("decode alarm state %s of %s: %w", code, key, err)
+	}
+
+	return state, true, nil
+}
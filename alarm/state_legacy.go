@@ -0,0 +1,159 @@
+package alarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/go-redis/redis/v8"
+)
+
+// MigrateLegacy moves the comma separated alarm keys the handlers wrote
+// before the state store into alarm states and deletes them. A state written
+// by a handler since is kept. Keys of other formats are left alone. It returns
+// the number of migrated keys.
+//
+// Legacy keys could not tell separators from commas in names, names are
+// recovered from the fixed fields around them. Growatt keys carried no device
+// model, the description of their clear trap lacks it.
+func (s *StateStore) MigrateLegacy(ctx context.Context, now time.Time) (int, error) {
+	migrated := 0
+	var cursor uint64
+	for {
+		keys, next, err := s.rdb.Scan(ctx, cursor, "*,*", 100).Result()
+		if err != nil {
+			return migrated, fmt.Errorf("scan legacy alarm keys: %w", err)
+		}
+
+		for _, key := range keys {
+			value, err := s.rdb.Get(ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			}
+
+			if err != nil {
+				// Not a string, the key belongs to something else.
+				if strings.HasPrefix(err.Error(), "WRONGTYPE") {
+					continue
+				}
+				return migrated, fmt.Errorf("get legacy alarm key %s: %w", key, err)
+			}
+
+			state, ok := parseLegacyState(key, value, now)
+			if !ok {
+				continue
+			}
+
+			encoded, err := json.Marshal(state)
+			if err != nil {
+				return migrated, err
+			}
+
+			if err := s.rdb.HSetNX(ctx, state.DeviceKey().redisKey(), state.Code, encoded).Err(); err != nil {
+				return migrated, fmt.Errorf("set alarm state of legacy key %s: %w", key, err)
+			}
+
+			if err := s.rdb.Del(ctx, key).Err(); err != nil {
+				return migrated, fmt.Errorf("delete legacy alarm key %s: %w", key, err)
+			}
+			migrated++
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return migrated, nil
+		}
+	}
+}
+
+// parseLegacyState reads a legacy key and value into the state the handler
+// would raise today.
+func parseLegacyState(key, value string, now time.Time) (AlarmState, bool) {
+	parts := strings.Split(key, ",")
+	values := strings.Split(value, ",")
+	// Earlier builds sent the raise trap on every run.
+	state := AlarmState{Severity: infra.MajorSeverity, FirstSeenAt: now, LastSeenAt: now, LastNotifiedAt: now, RaisedAt: now}
+
+	switch {
+	case parts[0] == "Huawei":
+		// Huawei,<plant code>,<device SN>,<device name>,<alarm name> =
+		// <plant name>,<cause>,<time>
+		if len(parts) < 5 || len(values) < 3 {
+			return state, false
+		}
+
+		deviceName := strings.Join(parts[3:len(parts)-1], ",")
+		state.Vendor = model.VendorTypeHuawei
+		state.Plant = parts[1]
+		state.Device = parts[2]
+		state.Code = parts[len(parts)-1]
+		state.PlantName = strings.Join(values[:len(values)-2], ",")
+		state.Name = state.PlantName
+		state.Alert = strings.ReplaceAll(fmt.Sprintf("HUW-%s", state.Code), " ", "-")
+		state.Description = fmt.Sprintf("Huawei,%s,%s", deviceName, values[len(values)-2])
+		state.AlarmTime = values[len(values)-1]
+	case parts[0] == "Kstar":
+		// Kstar,<plant id>,<device id>,<device name>,<alarm> = <plant name>,<time>
+		if len(parts) < 5 || len(values) < 2 {
+			return state, false
+		}
+
+		deviceName := strings.Join(parts[3:len(parts)-1], ",")
+		state.Vendor = model.VendorTypeKstar
+		state.Plant = parts[1]
+		state.Device = parts[2]
+		state.Code = parts[len(parts)-1]
+		state.PlantName = strings.Join(values[:len(values)-1], ",")
+		state.Name = state.PlantName
+		state.Alert = state.Code
+		state.Description = fmt.Sprintf("Kstar,%s,%s,%s", state.Plant, state.Device, deviceName)
+		state.AlarmTime = values[len(values)-1]
+	case parts[0] == "INVT-Ipanda":
+		// INVT-Ipanda,<station id>,<device type>,<device SN>,<device id>,<alarm> =
+		// <station name>,<time>
+		if len(parts) < 6 || len(values) < 2 {
+			return state, false
+		}
+
+		deviceType := parts[2]
+		state.Vendor = model.VendorTypeSolarman
+		state.Plant = parts[1]
+		state.Device = parts[3]
+		state.Code = strings.Join(parts[5:], ",")
+		state.PlantName = strings.Join(values[:len(values)-1], ",")
+		state.Name = fmt.Sprintf("%s-%s", state.PlantName, state.Device)
+		state.Alert = strings.ReplaceAll(fmt.Sprintf("%s-%s", deviceType, state.Code), " ", "-")
+		state.Description = fmt.Sprintf("%s,%s,%s,%s", parts[0], state.Plant, state.Device, parts[4])
+		state.AlarmTime = values[len(values)-1]
+	default:
+		// Growatt: <plant id>,<plant name>,<device type>,<device SN> =
+		// <alarm code>,<message>
+		plantID, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) < 4 || len(values) < 2 {
+			return state, false
+		}
+
+		deviceType := parts[len(parts)-2]
+		message := strings.Join(values[1:], ",")
+		state.Vendor = model.VendorTypeGrowatt
+		state.Plant = parts[0]
+		state.Device = parts[len(parts)-1]
+		state.Code = values[0]
+		state.PlantName = strings.Join(parts[1:len(parts)-2], ",")
+		state.Name = fmt.Sprintf("%s_%d_%s", state.PlantName, plantID, state.Device)
+		state.Alert = fmt.Sprintf("%s-Error-%s", deviceType, values[0])
+		state.Description = fmt.Sprintf("Growatt,%s,", message)
+		state.AlarmTime = now.Format("2006-01-02")
+		if values[0] == "0" && message == "Disconnect" {
+			state.Code = "Disconnect"
+			state.Severity = infra.MinorSeverity
+		}
+	}
+
+	return state, true
+}
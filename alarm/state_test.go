@@ -0,0 +1,245 @@
+package alarm_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// stateDevice is the device of the alarms recorded by the state tests.
+var stateDevice = alarm.DeviceKey{Vendor: model.VendorTypeHuawei, Plant: "NE=1001", Device: "HWINV0001"}
+
+func newStateStore(t *testing.T, conf config.AlarmConfig) *alarm.StateStore {
+	t.Helper()
+	redisServer := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	return alarm.NewStateStore(rdb, conf)
+}
+
+func stateAlarm(code string) alarm.AlarmState {
+	return alarm.AlarmState{
+		Vendor:      stateDevice.Vendor,
+		Plant:       stateDevice.Plant,
+		Device:      stateDevice.Device,
+		Code:        code,
+		Name:        "BKK02-AN-1P-5.00",
+		Alert:       code,
+		Description: "Huawei, " + code,
+		Severity:    "2",
+	}
+}
+
+func TestStateStoreRaiseConcurrent(t *testing.T) {
+	const runners = 8
+	store := newStateStore(t, config.AlarmConfig{Debounce: runners})
+	now := time.Now()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		raises  int
+		errList []error
+	)
+	for i := 0; i < runners; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update, err := store.Raise(context.Background(), stateAlarm("Grid Loss"), now, false)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errList = append(errList, err)
+			}
+			if update.Transition == alarm.TransitionRaise {
+				raises++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errList) > 0 {
+		t.Fatalf("Raise() errors = %v", errList)
+	}
+
+	// Every observation counts once, the last one reaches the debounce.
+	if raises != 1 {
+		t.Fatalf("raises = %d, want 1", raises)
+	}
+
+	if states, err := store.Active(context.Background(), stateDevice); err != nil || len(states) != 1 {
+		t.Fatalf("Active() = %+v, %v, want the raised alarm", states, err)
+	}
+}
+
+// step is one run of a handler: the alarm is observed at at, or missed.
+type step struct {
+	at        time.Duration
+	miss      bool
+	want      alarm.Transition
+	notify    bool
+	flapStart bool
+	flapEnd   bool
+}
+
+// runSteps plays steps on the Grid Loss alarm from start and checks the
+// update of every run.
+func runSteps(t *testing.T, store *alarm.StateStore, start time.Time, steps []step) []alarm.Update {
+	t.Helper()
+	updates := make([]alarm.Update, 0, len(steps))
+	for i, s := range steps {
+		now := start.Add(s.at)
+		var update alarm.Update
+		var err error
+		if s.miss {
+			update, err = store.Miss(context.Background(), stateAlarm("Grid Loss"), now, false)
+		} else {
+			update, err = store.Raise(context.Background(), stateAlarm("Grid Loss"), now, false)
+		}
+		if err != nil {
+			t.Fatalf("step %d: error = %v", i, err)
+		}
+
+		if update.Transition != s.want || update.Notify != s.notify || update.FlapStart != s.flapStart || update.FlapEnd != s.flapEnd {
+			t.Fatalf("step %d: update = %s notify %t flap start %t end %t, want %s notify %t flap start %t end %t",
+				i, update.Transition, update.Notify, update.FlapStart, update.FlapEnd, s.want, s.notify, s.flapStart, s.flapEnd)
+		}
+		updates = append(updates, update)
+	}
+
+	return updates
+}
+
+func activeCount(t *testing.T, store *alarm.StateStore) int {
+	t.Helper()
+	states, err := store.Active(context.Background(), stateDevice)
+	if err != nil {
+		t.Fatalf("Active() error = %v", err)
+	}
+
+	return len(states)
+}
+
+func TestStateStoreDebounce(t *testing.T) {
+	store := newStateStore(t, config.AlarmConfig{Debounce: 3})
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	updates := runSteps(t, store, start, []step{
+		{at: 0, want: alarm.TransitionNone},
+		{at: 10 * time.Minute, want: alarm.TransitionNone},
+		{at: 20 * time.Minute, want: alarm.TransitionRaise, notify: true},
+	})
+
+	raised := updates[2].State
+	if !raised.FirstSeenAt.Equal(start) || !raised.RaisedAt.Equal(start.Add(20*time.Minute)) {
+		t.Errorf("raised state first seen %s, raised %s, want %s and %s", raised.FirstSeenAt, raised.RaisedAt, start, start.Add(20*time.Minute))
+	}
+
+	if n := activeCount(t, store); n != 1 {
+		t.Fatalf("active alarms after the raise = %d, want 1", n)
+	}
+
+	// An observation between misses restarts the count.
+	runSteps(t, store, start, []step{
+		{at: 30 * time.Minute, miss: true, want: alarm.TransitionNone},
+		{at: 40 * time.Minute, miss: true, want: alarm.TransitionNone},
+		{at: 50 * time.Minute, want: alarm.TransitionRepeat},
+		{at: 60 * time.Minute, miss: true, want: alarm.TransitionNone},
+		{at: 70 * time.Minute, miss: true, want: alarm.TransitionNone},
+		{at: 80 * time.Minute, miss: true, want: alarm.TransitionClear, notify: true},
+	})
+
+	if n := activeCount(t, store); n != 0 {
+		t.Errorf("active alarms after the clear = %d, want 0", n)
+	}
+}
+
+func TestStateStoreMissPendingRaise(t *testing.T) {
+	store := newStateStore(t, config.AlarmConfig{Debounce: 2})
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// The miss drops the pending observation, the alarm needs two new ones.
+	updates := runSteps(t, store, start, []step{
+		{at: 0, want: alarm.TransitionNone},
+		{at: 10 * time.Minute, miss: true, want: alarm.TransitionNone},
+		{at: 20 * time.Minute, want: alarm.TransitionNone},
+		{at: 30 * time.Minute, want: alarm.TransitionRaise, notify: true},
+	})
+
+	if first := updates[3].State.FirstSeenAt; !first.Equal(start.Add(20 * time.Minute)) {
+		t.Errorf("first seen = %s, want the observation after the miss %s", first, start.Add(20*time.Minute))
+	}
+}
+
+func TestStateStoreFlap(t *testing.T) {
+	store := newStateStore(t, config.AlarmConfig{Debounce: 1, FlapTransitions: 3, FlapWindow: time.Hour})
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// The third transition within the hour starts flapping and holds back
+	// the traps until the window has no transition left.
+	updates := runSteps(t, store, start, []step{
+		{at: 0, want: alarm.TransitionRaise, notify: true},
+		{at: 5 * time.Minute, miss: true, want: alarm.TransitionClear, notify: true},
+		{at: 10 * time.Minute, want: alarm.TransitionRaise, flapStart: true},
+		{at: 15 * time.Minute, miss: true, want: alarm.TransitionClear},
+		{at: 20 * time.Minute, want: alarm.TransitionRaise},
+		{at: 25 * time.Minute, miss: true, want: alarm.TransitionClear},
+		{at: 80 * time.Minute, miss: true, want: alarm.TransitionNone},
+		{at: 86 * time.Minute, miss: true, want: alarm.TransitionNone, notify: true, flapEnd: true},
+	})
+
+	if !updates[2].State.Flapping || !updates[6].State.Flapping {
+		t.Errorf("flapping = %t and %t, want the alarm flapping within the window", updates[2].State.Flapping, updates[6].State.Flapping)
+	}
+
+	// The trap due at the end is the clear trap of the pending alarm, which
+	// is then removed.
+	if end := updates[7].State; end.Flapping || !end.Pending {
+		t.Errorf("state at the end = %+v, want pending and not flapping", end)
+	}
+
+	runSteps(t, store, start, []step{
+		{at: 90 * time.Minute, want: alarm.TransitionRaise, notify: true},
+	})
+}
+
+func TestStateStoreRenotify(t *testing.T) {
+	store := newStateStore(t, config.AlarmConfig{Debounce: 1, RenotifyInterval: 30 * time.Minute})
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	updates := runSteps(t, store, start, []step{
+		{at: 0, want: alarm.TransitionRaise, notify: true},
+		{at: 10 * time.Minute, want: alarm.TransitionRepeat},
+		{at: 30 * time.Minute, want: alarm.TransitionRepeat, notify: true},
+		{at: 50 * time.Minute, want: alarm.TransitionRepeat},
+		{at: 60 * time.Minute, want: alarm.TransitionRepeat, notify: true},
+	})
+
+	for i, want := range []time.Duration{0, 0, 30 * time.Minute, 30 * time.Minute, 60 * time.Minute} {
+		if got := updates[i].State.LastNotifiedAt; !got.Equal(start.Add(want)) {
+			t.Errorf("step %d: last notified = %s, want %s", i, got, start.Add(want))
+		}
+	}
+
+	// A changed trap is due at once, the interval restarts from it.
+	changed := stateAlarm("Grid Loss")
+	changed.Severity = "1"
+	update, err := store.Raise(context.Background(), changed, start.Add(70*time.Minute), false)
+	if err != nil || update.Transition != alarm.TransitionChange || !update.Notify {
+		t.Fatalf("Raise() changed = %s notify %t, %v, want a change due", update.Transition, update.Notify, err)
+	}
+
+	update, err = store.Raise(context.Background(), changed, start.Add(95*time.Minute), false)
+	if err != nil || update.Notify {
+		t.Errorf("Raise() 25m after the change notify = %t, %v, want none", update.Notify, err)
+	}
+}
@@ -178,7 +178,7 @@ func (p SumPerformanceAlarm) Run() error {
 	}
 
 	index := fmt.Sprintf("%s-%s", model.PerformanceAlarmIndex, now.Format("2006.01.02"))
-	if err := p.solarRepo.BulkIndex(index, documents); err != nil {
+	if _, err := p.solarRepo.BulkIndex(index, documents); err != nil {
 		p.logger.Error().Err(err).Msg("SumPerformanceAlarm::Run() - failed to bulk index")
 		return err
 	}
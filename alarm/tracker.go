@@ -0,0 +1,126 @@
+package alarm
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+)
+
+// tracker applies the alarm transitions of one handler run. It records them
+// in the state store, sends their traps and collects their alarm documents.
+// The alarms maintenance covers are recorded without raise traps.
+type tracker struct {
+	vendorType  string
+	store       *StateStore
+	snmp        *infra.SnmpOrchestrator
+	maintenance *Maintenance
+	documents   []interface{}
+}
+
+func newTracker(vendorType string, store *StateStore, snmp *infra.SnmpOrchestrator, maintenance *Maintenance) *tracker {
+	return &tracker{
+		vendorType:  vendorType,
+		store:       store,
+		snmp:        snmp,
+		maintenance: maintenance,
+		documents:   make([]interface{}, 0),
+	}
+}
+
+// raise records an observed alarm and sends the traps due.
+func (t *tracker) raise(ctx context.Context, alarm AlarmState) (Transition, error) {
+	update, err := t.store.Raise(ctx, alarm, time.Now(), t.maintenance.Covers(alarm))
+	if err != nil {
+		return TransitionNone, err
+	}
+
+	t.notify(update, nil)
+	return update.Transition, nil
+}
+
+// clear records the alarms of device except the codes in keep, which were
+// observed in this run, as missed and sends the traps due. It returns the
+// alarms cleared.
+func (t *tracker) clear(ctx context.Context, device DeviceKey, keep ...string) ([]AlarmState, error) {
+	return t.clearAs(ctx, device, nil, keep...)
+}
+
+// clearAs is clear with the clear traps built by clearTrap from the cleared
+// alarm, for vendors whose clear traps differ from their raise traps.
+func (t *tracker) clearAs(ctx context.Context, device DeviceKey, clearTrap func(AlarmState) AlarmState, keep ...string) ([]AlarmState, error) {
+	states, err := t.store.states(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cleared := make([]AlarmState, 0, len(states))
+	for _, state := range states {
+		if slices.Contains(keep, state.Code) {
+			continue
+		}
+
+		update, err := t.store.Miss(ctx, state, now, t.maintenance.Covers(state))
+		if err != nil {
+			return cleared, err
+		}
+
+		t.notify(update, clearTrap)
+		if update.Transition == TransitionClear {
+			cleared = append(cleared, update.State)
+		}
+	}
+
+	return cleared, nil
+}
+
+// notify sends the traps of an update. A flapping alarm gets a single trap
+// with the alert suffixed -Flapping, cleared once it stops flapping. A raise
+// trap held back by a maintenance window still gets its alarm document.
+// clearTrap builds the clear trap of the alarm when set.
+func (t *tracker) notify(update Update, clearTrap func(AlarmState) AlarmState) {
+	state := update.State
+	flapping := state
+	flapping.Alert = state.Alert + "-Flapping"
+
+	if update.FlapStart {
+		t.send(flapping, state.Severity)
+	}
+
+	if update.FlapEnd {
+		t.send(flapping, infra.ClearSeverity)
+	}
+
+	if update.Silenced {
+		t.record(state, state.Severity)
+	}
+
+	if !update.Notify {
+		return
+	}
+
+	if state.Pending {
+		if clearTrap != nil {
+			state = clearTrap(state)
+		}
+
+		t.send(state, infra.ClearSeverity)
+		return
+	}
+
+	t.send(state, state.Severity)
+}
+
+func (t *tracker) send(state AlarmState, severity string) {
+	t.snmp.SendTrap(state.Name, state.Alert, state.Description, severity, state.AlarmTime)
+	t.record(state, severity)
+}
+
+// record collects the alarm document of a trap, sent or held back by a
+// maintenance window.
+func (t *tracker) record(state AlarmState, severity string) {
+	t.documents = append(t.documents, model.NewSnmpAlarmItem(t.vendorType, state.Name, state.Alert, state.Description, severity, state.AlarmTime))
+}
@@ -0,0 +1,50 @@
+package fake
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/imroc/req/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// Recorder returns a response hook that stores every successful response as a
+// fixture below dir, so a server built from os.DirFS(dir) replays it. baseURL
+// is the base URL of the client and is stripped from the request path:
+//
+//	client := growatt.NewGrowattClient(username, token, growatt.WithResponseHook(fake.Recorder("fixtures/growatt", growatt.BaseURL)))
+//
+// Responses of the same path overwrite each other, so record one page per
+// endpoint and review the files before committing them, they contain live
+// plant data.
+func Recorder(dir, baseURL string) req.ResponseMiddleware {
+	basePath := ""
+	if base, err := url.Parse(baseURL); err == nil {
+		basePath = strings.TrimSuffix(base.Path, "/")
+	}
+
+	return func(_ *req.Client, resp *req.Response) error {
+		if resp == nil || resp.Response == nil || resp.Request == nil || resp.Request.RawRequest == nil {
+			return nil
+		}
+
+		if resp.StatusCode != 200 {
+			return nil
+		}
+
+		requestPath := strings.TrimPrefix(resp.Request.RawRequest.URL.Path, basePath)
+		file := filepath.Join(dir, filepath.FromSlash(FixtureName(requestPath)))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			log.Error().Err(err).Str("file", file).Msg("Recorder() - failed to create fixture directory")
+			return nil
+		}
+
+		if err := os.WriteFile(file, resp.Bytes(), 0644); err != nil {
+			log.Error().Err(err).Str("file", file).Msg("Recorder() - failed to write fixture")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package fake
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/HavvokLab/true-solar/api/huawei"
+)
+
+// Token is the XSRF token the fake Huawei server hands out on login.
+const Token = "fake-xsrf-token"
+
+// The fixtures describe one plant per vendor whose plant name parses with
+// util.ParsePlantID, with an online and a faulty inverter and one active
+// alarm. Timestamps are around 2024-01-15 12:00 UTC, collectors that match
+// historical data by date should be run with that time as now.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// NewGrowattServer serves the Growatt OpenAPI.
+func NewGrowattServer() *Server {
+	return mustNewVendorServer("growatt")
+}
+
+// NewHuaweiServer serves both the FusionSolar API used by the huawei client
+// and the one used by the huawei2 client, which share their host. Logins
+// always succeed and set the XSRF-TOKEN cookie to Token.
+func NewHuaweiServer() *Server {
+	s := mustNewVendorServer("huawei")
+
+	login := s.fixtures["/thirdData/login"]
+	login.Header = http.Header{}
+	login.Header.Add("Set-Cookie", (&http.Cookie{Name: huawei.AuthHeader, Value: Token, Path: "/"}).String())
+	s.Handle("/thirdData/login", login)
+	return s
+}
+
+// NewKstarServer serves the Kstar public API.
+func NewKstarServer() *Server {
+	return mustNewVendorServer("kstar")
+}
+
+// NewSolarmanServer serves the Solarman OpenAPI. Every token request returns
+// the same access token, for both the basic and the business token.
+func NewSolarmanServer() *Server {
+	return mustNewVendorServer("solarman")
+}
+
+func mustNewVendorServer(vendor string) *Server {
+	fsys, err := fs.Sub(fixtures, "fixtures/"+vendor)
+	if err != nil {
+		panic(err)
+	}
+
+	s, err := NewServer(fsys)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
@@ -3,7 +3,6 @@ package growatt
 // TODO - validate API path from document
 import (
 	"fmt"
-	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -11,12 +10,14 @@ import (
 	"dario.cat/mergo"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
 	"github.com/imroc/req/v3"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 const (
+	BaseURL     = "https://openapi.growatt.com/v1"
 	AuthHeader  = "Token"
 	MaxPageSize = 100
 	BatchSize   = 50
@@ -96,19 +97,50 @@ type GrowattClient struct {
 	logger    zerolog.Logger
 }
 
-func NewGrowattClient(username, token string) *GrowattClient {
+type Option func(*GrowattClient)
+
+func WithRetryCount(count int) Option {
+	return func(g *GrowattClient) {
+		g.reqClient.SetCommonRetryCount(count)
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(g *GrowattClient) {
+		g.reqClient.SetTimeout(timeout)
+	}
+}
+
+func WithResponseHook(hook req.ResponseMiddleware) Option {
+	return func(g *GrowattClient) {
+		g.reqClient.OnAfterResponse(hook)
+	}
+}
+
+func WithBaseURL(url string) Option {
+	return func(g *GrowattClient) {
+		g.url = strings.TrimSuffix(url, "/")
+	}
+}
+
+func NewGrowattClient(username, token string, opts ...Option) *GrowattClient {
 	logger := zerolog.New(logger.NewWriter("growatt_api.log")).With().Caller().Timestamp().Logger()
 	g := &GrowattClient{
 		reqClient: req.C().
 			SetCommonRetryCount(3).
-			SetCommonRetryFixedInterval(5 * time.Minute),
-		url:      "https://openapi.growatt.com/v1",
+			SetCommonRetryFixedInterval(5 * time.Minute).
+			OnAfterResponse(metrics.ObserveAPIResponse("growatt")),
+		url:      BaseURL,
 		username: username,
 		token:    token,
 		headers:  map[string]string{AuthHeader: token},
 		logger:   logger,
 	}
 
+	for _, opt := range opts {
+		opt(g)
+	}
+
 	return g
 }
 
@@ -130,12 +162,12 @@ func (g *GrowattClient) GetPlantListWithPagination(page, size int) (*GetPlantLis
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get plant list")
 		return nil, err
@@ -197,12 +229,12 @@ func (g *GrowattClient) GetPlantOverviewInfo(plantId int) (*GetPlantOverviewInfo
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get plant overview info")
 		return nil, err
@@ -243,12 +275,12 @@ func (g *GrowattClient) GetPlantDataLoggerInfo(plantId int) (*GetPlantDataLogger
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get plant data logger info")
 		return nil, err
@@ -291,12 +323,12 @@ func (g *GrowattClient) GetPlantDeviceListWithPagination(plantId, page, size int
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get plant device list")
 		return nil, err
@@ -360,12 +392,12 @@ func (g *GrowattClient) GetRealtimeDeviceBatchDataWithPagination(deviceSNs []str
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get realtime device batch data")
 		return nil, err
@@ -444,12 +476,12 @@ func (g *GrowattClient) GetInverterAlertListWithPagination(deviceSN string, date
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get inverter alert list")
 
@@ -515,12 +547,12 @@ func (g *GrowattClient) GetEnergyStorageMachineAlertList(deviceSN string, timest
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get energy storage machine alert list")
 
@@ -567,12 +599,12 @@ func (g *GrowattClient) GetMaxAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get max alert list")
 
@@ -640,12 +672,12 @@ func (g *GrowattClient) GetMixAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get mix alert list")
 
@@ -713,12 +745,12 @@ func (g *GrowattClient) GetMinAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get min alert list")
 
@@ -786,12 +818,12 @@ func (g *GrowattClient) GetSpaAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get spa alert list")
 
@@ -859,12 +891,12 @@ func (g *GrowattClient) GetPcsAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get pcs alert list")
 
@@ -932,12 +964,12 @@ func (g *GrowattClient) GetHpsAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get hps alert list")
 
@@ -1005,12 +1037,12 @@ func (g *GrowattClient) GetPbdAlertListWithPagination(deviceSN string, timestamp
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get pbd alert list")
 
@@ -1080,12 +1112,12 @@ func (g *GrowattClient) GetHistoricalPlantPowerGenerationWithPagination(plantId
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get historical plant power generation")
 
@@ -1150,12 +1182,12 @@ func (g *GrowattClient) GetPlantBasicInfo(plantId int) (*GetPlantBasicInfoRespon
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		g.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get plant basic info")
 
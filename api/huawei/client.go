@@ -2,17 +2,19 @@ package huawei
 
 import (
 	"fmt"
-	"io"
+	"strings"
 	"time"
 
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
 	"github.com/HavvokLab/true-solar/pkg/util"
 	"github.com/imroc/req/v3"
 	"github.com/rs/zerolog"
 )
 
 const (
+	BaseURL         = "https://sg5.fusionsolar.huawei.com"
 	AuthHeader      = "XSRF-TOKEN"
 	CurrencyUSD     = "USD"
 	LanguageEnglish = "en_UK"
@@ -52,13 +54,32 @@ func WithRetryCount(count int) Option {
 	}
 }
 
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *HuaweiClient) {
+		h.reqClient.SetTimeout(timeout)
+	}
+}
+
+func WithResponseHook(hook req.ResponseMiddleware) Option {
+	return func(h *HuaweiClient) {
+		h.reqClient.OnAfterResponse(hook)
+	}
+}
+
+func WithBaseURL(url string) Option {
+	return func(h *HuaweiClient) {
+		h.url = strings.TrimSuffix(url, "/")
+	}
+}
+
 func NewHuaweiClient(username, password string, opts ...Option) (*HuaweiClient, error) {
 	h := &HuaweiClient{
 		reqClient: req.C().
 			SetCommonRetryCount(3).
 			SetCommonRetryFixedInterval(5 * time.Minute).
-			SetTimeout(10 * time.Second),
-		url:      "https://sg5.fusionsolar.huawei.com",
+			SetTimeout(10 * time.Second).
+			OnAfterResponse(metrics.ObserveAPIResponse("huawei")),
+		url:      BaseURL,
 		username: username,
 		password: password,
 		headers:  make(map[string]string),
@@ -94,12 +115,12 @@ func (h *HuaweiClient) GetToken(username, password string) (string, error) {
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get token")
 		return "", err
@@ -156,12 +177,12 @@ func (h *HuaweiClient) GetPlantList() (*GetPlantListResponse, error) {
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Msg("failed to get plant list")
 		return nil, err
 	}
@@ -208,12 +229,12 @@ func (h *HuaweiClient) GetRealtimePlantData(stationCodes string) (*GetRealtimePl
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get realtime plant data")
 		return nil, err
@@ -275,12 +296,12 @@ func (h *HuaweiClient) GetHistoricalPlantData(interval Interval, stationCodes st
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("interval", interval).
 			Any("body", body).
 			Msg("failed to get historical plant data")
@@ -334,12 +355,12 @@ func (h *HuaweiClient) GetDeviceList(stationCodes string) (*GetDeviceListRespons
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get device list")
 		return nil, err
@@ -392,12 +413,12 @@ func (h *HuaweiClient) GetRealtimeDeviceData(deviceIds, deviceTypeId string) (*G
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", data).
 			Msg("failed to get realtime device data")
 		return nil, err
@@ -460,12 +481,12 @@ func (h *HuaweiClient) GetHistoricalDeviceData(interval Interval, deviceId, devi
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get historical device data")
 		return nil, err
@@ -521,12 +542,12 @@ func (h *HuaweiClient) GetDeviceAlarm(stationCodes string, from, to int64) (*Get
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get device alarm")
 		return nil, err
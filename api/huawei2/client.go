@@ -2,17 +2,19 @@ package huawei2
 
 import (
 	"fmt"
-	"io"
+	"strings"
 	"time"
 
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
 	"github.com/HavvokLab/true-solar/pkg/util"
 	"github.com/imroc/req/v3"
 	"github.com/rs/zerolog"
 )
 
 const (
+	BaseURL         = "https://sg5.fusionsolar.huawei.com"
 	AuthHeader      = "XSRF-TOKEN"
 	CurrencyUSD     = "USD"
 	LanguageEnglish = "en_UK"
@@ -44,18 +46,49 @@ type Huawei2Client struct {
 	logger    zerolog.Logger
 }
 
-func NewHuawei2Client(username, password string) (*Huawei2Client, error) {
+type Option func(*Huawei2Client)
+
+func WithRetryCount(count int) Option {
+	return func(h *Huawei2Client) {
+		h.reqClient.SetCommonRetryCount(count)
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Huawei2Client) {
+		h.reqClient.SetTimeout(timeout)
+	}
+}
+
+func WithResponseHook(hook req.ResponseMiddleware) Option {
+	return func(h *Huawei2Client) {
+		h.reqClient.OnAfterResponse(hook)
+	}
+}
+
+func WithBaseURL(url string) Option {
+	return func(h *Huawei2Client) {
+		h.url = strings.TrimSuffix(url, "/")
+	}
+}
+
+func NewHuawei2Client(username, password string, opts ...Option) (*Huawei2Client, error) {
 	h := &Huawei2Client{
 		reqClient: req.C().
 			SetCommonRetryCount(3).
-			SetCommonRetryFixedInterval(5 * time.Minute),
-		url:      "https://sg5.fusionsolar.huawei.com",
+			SetCommonRetryFixedInterval(5 * time.Minute).
+			OnAfterResponse(metrics.ObserveAPIResponse(model.ModuleHuawei2)),
+		url:      BaseURL,
 		username: username,
 		password: password,
 		headers:  make(map[string]string),
 		logger:   zerolog.New(logger.NewWriter("huawei2_api.log")).With().Timestamp().Logger(),
 	}
 
+	for _, opt := range opts {
+		opt(h)
+	}
+
 	token, err := h.GetToken(username, password)
 	if err != nil {
 		return nil, err
@@ -81,12 +114,12 @@ func (h *Huawei2Client) GetToken(username, password string) (string, error) {
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get token")
 		return "", err
@@ -147,12 +180,12 @@ func (h *Huawei2Client) GetPlantListWithPagination(page int) (*GetPlantListRespo
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get plant list")
 		return nil, err
@@ -233,12 +266,12 @@ func (h *Huawei2Client) GetRealtimePlantData(stationCodes string) (*GetRealtimeP
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get realtime plant data")
 		return nil, err
@@ -300,12 +333,12 @@ func (h *Huawei2Client) GetHistoricalPlantData(interval Interval, stationCodes s
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("interval", interval).
 			Any("body", body).
 			Msg("failed to get historical plant data")
@@ -359,12 +392,12 @@ func (h *Huawei2Client) GetDeviceList(stationCodes string) (*GetDeviceListRespon
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get device list")
 		return nil, err
@@ -417,12 +450,12 @@ func (h *Huawei2Client) GetRealtimeDeviceData(deviceIds, deviceTypeId string) (*
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", data).
 			Msg("failed to get realtime device data")
 		return nil, err
@@ -485,12 +518,12 @@ func (h *Huawei2Client) GetHistoricalDeviceData(interval Interval, deviceId, dev
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get historical device data")
 		return nil, err
@@ -546,12 +579,12 @@ func (h *Huawei2Client) GetDeviceAlarm(stationCodes string, from, to int64) (*Ge
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		h.logger.Error().
 			Err(err).
 			Str("url", url).
-			Int("status_code", resp.StatusCode).
-			Str("raw", string(raw)).
+			Int("status_code", resp.GetStatusCode()).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get device alarm")
 		return nil, err
@@ -4,7 +4,6 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"fmt"
-	"io"
 	"net/url"
 	"strconv"
 	"strings"
@@ -12,12 +11,14 @@ import (
 
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
 	"github.com/imroc/req/v3"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 const (
+	BaseURL     = "http://solar.kstar.com:9000/public"
 	MaxPageSize = 100
 )
 
@@ -47,6 +48,24 @@ func WithRetryCount(count int) Option {
 	}
 }
 
+func WithTimeout(timeout time.Duration) Option {
+	return func(k *KstarClient) {
+		k.reqClient.SetTimeout(timeout)
+	}
+}
+
+func WithResponseHook(hook req.ResponseMiddleware) Option {
+	return func(k *KstarClient) {
+		k.reqClient.OnAfterResponse(hook)
+	}
+}
+
+func WithBaseURL(url string) Option {
+	return func(k *KstarClient) {
+		k.url = strings.TrimSuffix(url, "/")
+	}
+}
+
 func NewKstarClient(username, password string, opts ...Option) *KstarClient {
 	logger := zerolog.New(logger.NewWriter("kstar_api.log")).With().Caller().Timestamp().Logger()
 	k := &KstarClient{
@@ -59,8 +78,9 @@ func NewKstarClient(username, password string, opts ...Option) *KstarClient {
 					Any("request", req.RawURL).
 					Msg("KstarClient::NewKstarClient() - requesting")
 				return nil
-			}),
-		url:      "http://solar.kstar.com:9000/public",
+			}).
+			OnAfterResponse(metrics.ObserveAPIResponse("kstar")),
+		url:      BaseURL,
 		username: username,
 		password: password,
 		logger:   logger,
@@ -112,12 +132,12 @@ func (k *KstarClient) GetPlantList() (*GetPlantListResponse, error) {
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		k.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get plant list")
 		return nil, err
@@ -176,12 +196,12 @@ func (k *KstarClient) GetDeviceListWithPagination(page, size int) (*GetDeviceLis
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		k.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get device list with pagination")
 		return nil, err
@@ -262,12 +282,12 @@ func (k *KstarClient) GetRealtimeDeviceData(deviceId string) (*GetRealtimeDevice
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		k.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get realtime device data")
 	}
@@ -322,12 +342,12 @@ func (k *KstarClient) GetRealtimeAlarmListOfDevice(deviceId string) (*GetRealtim
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		k.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get realtime alarm list of device")
 		return nil, err
@@ -387,12 +407,12 @@ func (k *KstarClient) GetHistoricalDeviceData(deviceId string, collectTime *time
 		Get(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		k.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get historical device data")
 		return nil, err
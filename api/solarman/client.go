@@ -2,17 +2,19 @@ package solarman
 
 import (
 	"fmt"
-	"io"
+	"strings"
 	"time"
 
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
 	"github.com/imroc/req/v3"
 	"github.com/rs/zerolog"
 	"go.openly.dev/pointy"
 )
 
 const (
+	BaseURL                         = "https://globalapi.solarmanpv.com"
 	DataListKeyCumulativeProduction = "Et_ge0"
 	DataListKeyGeneration           = "generation"
 )
@@ -69,7 +71,33 @@ type SolarmanClient struct {
 	headers   map[string]string
 }
 
-func NewSolarmanClient(username, password, appId, appSecret string) *SolarmanClient {
+type Option func(*SolarmanClient)
+
+func WithRetryCount(count int) Option {
+	return func(c *SolarmanClient) {
+		c.reqClient.SetCommonRetryCount(count)
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *SolarmanClient) {
+		c.reqClient.SetTimeout(timeout)
+	}
+}
+
+func WithResponseHook(hook req.ResponseMiddleware) Option {
+	return func(c *SolarmanClient) {
+		c.reqClient.OnAfterResponse(hook)
+	}
+}
+
+func WithBaseURL(url string) Option {
+	return func(c *SolarmanClient) {
+		c.url = strings.TrimSuffix(url, "/")
+	}
+}
+
+func NewSolarmanClient(username, password, appId, appSecret string, opts ...Option) *SolarmanClient {
 	logger := zerolog.New(logger.NewWriter("solarman_api.log")).With().Caller().Timestamp().Logger()
 	client := &SolarmanClient{
 		reqClient: req.C().
@@ -81,8 +109,9 @@ func NewSolarmanClient(username, password, appId, appSecret string) *SolarmanCli
 					Any("request", req.RawURL).
 					Msg("SolarmanClient::NewSolarmanClient() - requesting")
 				return nil
-			}),
-		url:       "https://globalapi.solarmanpv.com",
+			}).
+			OnAfterResponse(metrics.ObserveAPIResponse("solarman")),
+		url:       BaseURL,
 		username:  username,
 		password:  DecodePassword(password),
 		appId:     appId,
@@ -91,6 +120,10 @@ func NewSolarmanClient(username, password, appId, appSecret string) *SolarmanCli
 		headers:   make(map[string]string),
 	}
 
+	for _, opt := range opts {
+		opt(client)
+	}
+
 	return client
 }
 
@@ -119,12 +152,12 @@ func (c *SolarmanClient) GetBasicToken() (*GetTokenResponse, error) {
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get basic token")
 		return nil, err
@@ -172,12 +205,12 @@ func (c *SolarmanClient) GetBusinessToken(orgId int) (*GetTokenResponse, error)
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get business token")
 		return nil, err
@@ -219,12 +252,12 @@ func (c *SolarmanClient) GetUserInfo() (*GetUserInfoResponse, error) {
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("query", query).
 			Msg("failed to get user info")
 		return nil, err
@@ -271,12 +304,12 @@ func (c *SolarmanClient) GetPlantListWithPagination(page, size int) (*GetPlantLi
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get plant list with pagination")
 		return nil, err
@@ -343,12 +376,12 @@ func (c *SolarmanClient) GetPlantBaseInfo(stationId int) (*GetPlantBaseInfoRespo
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get plant base info")
 		return nil, err
@@ -394,12 +427,12 @@ func (c *SolarmanClient) GetPlantRealtimeData(stationId int) (*GetRealtimePlantD
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get plant realtime data")
 		return nil, err
@@ -448,12 +481,12 @@ func (c *SolarmanClient) GetHistoricalPlantData(stationId int, timeType TimeType
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get historical plant data")
 		return nil, err
@@ -501,12 +534,12 @@ func (c *SolarmanClient) GetPlantDeviceListWithPagination(stationId, page, size
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get plant device list with pagination")
 		return nil, err
@@ -573,12 +606,12 @@ func (c *SolarmanClient) GetDeviceRealtimeData(deviceSn string) (*GetRealtimeDev
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get device realtime data")
 		return nil, err
@@ -627,12 +660,12 @@ func (c *SolarmanClient) GetHistoricalDeviceData(deviceSn string, timeType TimeT
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get historical device data")
 		return nil, err
@@ -682,12 +715,12 @@ func (c *SolarmanClient) GetDeviceAlertListWithPagination(deviceSn string, from,
 		Post(url)
 
 	if err != nil {
-		raw, _ := io.ReadAll(resp.Body)
+		raw := resp.String()
 		c.logger.Error().
 			Err(err).
-			Int("status_code", resp.StatusCode).
+			Int("status_code", resp.GetStatusCode()).
 			Str("url", url).
-			Str("raw", string(raw)).
+			Str("raw", raw).
 			Any("body", body).
 			Msg("failed to get device alert list with pagination")
 		return nil, err
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/HavvokLab/true-solar/admin"
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAddress  = ":8080"
+	shutdownTimeout = 10 * time.Second
+)
+
+func init() {
+	logger.Init("admin.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+func main() {
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+		log.Fatal().Err(err).Msg("error prepare database")
+	}
+
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load credential key")
+	}
+
+	cfg := conf.Admin
+	server, err := admin.NewServer(db, cipher, cfg.Token)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error create admin server")
+	}
+
+	address := cfg.Address
+	if address == "" {
+		address = defaultAddress
+	}
+
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Info().Str("address", address).Msg("starting admin server")
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("admin server stopped")
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("error shutdown admin server")
+	}
+}
@@ -1,17 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"sync"
 	"time"
 
 	"github.com/HavvokLab/true-solar/alarm"
 	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/registry"
 	"github.com/HavvokLab/true-solar/repo"
 	"github.com/rs/zerolog/log"
-	"github.com/sourcegraph/conc"
 )
 
 // parseFlags parses the workerPoolSize, startDate, endDate, and vendor flags and returns them.
@@ -33,131 +34,88 @@ func init() {
 
 func main() {
 	vendor := parseFlags()
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
 	log.Info().Msgf("start alarm for vendor: %s", vendor)
 	switch vendor {
-	case "growatt":
-		growatt()
-	case "kstar":
-		kstar()
-	case "huawei":
-		huawei()
-	case "solarman":
-		solarman()
 	case "clear":
-		clear()
+		clear(container)
 	case "performance":
-		performance()
+		performance(container)
+	case "migrate-state":
+		migrateState(container)
 	default:
-		log.Panic().Msg("invalid vendor")
+		module, ok := registry.Lookup(vendor)
+		if !ok || !module.HasAlarm() {
+			log.Panic().Msg("invalid vendor")
+		}
+
+		run(container, module)
 	}
 }
 
-func growatt() {
-	credRepo := repo.NewGrowattCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+func run(container *infra.Container, module registry.Module) {
+	db, err := container.DB()
 	if err != nil {
-		log.Panic().Err(err).Msg("error find all credentials")
-	}
-	log.Info().Msgf("found %d credentials", len(credentials))
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeGrowattAlarm, config.GetConfig().SnmpList)
-	if err != nil {
-		log.Panic().Err(err).Msg("error create snmp orchestrator")
+		log.Panic().Err(err).Msg("error open database")
 	}
-	log.Info().Msg("create snmp orchestrator success")
-	rdb, err := infra.NewRedis()
-	if err != nil {
-		log.Panic().Err(err).Msg("error create redis")
+
+	if err := migration.Prepare(db, container.Config().Database.MigrateOnOpen(false)); err != nil {
+		log.Panic().Err(err).Msg("error prepare database")
 	}
-	log.Info().Msg("create redis success")
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		wg.Go(func() {
-			serv := alarm.NewGrowattAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
-			)
 
-			serv.Run(&cred)
-		})
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
 	}
 
-	if r := wg.WaitAndRecover(); r != nil {
-		log.Panic().Any("recover", r.Value).Msg("error wait group")
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Panic().Err(err).Msg("error load credential key")
 	}
-}
 
-func huawei() {
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+	credentials, err := module.Credentials(db, cipher)
 	if err != nil {
 		log.Panic().Err(err).Msg("error find all credentials")
 	}
 	log.Info().Msgf("found %d credentials", len(credentials))
 
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeHuaweiAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(module.TrapType)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
 	log.Info().Msg("create snmp orchestrator success")
 
-	rdb, err := infra.NewRedis()
+	rdb, err := container.Redis()
 	if err != nil {
 		log.Panic().Err(err).Msg("error create redis")
 	}
 	log.Info().Msg("create redis success")
 
-	wg := sync.WaitGroup{}
-	for _, credential := range credentials {
-		cred := credential
-		if cred.Version != 1 {
-			continue
-		}
+	state := alarm.NewStateStore(rdb, container.Config().Alarm)
 
-		wg.Add(1)
-		go func() {
-			serv := alarm.NewHuaweiAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
-			)
-
-			serv.Run(&cred)
-			wg.Done()
-		}()
-	}
-	wg.Wait()
-}
-
-func kstar() {
-	credRepo := repo.NewKStarCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		log.Panic().Err(err).Msg("error find all credentials")
-	}
-	log.Info().Msgf("found %d credentials", len(credentials))
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeKstarAlarm, config.GetConfig().SnmpList)
-	if err != nil {
-		log.Panic().Err(err).Msg("error create snmp orchestrator")
-	}
-	log.Info().Msg("create snmp orchestrator success")
-	rdb, err := infra.NewRedis()
-	if err != nil {
-		log.Panic().Err(err).Msg("error create redis")
-	}
-	log.Info().Msg("create redis success")
-	wg := conc.NewWaitGroup()
+	wg := module.Pool(container.Config().Concurrency).NewGroup()
 	for _, credential := range credentials {
 		cred := credential
 		wg.Go(func() {
-			serv := alarm.NewKstarAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
+			serv := module.NewAlarm(
+				repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+				repo.NewSiteRegionMappingRepo(db),
+				repo.NewAlarmSeverityMappingRepo(db),
+				repo.NewMaintenanceWindowRepo(db),
 				snmp,
-				rdb,
+				state,
 			)
 
-			serv.Run(&cred)
+			if err := serv.Run(cred); err != nil {
+				log.Error().Err(err).Int64("credential_id", cred.GetID()).Msg("error run alarm")
+			}
 		})
 	}
 
@@ -166,64 +124,50 @@ func kstar() {
 	}
 }
 
-func solarman() {
-	credRepo := repo.NewSolarmanCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		log.Panic().Err(err).Msg("error find all credentials")
-	}
-	log.Info().Msgf("found %d credentials", len(credentials))
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeSolarmanAlarm, config.GetConfig().SnmpList)
+func clear(container *infra.Container) {
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
-	log.Info().Msg("create snmp orchestrator success")
-	rdb, err := infra.NewRedis()
+
+	es, err := container.Elastic()
 	if err != nil {
-		log.Panic().Err(err).Msg("error create redis")
+		log.Panic().Err(err).Msg("error connect elasticsearch")
 	}
-	log.Info().Msg("create redis success")
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		wg.Go(func() {
-			serv := alarm.NewSolarmanAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
-			)
 
-			serv.Run(&cred)
-		})
-	}
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
 
-	if r := wg.WaitAndRecover(); r != nil {
-		log.Panic().Any("recover", r.Value).Msg("error wait group")
+	clearAlarm := alarm.NewClearAlarm(solarRepo, snmp)
+	if err := clearAlarm.Run(); err != nil {
+		log.Panic().Err(err).Msg("error run clear alarm")
 	}
+
 }
 
-func clear() {
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+func performance(container *infra.Container) {
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
 
-	clearAlarm := alarm.NewClearAlarm(repo.NewSolarRepo(infra.ElasticClient), snmp)
-	if err := clearAlarm.Run(); err != nil {
-		log.Panic().Err(err).Msg("error run clear alarm")
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
 	}
 
-}
+	if err := migration.Prepare(db, container.Config().Database.MigrateOnOpen(false)); err != nil {
+		log.Panic().Err(err).Msg("error prepare database")
+	}
 
-func performance() {
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+	es, err := container.Elastic()
 	if err != nil {
-		log.Panic().Err(err).Msg("error create snmp orchestrator")
+		log.Panic().Err(err).Msg("error connect elasticsearch")
 	}
 
-	solarRepo := repo.NewSolarRepo(infra.ElasticClient)
-	installedCapacityRepo := repo.NewInstalledCapacityRepo(infra.GormDB)
-	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(infra.GormDB)
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+
+	installedCapacityRepo := repo.NewInstalledCapacityRepo(db)
+	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(db)
 	lowAlarm := alarm.NewLowPerformanceAlarm(
 		solarRepo,
 		installedCapacityRepo,
@@ -235,3 +179,18 @@ func performance() {
 		log.Error().Err(err).Msg("error run low performance alarm")
 	}
 }
+
+// migrateState moves the alarm keys of earlier builds into the state store.
+// Run it once before the first alarm run of this build.
+func migrateState(container *infra.Container) {
+	rdb, err := container.Redis()
+	if err != nil {
+		log.Panic().Err(err).Msg("error create redis")
+	}
+
+	migrated, err := alarm.NewStateStore(rdb, container.Config().Alarm).MigrateLegacy(context.Background(), time.Now())
+	if err != nil {
+		log.Panic().Err(err).Int("migrated", migrated).Msg("error migrate alarm state")
+	}
+	log.Info().Int("migrated", migrated).Msg("migrate alarm state success")
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/go-playground/validator/v10"
@@ -32,8 +33,21 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to decode file")
 	}
 
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	client, err := container.Elastic()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect elasticsearch")
+	}
+
 	vld := validator.New()
-	bulk := infra.ElasticClient.Bulk()
+	bulk := client.Bulk()
 	for _, doc := range docs {
 		if err := vld.Struct(doc); err != nil {
 			log.Warn().Err(err).Any("document", doc).Msg("⚠️ invalid document")
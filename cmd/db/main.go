@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// copyBatchSize is the number of rows read and inserted at once by copy.
+const copyBatchSize = 500
+
+// tableCopy copies one table. The rows a migration seeded into a seeded
+// table are replaced by the rows of the source.
+type tableCopy struct {
+	table  string
+	seeded bool
+}
+
+// copyTables are the tables moved by copy, in insert order. The schema
+// version table is not copied, the target is migrated itself.
+var copyTables = []tableCopy{
+	{"tbl_huawei_credentials", false},
+	{"tbl_kstar_credentials", false},
+	{"tbl_growatt_credentials", false},
+	{"tbl_solarman_credentials", false},
+	{"tbl_site_region_mapping", false},
+	{"tbl_installed_capacity", true},
+	{"tbl_performance_alarm_config", true},
+	{"tbl_alarm_severity_mapping", true},
+	{"tbl_maintenance_windows", false},
+	{"tbl_job_runs", false},
+}
+
+func init() {
+	logger.Init("db.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+func usage() {
+	fmt.Fprintln(flag.CommandLine.Output(), "Usage: db <migrate|status|copy>")
+	fmt.Fprintln(flag.CommandLine.Output(), "  migrate                apply the pending migrations to the configured database")
+	fmt.Fprintln(flag.CommandLine.Output(), "  status                 list the migrations and when they were applied")
+	fmt.Fprintln(flag.CommandLine.Output(), "  copy [-from database.db]")
+	fmt.Fprintln(flag.CommandLine.Output(), "                         migrate the configured database and copy the rows of a SQLite file into it")
+}
+
+// main manages the schema of the relational store configured in
+// database.driver and database.dsn, and moves an existing SQLite file into
+// PostgreSQL or MySQL.
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	db, err := infra.NewGormDB(conf.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	switch flag.Arg(0) {
+	case "migrate":
+		migrate(db)
+	case "status":
+		statuses, err := migration.Statuses(db)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error read migrations")
+		}
+		printStatuses(statuses)
+	case "copy":
+		copyFlags := flag.NewFlagSet("copy", flag.ExitOnError)
+		from := copyFlags.String("from", config.DefaultDatabaseDSN, "SQLite file to copy from")
+		copyFlags.Parse(flag.Args()[1:])
+
+		target := conf.Database.WithDefaults()
+		if target.Driver == config.DatabaseDriverSQLite && filepath.Clean(target.DSN) == filepath.Clean(*from) {
+			log.Fatal().Str("from", *from).Msg("source and target are the same database, configure database.driver and database.dsn first")
+		}
+
+		migrate(db)
+		if err := copyDatabase(*from, db); err != nil {
+			log.Fatal().Err(err).Msg("error copy database")
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func migrate(db *gorm.DB) {
+	applied, err := migration.Migrate(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error migrate database")
+	}
+
+	for _, m := range applied {
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("applied migration")
+	}
+
+	log.Info().Int("applied", len(applied)).Int("version", migration.Latest()).Msg("database is up to date")
+}
+
+// copyDatabase copies every table of the SQLite file at from into dst. It
+// refuses to copy into a table holding rows, so an interrupted copy is redone
+// on an emptied target instead of duplicating rows. Each table is copied in
+// a transaction, a failed copy leaves the table as it was.
+func copyDatabase(from string, dst *gorm.DB) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+
+	src, err := infra.NewGormDB(config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, DSN: from})
+	if err != nil {
+		return fmt.Errorf("open %s: %w", from, err)
+	}
+
+	if sqlDB, err := src.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	for _, table := range copyTables {
+		if !src.Migrator().HasTable(table.table) {
+			log.Warn().Str("table", table.table).Msg("table missing in source, skipped")
+			continue
+		}
+
+		rows := 0
+		err := dst.Transaction(func(tx *gorm.DB) error {
+			if table.seeded {
+				if err := replaceSeeds(src, tx, table.table); err != nil {
+					return fmt.Errorf("replace seeded rows of %s: %w", table.table, err)
+				}
+			}
+
+			var count int64
+			if err := tx.Table(table.table).Count(&count).Error; err != nil {
+				return fmt.Errorf("count %s: %w", table.table, err)
+			}
+
+			if count > 0 {
+				return fmt.Errorf("target table %s holds %d rows, empty it first", table.table, count)
+			}
+
+			var err error
+			if rows, err = copyRows(src, tx, table.table); err != nil {
+				return fmt.Errorf("copy %s: %w", table.table, err)
+			}
+
+			if err := resetSequence(tx, table.table); err != nil {
+				return fmt.Errorf("reset id sequence of %s: %w", table.table, err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Info().Str("table", table.table).Int("rows", rows).Msg("copied table")
+	}
+
+	return nil
+}
+
+// replaceSeeds deletes the rows of table in dst when src has rows of its own,
+// the seeds of the migrations would make the copy refuse the table.
+func replaceSeeds(src, dst *gorm.DB, table string) error {
+	var count int64
+	if err := src.Table(table).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return dst.Exec("DELETE FROM " + table).Error
+}
+
+// copyRows copies the rows of table in id order, keeping their ids so
+// references like the credential id of the job ledger stay valid. Rows are
+// copied as column maps, models would fill empty timestamps on insert.
+func copyRows(src, dst *gorm.DB, table string) (int, error) {
+	total := 0
+	var lastID interface{} = 0
+	for {
+		batch := make([]map[string]interface{}, 0, copyBatchSize)
+		err := src.Table(table).Where("id > ?", lastID).Order("id").Limit(copyBatchSize).Find(&batch).Error
+		if err != nil {
+			return total, err
+		}
+
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		if err := dst.Table(table).Create(&batch).Error; err != nil {
+			return total, err
+		}
+
+		total += len(batch)
+		lastID = batch[len(batch)-1]["id"]
+		if len(batch) < copyBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// resetSequence moves the id sequence of a PostgreSQL table past the copied
+// ids, which were inserted explicitly. SQLite and MySQL follow the largest id
+// on their own.
+func resetSequence(db *gorm.DB, table string) error {
+	if db.Dialector.Name() != config.DatabaseDriverPostgres {
+		return nil
+	}
+
+	query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s`, table, table)
+	return db.Exec(query).Error
+}
+
+func printStatuses(statuses []migration.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	w.Flush()
+}
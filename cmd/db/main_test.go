@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"gorm.io/gorm"
+)
+
+// openStore opens a migrated SQLite store in the test directory.
+func openStore(t *testing.T, name string) (*gorm.DB, string) {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), name)
+	db, err := infra.NewGormDB(config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, DSN: dsn})
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate %s: %v", name, err)
+	}
+
+	return db, dsn
+}
+
+func TestCopyDatabase(t *testing.T) {
+	src, from := openStore(t, "source.db")
+	dst, _ := openStore(t, "target.db")
+
+	started := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	runs := make([]model.JobRun, 0, 2*copyBatchSize+1)
+	for i := 0; i < cap(runs); i++ {
+		runs = append(runs, model.JobRun{
+			JobName:   "collect",
+			Vendor:    "huawei",
+			StartedAt: started.Add(time.Duration(i) * time.Minute),
+			Status:    model.JobRunStatusSuccess,
+		})
+	}
+
+	if err := src.CreateInBatches(&runs, 100).Error; err != nil {
+		t.Fatalf("create job runs: %v", err)
+	}
+
+	// Leave a gap in the ids, they are kept by the copy.
+	if err := src.Delete(&model.JobRun{}, 10).Error; err != nil {
+		t.Fatalf("delete job run: %v", err)
+	}
+
+	if err := src.Model(&model.InstalledCapacity{}).Where("1 = 1").Update("efficiency_factor", 0.9).Error; err != nil {
+		t.Fatalf("update installed capacity: %v", err)
+	}
+
+	if err := copyDatabase(from, dst); err != nil {
+		t.Fatalf("copy database: %v", err)
+	}
+
+	var copied []model.JobRun
+	if err := dst.Order("id").Find(&copied).Error; err != nil {
+		t.Fatalf("find job runs: %v", err)
+	}
+
+	if len(copied) != len(runs)-1 {
+		t.Fatalf("copied %d job runs, want %d", len(copied), len(runs)-1)
+	}
+
+	for _, run := range copied {
+		if run.ID == 10 {
+			t.Fatalf("deleted job run 10 was copied")
+		}
+
+		want := started.Add(time.Duration(run.ID-1) * time.Minute)
+		if !run.StartedAt.Equal(want) {
+			t.Fatalf("job run %d started at %s, want %s", run.ID, run.StartedAt, want)
+		}
+
+		if run.FinishedAt != nil {
+			t.Fatalf("job run %d finished at %s, want none", run.ID, run.FinishedAt)
+		}
+	}
+
+	var capacities []model.InstalledCapacity
+	if err := dst.Find(&capacities).Error; err != nil {
+		t.Fatalf("find installed capacity: %v", err)
+	}
+
+	if len(capacities) != 1 || capacities[0].EfficiencyFactor != 0.9 {
+		t.Fatalf("installed capacity = %+v, want the source row only", capacities)
+	}
+}
+
+func TestCopyDatabaseRefusesFilledTable(t *testing.T) {
+	_, from := openStore(t, "source.db")
+	dst, _ := openStore(t, "target.db")
+
+	if err := dst.Create(&model.SiteRegionMapping{Code: "BKK", Name: "Bangkok"}).Error; err != nil {
+		t.Fatalf("create site region: %v", err)
+	}
+
+	if err := copyDatabase(from, dst); err == nil {
+		t.Fatalf("copy into a filled table succeeded")
+	}
+}
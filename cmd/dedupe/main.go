@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/olivere/elastic/v7"
+	"github.com/rs/zerolog/log"
+)
+
+// bulkSize is the number of index and delete requests sent at once.
+const bulkSize = 1000
+
+func init() {
+	logger.Init("dedupe.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+// hit is a document of a duplicate group.
+type hit struct {
+	id        string
+	timestamp time.Time
+	source    json.RawMessage
+}
+
+// group holds the documents of one index that map to the same deterministic
+// _id, with the most recently collected one kept.
+type group struct {
+	kept  hit
+	extra []string
+}
+
+type result struct {
+	documents  int
+	skipped    int
+	duplicates int
+	reindexed  int
+}
+
+// main removes the duplicates that re-running collectors and troubleshoot
+// left in the solarcell indices before documents had deterministic IDs. Of
+// each plant, device or alarm of a collection slot only the most recently
+// collected document is kept, stored under its deterministic _id so later
+// runs overwrite it. Without -apply the command only reports what it would
+// change.
+func main() {
+	pattern := flag.String("index", fmt.Sprintf("%s-*", model.SolarIndex), "Index or index pattern to dedupe")
+	apply := flag.Bool("apply", false, "Delete duplicates and re-index kept documents under their deterministic ID")
+	flag.Parse()
+
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	client, err := container.Elastic()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect elasticsearch")
+	}
+
+	ctx := context.Background()
+	indices, err := listIndices(ctx, client, *pattern)
+	if err != nil {
+		log.Fatal().Err(err).Str("index", *pattern).Msg("failed to list indices")
+	}
+
+	for _, index := range indices {
+		result, err := dedupe(ctx, client, index, *apply)
+		if err != nil {
+			log.Error().Err(err).Str("index", index).Msg("failed to dedupe index")
+			continue
+		}
+
+		log.Info().
+			Str("index", index).
+			Bool("apply", *apply).
+			Int("documents", result.documents).
+			Int("skipped", result.skipped).
+			Int("duplicates", result.duplicates).
+			Int("reindexed", result.reindexed).
+			Msg("index deduped")
+	}
+}
+
+func listIndices(ctx context.Context, client *elastic.Client, pattern string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, repo.DefaultESTimeout)
+	defer cancel()
+
+	response, err := client.IndexGet(pattern).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(response))
+	for index := range response {
+		indices = append(indices, index)
+	}
+
+	sort.Strings(indices)
+	return indices, nil
+}
+
+func dedupe(ctx context.Context, client *elastic.Client, index string, apply bool) (result, error) {
+	groups, res, err := scan(ctx, client, index)
+	if err != nil {
+		return res, err
+	}
+
+	requests := make([]elastic.BulkableRequest, 0)
+	for id, g := range groups {
+		if g.kept.id != id {
+			requests = append(requests, elastic.NewBulkIndexRequest().Index(index).Id(id).Doc(g.kept.source))
+			requests = append(requests, elastic.NewBulkDeleteRequest().Index(index).Id(g.kept.id))
+			res.reindexed++
+		}
+
+		for _, extra := range g.extra {
+			// The kept document is written to id, which must survive.
+			if extra != id {
+				requests = append(requests, elastic.NewBulkDeleteRequest().Index(index).Id(extra))
+			}
+		}
+		res.duplicates += len(g.extra)
+	}
+
+	if !apply {
+		return res, nil
+	}
+
+	for start := 0; start < len(requests); start += bulkSize {
+		end := min(start+bulkSize, len(requests))
+		if err := send(ctx, client, requests[start:end]); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// scan groups the documents of index by their deterministic _id. Documents
+// that have no deterministic _id, like those of an unknown data type, are
+// left alone.
+func scan(ctx context.Context, client *elastic.Client, index string) (map[string]*group, result, error) {
+	ctx, cancel := context.WithTimeout(ctx, repo.ScrollESTimeout)
+	defer cancel()
+
+	scroll := client.Scroll(index).Size(bulkSize).Scroll(repo.ScrollKeepAlive)
+	defer func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cleanupCancel()
+		_ = scroll.Clear(cleanupCtx)
+	}()
+
+	groups := make(map[string]*group)
+	var res result
+	for {
+		results, err := scroll.Do(ctx)
+		if errors.Is(err, io.EOF) {
+			return groups, res, nil
+		}
+
+		if err != nil {
+			return nil, res, err
+		}
+
+		for _, searchHit := range results.Hits.Hits {
+			res.documents++
+
+			id, timestamp, err := identify(searchHit.Source)
+			if err != nil || id == "" {
+				res.skipped++
+				continue
+			}
+
+			current := hit{id: searchHit.Id, timestamp: timestamp, source: searchHit.Source}
+			g, ok := groups[id]
+			if !ok {
+				groups[id] = &group{kept: current}
+				continue
+			}
+
+			if keep(current, g.kept, id) {
+				g.extra = append(g.extra, g.kept.id)
+				g.kept = current
+			} else {
+				g.extra = append(g.extra, current.id)
+			}
+		}
+	}
+}
+
+// keep reports whether candidate replaces kept, preferring the most recently
+// collected document and then the one already stored under id.
+func keep(candidate, kept hit, id string) bool {
+	if !candidate.timestamp.Equal(kept.timestamp) {
+		return candidate.timestamp.After(kept.timestamp)
+	}
+
+	return candidate.id == id && kept.id != id
+}
+
+// identify returns the deterministic _id and the collection time of a
+// solarcell document.
+func identify(source json.RawMessage) (string, time.Time, error) {
+	var header struct {
+		DataType string `json:"data_type"`
+	}
+	if err := json.Unmarshal(source, &header); err != nil {
+		return "", time.Time{}, err
+	}
+
+	var doc model.Identifiable
+	var timestamp *time.Time
+	switch header.DataType {
+	case model.DataTypePlant:
+		item := &model.PlantItem{}
+		doc, timestamp = item, &item.Timestamp
+	case model.DataTypeDevice:
+		item := &model.DeviceItem{}
+		doc, timestamp = item, &item.Timestamp
+	case model.DataTypeAlarm:
+		item := &model.AlarmItem{}
+		doc, timestamp = item, &item.Timestamp
+	default:
+		return "", time.Time{}, nil
+	}
+
+	if err := json.Unmarshal(source, doc); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return doc.DocumentID(), *timestamp, nil
+}
+
+func send(ctx context.Context, client *elastic.Client, requests []elastic.BulkableRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, repo.DefaultESTimeout)
+	defer cancel()
+
+	response, err := client.Bulk().Add(requests...).Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if failed := response.Failed(); len(failed) > 0 {
+		reason := ""
+		if failed[0].Error != nil {
+			reason = failed[0].Error.Reason
+		}
+		return fmt.Errorf("%d of %d bulk requests failed: %s", len(failed), len(requests), reason)
+	}
+
+	return nil
+}
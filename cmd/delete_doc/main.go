@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/pkg/logger"
 	"github.com/gocarina/gocsv"
@@ -130,11 +131,17 @@ func main() {
 		log.Panic().Err(err).Msg("error load document")
 	}
 
+	conf, err := config.Load("")
+	if err != nil {
+		log.Panic().Err(err).Msg("error load config")
+	}
+
 	ctx := context.Background()
-	elasticClient, err := infra.NewElasticClient()
+	elasticClient, err := infra.NewElasticClient(conf.Elastic)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create elastic client")
 	}
+	defer infra.CloseElasticClient(elasticClient)
 
 	deleteDocs := make([]Document, 0)
 	for _, doc := range docs {
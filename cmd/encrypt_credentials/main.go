@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+func init() {
+	logger.Init("encrypt_credentials.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+// main encrypts the secret columns of credential rows written before
+// encryption was enabled. Rows that are already encrypted are left alone.
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Only report rows that would be encrypted")
+	flag.Parse()
+
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load credential key")
+	}
+
+	if cipher == nil {
+		log.Fatal().Msg("no credential key configured, set security.credential_key or SECURITY_CREDENTIAL_KEY")
+	}
+
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+		log.Fatal().Err(err).Msg("error prepare database")
+	}
+
+	results := []struct {
+		vendor string
+		run    func() (int, error)
+	}{
+		{model.VendorTypeGrowatt, func() (int, error) {
+			return migrate(db, repo.NewGrowattCredentialRepo(db, cipher).Update, *dryRun)
+		}},
+		{model.VendorTypeHuawei, func() (int, error) {
+			return migrate(db, repo.NewHuaweiCredentialRepo(db, cipher).Update, *dryRun)
+		}},
+		{model.VendorTypeKstar, func() (int, error) {
+			return migrate(db, repo.NewKStarCredentialRepo(db, cipher).Update, *dryRun)
+		}},
+		{model.VendorTypeSolarman, func() (int, error) {
+			return migrate(db, repo.NewSolarmanCredentialRepo(db, cipher).Update, *dryRun)
+		}},
+	}
+
+	for _, result := range results {
+		count, err := result.run()
+		if err != nil {
+			log.Fatal().Err(err).Str("vendor", result.vendor).Msg("error encrypt credentials")
+		}
+
+		log.Info().Str("vendor", result.vendor).Int("rows", count).Bool("dry_run", *dryRun).Msg("encrypted credentials")
+	}
+}
+
+// migrate reads the raw rows, bypassing the repo decryption, and rewrites the
+// ones holding plaintext secrets through the repo, which encrypts them.
+func migrate[T any, PT interface {
+	*T
+	model.Credential
+	SecretFields() []*string
+}](db *gorm.DB, update func(id int64, credential *T) error, dryRun bool) (int, error) {
+	var rows []T
+	if err := db.Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range rows {
+		row := PT(&rows[i])
+		if !hasPlaintextSecret(row.SecretFields()) {
+			continue
+		}
+
+		count++
+		if dryRun {
+			continue
+		}
+
+		if err := update(row.GetID(), &rows[i]); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func hasPlaintextSecret(fields []*string) bool {
+	for _, field := range fields {
+		if *field != "" && !secret.IsEncrypted(*field) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/HavvokLab/true-solar/alarm"
 	"github.com/HavvokLab/true-solar/collector"
 	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
 	"github.com/HavvokLab/true-solar/pkg/logger"
 	"github.com/HavvokLab/true-solar/repo"
 	"github.com/go-co-op/gocron"
@@ -20,16 +25,56 @@ func init() {
 	time.Local = loc
 }
 
+var container *infra.Container
+
 func main() {
-	cfg := config.GetConfig()
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container = infra.NewContainer(conf)
+	defer container.Close()
+
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+		log.Fatal().Err(err).Msg("error prepare database")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	cron := gocron.NewScheduler(time.Local)
-	cron.Cron(cfg.Crontab.CollectTime).StartImmediately().SingletonMode().Do(collect)
-	cron.Cron(cfg.Crontab.AlarmTime).StartImmediately().SingletonMode().Do(runAlarm)
-	cron.StartBlocking()
+	cron.Cron(conf.Crontab.CollectTime).StartImmediately().SingletonMode().Do(collect)
+	cron.Cron(conf.Crontab.AlarmTime).StartImmediately().SingletonMode().Do(runAlarm)
+	cron.StartAsync()
+
+	// Stop waits for running jobs before the connections are closed.
+	<-ctx.Done()
+	cron.Stop()
 }
 
 func collect() {
-	credRepo := repo.NewGrowattCredentialRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
+	}
+
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Panic().Err(err).Msg("error load credential key")
+	}
+
+	credRepo := repo.NewGrowattCredentialRepo(db, cipher)
 	credentials, err := credRepo.FindAll()
 	if err != nil {
 		log.Panic().Err(err).Msg("error find all credentials")
@@ -41,8 +86,8 @@ func collect() {
 		cred := credential
 		wg.Go(func() {
 			serv := collector.NewGrowattCollector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
+				repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+				repo.NewSiteRegionMappingRepo(db),
 			)
 
 			serv.Execute(now, &cred)
@@ -55,30 +100,50 @@ func collect() {
 }
 
 func runAlarm() {
-	credRepo := repo.NewGrowattCredentialRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
+	}
+
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Panic().Err(err).Msg("error load credential key")
+	}
+
+	credRepo := repo.NewGrowattCredentialRepo(db, cipher)
 	credentials, err := credRepo.FindAll()
 	if err != nil {
 		log.Panic().Err(err).Msg("error find all credentials")
 	}
 
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeGrowattAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(infra.TrapTypeGrowattAlarm)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
 
-	rdb, err := infra.NewRedis()
+	rdb, err := container.Redis()
 	if err != nil {
 		log.Panic().Err(err).Msg("error create redis")
 	}
 
+	state := alarm.NewStateStore(rdb, container.Config().Alarm)
+
 	wg := conc.NewWaitGroup()
 	for _, credential := range credentials {
 		cred := credential
 		wg.Go(func() {
 			serv := alarm.NewGrowattAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
+				repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+				repo.NewSiteRegionMappingRepo(db),
+				repo.NewAlarmSeverityMappingRepo(db),
+				repo.NewMaintenanceWindowRepo(db),
 				snmp,
-				rdb,
+				state,
 			)
 
 			serv.Run(&cred)
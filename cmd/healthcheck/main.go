@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/credential"
+	"github.com/HavvokLab/true-solar/healthcheck"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/registry"
+	"github.com/rs/zerolog/log"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// stdout only carries the JSON results. Console logs of the command and of
+// the API clients go to stderr so monitors can parse the output.
+func init() {
+	logger.SetConsoleOutput(os.Stderr)
+	logger.Init("healthcheck.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+type flags struct {
+	vendor    string
+	username  string
+	password  string
+	token     string
+	appID     string
+	appSecret string
+}
+
+// credential returns the credential given on the command line, or nil when
+// the stored credentials should be checked instead.
+func (f flags) credential(module registry.Module) (model.Credential, error) {
+	if f.username == "" {
+		return nil, nil
+	}
+
+	return credential.Decode(module.Name, "", map[string]interface{}{
+		"username":   f.username,
+		"password":   f.password,
+		"token":      f.token,
+		"app_id":     f.appID,
+		"app_secret": f.appSecret,
+	})
+}
+
+func parseFlags() flags {
+	var f flags
+	flag.StringVar(&f.vendor, "vendor", "", fmt.Sprintf("Vendor to check, one of %s (empty for all)", strings.Join(registry.Names(), ", ")))
+	flag.StringVar(&f.username, "username", "", "Check this username instead of the stored credentials (requires -vendor)")
+	flag.StringVar(&f.password, "password", "", "Password (huawei, huawei2, kstar, solarman)")
+	flag.StringVar(&f.token, "token", "", "API token (growatt)")
+	flag.StringVar(&f.appID, "app-id", "", "App ID (solarman)")
+	flag.StringVar(&f.appSecret, "app-secret", "", "App secret (solarman)")
+	flag.Parse()
+	return f
+}
+
+// main checks the vendor APIs with every stored credential, or with the one
+// given on flags, and prints one JSON result per line. It exits with status 1
+// when any check is not healthy.
+func main() {
+	f := parseFlags()
+
+	modules, err := selectModules(f.vendor)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid flags")
+	}
+
+	if f.username != "" && f.vendor == "" {
+		log.Fatal().Msg("invalid flags: -username requires -vendor")
+	}
+
+	if !run(f, modules) {
+		os.Exit(1)
+	}
+}
+
+// run checks the modules and writes their results, it reports whether every
+// check is healthy. A credential given on flags is checked without loading
+// the config or opening the database.
+func run(f flags, modules []registry.Module) bool {
+	var source credential.CredentialSource
+	var concurrency config.ConcurrencyConfig
+	if f.username == "" {
+		conf, err := config.Load("")
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load config")
+		}
+
+		container := infra.NewContainer(conf, infra.WithGormLogger(
+			gormlogger.New(stdlog.New(os.Stderr, "\r\n", stdlog.LstdFlags), gormlogger.Config{
+				SlowThreshold: 200 * time.Millisecond,
+				LogLevel:      gormlogger.Warn,
+				Colorful:      true,
+			}),
+		))
+		defer container.Close()
+
+		db, err := container.DB()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open database")
+		}
+
+		if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+			log.Fatal().Err(err).Msg("failed to prepare database")
+		}
+
+		cipher, err := container.CredentialCipher()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load credential key")
+		}
+
+		source, err = credential.NewSource(db, cipher, conf.Credentials)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to create credential source")
+		}
+
+		concurrency = conf.Concurrency
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	healthy := true
+	for _, module := range modules {
+		results, err := checkModule(source, module, concurrency, f)
+		if err != nil {
+			log.Error().Err(err).Str("vendor", module.Name).Msg("failed to check vendor")
+			healthy = false
+		}
+
+		for _, result := range results {
+			if err := encoder.Encode(result); err != nil {
+				log.Fatal().Err(err).Msg("failed to write result")
+			}
+
+			healthy = healthy && result.Healthy()
+		}
+	}
+
+	return healthy
+}
+
+func selectModules(vendor string) ([]registry.Module, error) {
+	if vendor == "" {
+		modules := make([]registry.Module, 0)
+		for _, module := range registry.Modules() {
+			if module.HasHealthChecker() {
+				modules = append(modules, module)
+			}
+		}
+
+		return modules, nil
+	}
+
+	module, found := registry.Lookup(vendor)
+	if !found {
+		return nil, fmt.Errorf("unknown vendor %s, expected one of %s", vendor, strings.Join(registry.Names(), ", "))
+	}
+
+	if !module.HasHealthChecker() {
+		return nil, fmt.Errorf("vendor %s has no health check", module.Name)
+	}
+
+	return []registry.Module{module}, nil
+}
+
+// checkModule runs the checks of one vendor on its worker pool and returns
+// the results in credential order.
+func checkModule(source credential.CredentialSource, module registry.Module, concurrency config.ConcurrencyConfig, f flags) ([]healthcheck.Result, error) {
+	cred, err := f.credential(module)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := []model.Credential{cred}
+	var sourceErr error
+	if cred == nil {
+		credentials, sourceErr = source.FindAll(context.Background(), module.Name)
+	}
+
+	checker := module.NewHealthChecker()
+	results := make([]healthcheck.Result, len(credentials))
+	group := module.Pool(concurrency).NewGroup()
+	for i, cred := range credentials {
+		group.Go(func() {
+			results[i] = checker.Check(cred)
+		})
+	}
+	group.Wait()
+
+	return results, sourceErr
+}
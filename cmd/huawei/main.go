@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/HavvokLab/true-solar/alarm"
 	"github.com/HavvokLab/true-solar/collector"
 	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
 	"github.com/HavvokLab/true-solar/pkg/logger"
 	"github.com/HavvokLab/true-solar/repo"
 	"github.com/go-co-op/gocron"
@@ -22,16 +27,56 @@ func init() {
 	time.Local = loc
 }
 
+var container *infra.Container
+
 func main() {
-	cfg := config.GetConfig()
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container = infra.NewContainer(conf)
+	defer container.Close()
+
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+		log.Fatal().Err(err).Msg("error prepare database")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	cron := gocron.NewScheduler(time.Local)
-	cron.Cron(cfg.Crontab.CollectTime).StartImmediately().SingletonMode().Do(collect)
-	cron.Cron(cfg.Crontab.AlarmTime).StartImmediately().SingletonMode().Do(runAlarm)
-	cron.StartBlocking()
+	cron.Cron(conf.Crontab.CollectTime).StartImmediately().SingletonMode().Do(collect)
+	cron.Cron(conf.Crontab.AlarmTime).StartImmediately().SingletonMode().Do(runAlarm)
+	cron.StartAsync()
+
+	// Stop waits for running jobs before the connections are closed.
+	<-ctx.Done()
+	cron.Stop()
 }
 
 func collect() {
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
+	}
+
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Panic().Err(err).Msg("error load credential key")
+	}
+
+	credRepo := repo.NewHuaweiCredentialRepo(db, cipher)
 	credentials, err := credRepo.FindAll()
 	if err != nil {
 		log.Panic().Err(err).Msg("error find all credentials")
@@ -46,11 +91,11 @@ func collect() {
 
 		wg.Go(func() {
 			serv := collector.NewHuaweiCollector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
+				repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+				repo.NewSiteRegionMappingRepo(db),
 			)
 
-			serv.Execute(&cred)
+			serv.Execute(time.Now(), &cred)
 		})
 	}
 
@@ -60,22 +105,39 @@ func collect() {
 }
 
 func runAlarm() {
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
+	}
+
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Panic().Err(err).Msg("error load credential key")
+	}
+
+	credRepo := repo.NewHuaweiCredentialRepo(db, cipher)
 	credentials, err := credRepo.FindAll()
 	if err != nil {
 		log.Panic().Err(err).Msg("error find all credentials")
 	}
 
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeHuaweiAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(infra.TrapTypeHuaweiAlarm)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
 
-	rdb, err := infra.NewRedis()
+	rdb, err := container.Redis()
 	if err != nil {
 		log.Panic().Err(err).Msg("error create redis")
 	}
 
+	state := alarm.NewStateStore(rdb, container.Config().Alarm)
+
 	wg := conc.NewWaitGroup()
 	for _, credential := range credentials {
 		cred := credential
@@ -85,9 +147,12 @@ func runAlarm() {
 
 		wg.Go(func() {
 			serv := alarm.NewHuaweiAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
+				repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+				repo.NewSiteRegionMappingRepo(db),
+				repo.NewAlarmSeverityMappingRepo(db),
+				repo.NewMaintenanceWindowRepo(db),
 				snmp,
-				rdb,
+				state,
 			)
 
 			serv.Run(&cred)
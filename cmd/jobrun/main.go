@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/rs/zerolog/log"
+)
+
+// parseFlags parses the filters of the job run listing.
+func parseFlags() (repo.JobRunFilter, error) {
+	failures := flag.Bool("failures", false, "List failed runs only")
+	vendor := flag.String("vendor", "", "Vendor name")
+	job := flag.String("job", "", "Job name, e.g. huawei_collect")
+	since := flag.Duration("since", 24*time.Hour, "List runs started within this duration (0 for all)")
+	limit := flag.Int("limit", 50, "Maximum number of runs (0 for all)")
+
+	flag.Parse()
+
+	if *limit < 0 {
+		return repo.JobRunFilter{}, fmt.Errorf("limit must not be negative")
+	}
+
+	filter := repo.JobRunFilter{
+		JobName: *job,
+		Vendor:  *vendor,
+		Limit:   *limit,
+	}
+
+	if *failures {
+		filter.Status = model.JobRunStatusFailed
+	}
+
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	return filter, nil
+}
+
+func init() {
+	logger.Init("jobrun.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+func main() {
+	filter, err := parseFlags()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid flags")
+	}
+
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+		log.Fatal().Err(err).Msg("error prepare database")
+	}
+
+	runs, err := repo.NewJobRunRepo(db).FindAll(filter)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error find job runs")
+	}
+
+	printRuns(runs)
+}
+
+func printRuns(runs []model.JobRun) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tJOB\tVENDOR\tCREDENTIAL\tSTARTED\tDURATION\tSTATUS\tDOCS\tSITES\tERROR")
+	for _, run := range runs {
+		credential := "-"
+		if run.CredentialName != "" {
+			credential = run.CredentialName
+		} else if run.CredentialID != nil {
+			credential = strconv.FormatInt(*run.CredentialID, 10)
+		}
+
+		duration := "-"
+		if run.FinishedAt != nil {
+			duration = run.Duration().Round(time.Second).String()
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			run.ID,
+			run.JobName,
+			run.Vendor,
+			credential,
+			run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			duration,
+			run.Status,
+			run.DocumentsIndexed,
+			run.SitesUpserted,
+			run.Error,
+		)
+	}
+	w.Flush()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	logger.Init("mapping.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+// main checks the installed index templates and the mapping of every live
+// index against the expected ones and prints the differences. It exits with
+// status 1 when anything differs. With -install the templates and lifecycle
+// policies are installed first, a failed install also exits with status 1.
+func main() {
+	install := flag.Bool("install", false, "Install the index templates and lifecycle policies before checking")
+	templatesOnly := flag.Bool("templates", false, "Check the templates only, not the live indices")
+	flag.Parse()
+
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	client, err := container.Elastic()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error connect elasticsearch")
+	}
+
+	// A partial install, e.g. templates without their lifecycle policy, is
+	// still checked and fails the command.
+	templateRepo := repo.NewIndexTemplateRepo(client)
+	installed := true
+	if *install {
+		if err := templateRepo.Install(conf.Elastic); err != nil {
+			log.Error().Err(err).Msg("error install index templates")
+			installed = false
+		} else {
+			log.Info().Int("version", repo.IndexTemplateVersion).Msg("index templates installed")
+		}
+	}
+
+	mismatches, err := templateRepo.CheckTemplates()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error check index templates")
+	}
+
+	if !*templatesOnly {
+		indexMismatches, err := templateRepo.CheckIndices()
+		if err != nil {
+			log.Fatal().Err(err).Msg("error check index mappings")
+		}
+		mismatches = append(mismatches, indexMismatches...)
+	}
+
+	if len(mismatches) == 0 {
+		log.Info().Msg("index mappings match the templates")
+	} else {
+		printMismatches(mismatches)
+	}
+
+	if len(mismatches) > 0 || !installed {
+		container.Close()
+		os.Exit(1)
+	}
+}
+
+func printMismatches(mismatches []repo.MappingMismatch) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tFIELD\tEXPECTED\tACTUAL")
+	for _, mismatch := range mismatches {
+		actual := mismatch.Actual
+		if actual == "" {
+			actual = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mismatch.Index, mismatch.Field, mismatch.Expected, actual)
+	}
+	w.Flush()
+}
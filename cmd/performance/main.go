@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/HavvokLab/true-solar/alarm"
 	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/migration"
 	"github.com/HavvokLab/true-solar/pkg/logger"
 	"github.com/HavvokLab/true-solar/repo"
 	"github.com/go-co-op/gocron"
@@ -23,23 +28,59 @@ func init() {
 	time.Local = loc
 }
 
+var container *infra.Container
+
 func main() {
-	cfg := config.GetConfig()
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container = infra.NewContainer(conf)
+	defer container.Close()
+
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error open database")
+	}
+
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(false)); err != nil {
+		log.Fatal().Err(err).Msg("error prepare database")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	cron := gocron.NewScheduler(time.Local)
-	cron.Cron(cfg.Crontab.LowPerformanceAlarmTime).StartImmediately().SingletonMode().Do(lowPerformanceAlarm)
-	cron.Cron(cfg.Crontab.SumPerformanceAlarmTime).StartImmediately().SingletonMode().Do(sumPerformanceAlarm)
-	cron.StartBlocking()
+	cron.Cron(conf.Crontab.LowPerformanceAlarmTime).StartImmediately().SingletonMode().Do(lowPerformanceAlarm)
+	cron.Cron(conf.Crontab.SumPerformanceAlarmTime).StartImmediately().SingletonMode().Do(sumPerformanceAlarm)
+	cron.StartAsync()
+
+	// Stop waits for running jobs before the connections are closed.
+	<-ctx.Done()
+	cron.Stop()
 }
 
 func lowPerformanceAlarm() {
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
 
-	solarRepo := repo.NewSolarRepo(infra.ElasticClient)
-	installedCapacityRepo := repo.NewInstalledCapacityRepo(infra.GormDB)
-	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
+	}
+
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+
+	installedCapacityRepo := repo.NewInstalledCapacityRepo(db)
+	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(db)
 	lowAlarm := alarm.NewLowPerformanceAlarm(
 		solarRepo,
 		installedCapacityRepo,
@@ -63,14 +104,25 @@ func lowPerformanceAlarm() {
 }
 
 func sumPerformanceAlarm() {
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		log.Panic().Err(err).Msg("error create snmp orchestrator")
 	}
 
-	solarRepo := repo.NewSolarRepo(infra.ElasticClient)
-	installedCapacityRepo := repo.NewInstalledCapacityRepo(infra.GormDB)
-	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		log.Panic().Err(err).Msg("error open database")
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		log.Panic().Err(err).Msg("error connect elasticsearch")
+	}
+
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+
+	installedCapacityRepo := repo.NewInstalledCapacityRepo(db)
+	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(db)
 	sumAlarm := alarm.NewSumPerformanceAlarm(
 		solarRepo,
 		installedCapacityRepo,
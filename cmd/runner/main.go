@@ -1,37 +1,48 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/HavvokLab/true-solar/alarm"
-	"github.com/HavvokLab/true-solar/collector"
 	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/credential"
 	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/ledger"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
+	"github.com/HavvokLab/true-solar/registry"
 	"github.com/HavvokLab/true-solar/repo"
+	"github.com/HavvokLab/true-solar/spool"
 	"github.com/go-co-op/gocron"
+	"github.com/olivere/elastic/v7"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/sourcegraph/conc"
 )
 
 const (
-	huaweiSupportedVersion  = 1
-	huawei2SupportedVersion = 2
-
 	lowPerformanceMaxRetries = 5
 	lowPerformanceRetryDelay = 5 * time.Minute
 )
 
 var (
-	growattJobLogger     = newVendorLogger("growatt.log")
-	kstarJobLogger       = newVendorLogger("kstar.log")
-	huaweiJobLogger      = newVendorLogger("huawei.log")
-	huawei2JobLogger     = newVendorLogger("huawei2.log")
-	solarmanJobLogger    = newVendorLogger("solarman.log")
+	vendorJobLoggers     = make(map[string]zerolog.Logger)
 	clearAlarmJobLogger  = newVendorLogger("clear_alarm.log")
 	performanceJobLogger = newVendorLogger("performance_alarm.log")
+	container            *infra.Container
+	jobLedger            *ledger.Ledger
+	collectSpool         *spool.Spool
+	credentialSource     credential.CredentialSource
 )
 
 func main() {
@@ -40,122 +51,121 @@ func main() {
 		time.Local = loc
 	}
 
-	cron := gocron.NewScheduler(time.Local)
-	if err := registerJobs(cron); err != nil {
-		log.Fatal().Err(err).Msg("failed to register runner jobs")
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load config")
 	}
 
-	log.Info().Msg("starting runner scheduler")
-	cron.StartBlocking()
-}
+	container = infra.NewContainer(conf)
+	defer container.Close()
 
-func registerJobs(cron *gocron.Scheduler) error {
-	registrars := []func(*gocron.Scheduler) error{
-		scheduleGrowattJobs,
-		scheduleKstarJobs,
-		scheduleHuaweiJobs,
-		scheduleHuawei2Jobs,
-		scheduleSolarmanJobs,
-		schedulePerformanceJobs,
+	db, err := container.DB()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to open database")
 	}
 
-	for _, registrar := range registrars {
-		if err := registrar(cron); err != nil {
-			return err
-		}
+	// The runner owns the schema: it migrates unless auto_migrate is false.
+	if err := migration.Prepare(db, conf.Database.MigrateOnOpen(true)); err != nil {
+		log.Fatal().Err(err).Msg("failed to prepare database")
 	}
 
-	return nil
-}
-
-func scheduleGrowattJobs(cron *gocron.Scheduler) error {
-	cfg := config.GetConfig()
-	if err := addCronJob(cron, cfg.Crontab.CollectTime, "growatt_collect", growattJobLogger, func() error {
-		return runGrowattCollect(growattJobLogger)
-	}); err != nil {
-		return err
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load credential key")
 	}
 
-	if err := addCronJob(cron, cfg.Crontab.AlarmTime, "growatt_alarm", growattJobLogger, func() error {
-		return runGrowattAlarm(growattJobLogger)
-	}); err != nil {
-		return err
+	es, err := container.Elastic()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect elasticsearch")
 	}
 
-	return nil
-}
+	jobLedger = ledger.NewLedger(repo.NewJobRunRepo(db))
+	collectSpool = newCollectSpool(conf.Spool)
 
-func scheduleKstarJobs(cron *gocron.Scheduler) error {
-	cfg := config.GetConfig()
-	if err := addCronJob(cron, cfg.Crontab.CollectTime, "kstar_collect", kstarJobLogger, func() error {
-		return runKstarCollect(kstarJobLogger)
-	}); err != nil {
-		return err
+	// Collection must not stop on a cluster that refuses the templates, the
+	// indices are then created with dynamic mapping as before.
+	if err := repo.NewIndexTemplateRepo(es).Install(conf.Elastic); err != nil {
+		log.Error().Err(err).Msg("failed to install index templates")
 	}
 
-	if err := addCronJob(cron, cfg.Crontab.AlarmTime, "kstar_alarm", kstarJobLogger, func() error {
-		return runKstarAlarm(kstarJobLogger)
-	}); err != nil {
-		return err
+	source, err := credential.NewSource(db, cipher, conf.Credentials)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create credential source")
 	}
+	credentialSource = source
 
-	return nil
-}
+	startMetricsServer(conf.Metrics.Address)
 
-func scheduleHuaweiJobs(cron *gocron.Scheduler) error {
-	cfg := config.GetConfig()
-	if err := addCronJob(cron, cfg.Crontab.CollectTime, "huawei_collect", huaweiJobLogger, func() error {
-		return runHuaweiCollect(huaweiJobLogger)
-	}); err != nil {
-		return err
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := addCronJob(cron, cfg.Crontab.AlarmTime, "huawei_alarm", huaweiJobLogger, func() error {
-		return runHuaweiAlarm(huaweiJobLogger)
-	}); err != nil {
-		return err
+	cron := gocron.NewScheduler(time.Local)
+	if err := registerJobs(cron); err != nil {
+		log.Fatal().Err(err).Msg("failed to register runner jobs")
 	}
 
-	return nil
+	log.Info().Msg("starting runner scheduler")
+	cron.StartAsync()
+
+	// Running jobs finish before the connections are closed.
+	<-ctx.Done()
+	log.Info().Msg("stopping runner scheduler")
+	cron.Stop()
 }
 
-func scheduleHuawei2Jobs(cron *gocron.Scheduler) error {
-	cfg := config.GetConfig()
-	if err := addCronJob(cron, cfg.Crontab.CollectTime, "huawei2_collect", huawei2JobLogger, func() error {
-		return runHuawei2Collect(huawei2JobLogger)
-	}); err != nil {
-		return err
+func registerJobs(cron *gocron.Scheduler) error {
+	for _, module := range registry.Modules() {
+		if err := scheduleVendorJobs(cron, module); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return schedulePerformanceJobs(cron)
 }
 
-func scheduleSolarmanJobs(cron *gocron.Scheduler) error {
-	cfg := config.GetConfig()
-	if err := addCronJob(cron, cfg.Crontab.CollectTime, "solarman_collect", solarmanJobLogger, func() error {
-		return runSolarmanCollect(solarmanJobLogger)
-	}); err != nil {
-		return err
+func scheduleVendorJobs(cron *gocron.Scheduler, module registry.Module) error {
+	jobLogger := vendorJobLogger(module.Name)
+
+	if module.HasCollector() {
+		if err := scheduleVendorJob(cron, module, config.CrontabJobCollect, jobLogger, func() error {
+			return runVendorCollect(module, jobLogger)
+		}); err != nil {
+			return err
+		}
 	}
 
-	if err := addCronJob(cron, cfg.Crontab.AlarmTime, "solarman_alarm", solarmanJobLogger, func() error {
-		return runSolarmanAlarm(solarmanJobLogger)
-	}); err != nil {
-		return err
+	if module.HasAlarm() {
+		if err := scheduleVendorJob(cron, module, config.CrontabJobAlarm, jobLogger, func() error {
+			return runVendorAlarm(module, jobLogger)
+		}); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func scheduleVendorJob(cron *gocron.Scheduler, module registry.Module, job string, jobLogger zerolog.Logger, fn func() error) error {
+	name := module.Name + "_" + job
+	cronExpr, jitter := container.Config().Crontab.Schedule(module.Name, job)
+	if cronExpr == "" {
+		log.Info().Str("job", name).Msg("no schedule configured or job turned off, job disabled")
+		return nil
+	}
+
+	log.Info().Str("job", name).Str("cron", cronExpr).Dur("jitter", jitter).Msg("job scheduled")
+	return addCronJob(cron, cronExpr, jitter, name, module.Name, jobLogger, fn)
+}
+
 func schedulePerformanceJobs(cron *gocron.Scheduler) error {
-	cfg := config.GetConfig()
-	if err := addCronJob(cron, cfg.Crontab.LowPerformanceAlarmTime, "low_performance_alarm", performanceJobLogger, func() error {
+	cfg := container.Config()
+	if err := addCronJob(cron, cfg.Crontab.LowPerformanceAlarmTime, 0, "low_performance_alarm", "", performanceJobLogger, func() error {
 		return runLowPerformanceAlarm(performanceJobLogger)
 	}); err != nil {
 		return err
 	}
 
-	if err := addCronJob(cron, cfg.Crontab.SumPerformanceAlarmTime, "sum_performance_alarm", performanceJobLogger, func() error {
+	if err := addCronJob(cron, cfg.Crontab.SumPerformanceAlarmTime, 0, "sum_performance_alarm", "", performanceJobLogger, func() error {
 		return runSumPerformanceAlarm(performanceJobLogger)
 	}); err != nil {
 		return err
@@ -164,9 +174,16 @@ func schedulePerformanceJobs(cron *gocron.Scheduler) error {
 	return nil
 }
 
-func addCronJob(cron *gocron.Scheduler, cronExpr, name string, jobLogger zerolog.Logger, fn func() error) error {
+// addCronJob schedules fn under name. When jitter is positive each run is
+// delayed by a random duration up to jitter, spreading vendors that share
+// the same cron expression.
+func addCronJob(cron *gocron.Scheduler, cronExpr string, jitter time.Duration, name, vendor string, jobLogger zerolog.Logger, fn func() error) error {
 	if _, err := cron.Cron(cronExpr).StartImmediately().SingletonMode().Do(func() {
-		safeRun(jobLogger, name, fn)
+		if jitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(jitter))))
+		}
+
+		safeRun(jobLogger, name, vendor, fn)
 	}); err != nil {
 		return fmt.Errorf("failed to schedule %s: %w", name, err)
 	}
@@ -174,441 +191,290 @@ func addCronJob(cron *gocron.Scheduler, cronExpr, name string, jobLogger zerolog
 	return nil
 }
 
-func safeRun(jobLogger zerolog.Logger, name string, fn func() error) {
+// safeRun runs a job, recording it in the job ledger as a job-level run.
+func safeRun(jobLogger zerolog.Logger, name, vendor string, fn func() error) {
 	log := jobLogger.With().Str("job", name).Logger()
 	log.Info().Msg("job started")
+	run := jobLedger.Start(name, vendor, nil)
+
+	var err error
 	defer func() {
 		if r := recover(); r != nil {
 			log.Error().Any("recover", r).Msg("job panicked")
+			err = fmt.Errorf("job panicked: %v", r)
 		}
+
+		jobLedger.Finish(run, err, 0, 0)
 	}()
 
-	if err := fn(); err != nil {
+	if err = fn(); err != nil {
 		log.Error().Err(err).Msg("job finished with error")
 		return
 	}
 
+	metrics.SetJobLastSuccess(name, time.Now())
 	log.Info().Msg("job finished successfully")
 }
 
-func runGrowattCollect(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "growatt_collect")
-
-	credRepo := repo.NewGrowattCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find growatt credentials")
-		return err
-	}
-
-	if len(credentials) == 0 {
-		jobLogger.Info().Msg("no growatt credentials found")
-		return nil
-	}
-
-	wg := conc.NewWaitGroup()
-	now := time.Now()
-	for _, credential := range credentials {
-		cred := credential
-		wg.Go(func() {
-			serv := collector.NewGrowattCollector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
-			)
-
-			serv.Execute(now, &cred)
-		})
+// startMetricsServer serves /metrics in the background when address is set.
+func startMetricsServer(address string) {
+	if address == "" {
+		return
 	}
 
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("growatt collect panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("collector recovered from panic")
-		return err
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	return nil
+	go func() {
+		log.Info().Str("address", address).Msg("starting metrics server")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("metrics server stopped")
+		}
+	}()
 }
 
-func runGrowattAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "growatt_alarm")
-
-	credRepo := repo.NewGrowattCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find growatt credentials")
-		return err
+func runVendorCollect(module registry.Module, jobLogger zerolog.Logger) error {
+	// A partial failure still runs the resolved credentials, but fails the job.
+	credentials, sourceErr := credentialSource.FindAll(context.Background(), module.Name)
+	if sourceErr != nil {
+		jobLogger.Error().Err(sourceErr).Msgf("failed to find %s credentials", module.Name)
+		if len(credentials) == 0 {
+			return sourceErr
+		}
 	}
 
 	if len(credentials) == 0 {
-		jobLogger.Info().Msg("no growatt credentials found")
+		jobLogger.Info().Msgf("no %s credentials found", module.Name)
 		return nil
 	}
 
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeGrowattAlarm, config.GetConfig().SnmpList)
+	db, err := container.DB()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
+		jobLogger.Error().Err(err).Msg("failed to open database")
 		return err
 	}
 
-	rdb, err := infra.NewRedis()
+	es, err := container.Elastic()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to create redis client")
+		jobLogger.Error().Err(err).Msg("failed to connect elasticsearch")
 		return err
 	}
-	defer rdb.Close()
 
-	wg := conc.NewWaitGroup()
+	replaySpool(jobLogger)
+
+	var mu sync.Mutex
+	errs := []error{sourceErr}
+	wg := module.Pool(container.Config().Concurrency).NewGroup()
+	now := time.Now()
 	for _, credential := range credentials {
 		cred := credential
 		wg.Go(func() {
-			serv := alarm.NewGrowattAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
+			solarRepo := repo.NewCountingSolarRepo(newCollectSolarRepo(es))
+			serv := module.NewCollector(
+				solarRepo,
+				repo.NewSiteRegionMappingRepo(db),
 			)
 
-			serv.Run(&cred)
+			credentialKey := model.CredentialKey(cred)
+			run := jobLedger.Start(module.Name+"_collect", module.Name, cred)
+			err := serv.Execute(now, cred)
+			jobLedger.Finish(run, err, solarRepo.DocumentsIndexed(), solarRepo.SitesUpserted())
+			metrics.SetCollectedItems(module.Name, credentialKey, metrics.KindPlant, solarRepo.Plants())
+			metrics.SetCollectedItems(module.Name, credentialKey, metrics.KindDevice, solarRepo.Devices())
+			metrics.SetCollectedItems(module.Name, credentialKey, metrics.KindAlarm, solarRepo.Alarms())
+			if err != nil {
+				jobLogger.Error().Err(err).Str("credential", credentialKey).Msg("collector finished with error")
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("credential %s: %w", credentialKey, err))
+				mu.Unlock()
+			}
 		})
 	}
 
 	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("growatt alarm panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("alarm recovered from panic")
+		err := fmt.Errorf("%s collect panic: %v", module.Name, recovered.Value)
+		jobLogger.Error().Err(err).Msg("collector recovered from panic")
 		return err
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-func runKstarCollect(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "kstar_collect")
-
-	credRepo := repo.NewKStarCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find kstar credentials")
-		return err
-	}
-
-	if len(credentials) == 0 {
-		jobLogger.Info().Msg("no kstar credentials found")
+// newCollectSpool returns the spool of the collect jobs, nil when disabled.
+func newCollectSpool(conf config.SpoolConfig) *spool.Spool {
+	if conf.Disabled {
 		return nil
 	}
 
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		wg.Go(func() {
-			serv := collector.NewKstarCollector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
-			)
-
-			serv.Execute(&cred)
-		})
-	}
-
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("kstar collect panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("collector recovered from panic")
-		return err
-	}
-
-	return nil
+	return spool.NewSpool(conf)
 }
 
-func runKstarAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "kstar_alarm")
-
-	credRepo := repo.NewKStarCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find kstar credentials")
-		return err
+func newCollectSolarRepo(es *elastic.Client) repo.SolarRepo {
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+	if collectSpool == nil {
+		return solarRepo
 	}
 
-	if len(credentials) == 0 {
-		jobLogger.Info().Msg("no kstar credentials found")
-		return nil
-	}
+	return spool.NewSpoolingSolarRepo(solarRepo, collectSpool)
+}
 
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeKstarAlarm, config.GetConfig().SnmpList)
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
-		return err
+// replaySpool indexes the documents spooled by earlier collects before a new
+// collect starts. A failed replay is logged only, the collect still runs and
+// spools again if Elasticsearch is still unavailable.
+func replaySpool(jobLogger zerolog.Logger) {
+	if collectSpool == nil {
+		return
 	}
 
-	rdb, err := infra.NewRedis()
+	es, err := container.Elastic()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to create redis client")
-		return err
+		jobLogger.Error().Err(err).Msg("failed to connect elasticsearch, spool not replayed")
+		return
 	}
-	defer rdb.Close()
 
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		wg.Go(func() {
-			serv := alarm.NewKstarAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
-			)
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
 
-			serv.Run(&cred)
-		})
+	result, err := collectSpool.Replay(solarRepo)
+	if err != nil {
+		jobLogger.Error().Err(err).Int("documents", result.Documents).Int("kept", result.Kept).Int("quarantined", result.Quarantined).Msg("failed to replay spool")
+		return
 	}
 
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("kstar alarm panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("alarm recovered from panic")
-		return err
+	if result.Files > 0 {
+		jobLogger.Info().Int("files", result.Files).Int("documents", result.Documents).Int("duplicates", result.Duplicates).Int("corrupt", result.Corrupt).Int("quarantined", result.Quarantined).Msg("spool replayed")
 	}
-
-	return nil
 }
 
-func runHuaweiCollect(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "huawei_collect")
-
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find huawei credentials")
-		return err
-	}
-
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		if cred.Version != huaweiSupportedVersion {
-			continue
+func runVendorAlarm(module registry.Module, jobLogger zerolog.Logger) error {
+	// A partial failure still runs the resolved credentials, but fails the job.
+	credentials, sourceErr := credentialSource.FindAll(context.Background(), module.Name)
+	if sourceErr != nil {
+		jobLogger.Error().Err(sourceErr).Msgf("failed to find %s credentials", module.Name)
+		if len(credentials) == 0 {
+			return sourceErr
 		}
-
-		wg.Go(func() {
-			serv := collector.NewHuaweiCollector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
-			)
-
-			serv.Execute(&cred)
-		})
-	}
-
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("huawei collect panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("collector recovered from panic")
-		return err
 	}
 
-	return nil
-}
-
-func runHuaweiAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "huawei_alarm")
-
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find huawei credentials")
-		return err
+	if len(credentials) == 0 {
+		jobLogger.Info().Msgf("no %s credentials found", module.Name)
+		return nil
 	}
 
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeHuaweiAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(module.TrapType)
 	if err != nil {
 		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
 		return err
 	}
 
-	rdb, err := infra.NewRedis()
+	rdb, err := container.Redis()
 	if err != nil {
 		jobLogger.Error().Err(err).Msg("failed to create redis client")
 		return err
 	}
-	defer rdb.Close()
 
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		if cred.Version != huaweiSupportedVersion {
-			continue
-		}
-
-		wg.Go(func() {
-			serv := alarm.NewHuaweiAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
-			)
-
-			serv.Run(&cred)
-		})
-	}
-
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("huawei alarm panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("alarm recovered from panic")
-		return err
-	}
-
-	return nil
-}
-
-func runHuawei2Collect(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "huawei2_collect")
+	state := alarm.NewStateStore(rdb, container.Config().Alarm)
 
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+	es, err := container.Elastic()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find huawei credentials")
+		jobLogger.Error().Err(err).Msg("failed to connect elasticsearch")
 		return err
 	}
 
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		if cred.Version != huawei2SupportedVersion {
-			continue
-		}
-
-		wg.Go(func() {
-			serv := collector.NewHuawei2Collector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
-			)
-
-			serv.Execute(&cred)
-		})
-	}
-
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("huawei2 collect panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("collector recovered from panic")
-		return err
-	}
-
-	return nil
-}
-
-func runSolarmanCollect(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "solarman_collect")
-
-	credRepo := repo.NewSolarmanCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+	db, err := container.DB()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find solarman credentials")
+		jobLogger.Error().Err(err).Msg("failed to open database")
 		return err
 	}
 
-	if len(credentials) == 0 {
-		jobLogger.Info().Msg("no solarman credentials found")
-		return nil
-	}
-
-	wg := conc.NewWaitGroup()
-	now := time.Now()
+	var mu sync.Mutex
+	errs := []error{sourceErr}
+	wg := module.Pool(container.Config().Concurrency).NewGroup()
 	for _, credential := range credentials {
 		cred := credential
 		wg.Go(func() {
-			serv := collector.NewSolarmanCollector(
-				repo.NewSolarRepo(infra.ElasticClient),
-				repo.NewSiteRegionMappingRepo(infra.GormDB),
+			serv := module.NewAlarm(
+				repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+				repo.NewSiteRegionMappingRepo(db),
+				repo.NewAlarmSeverityMappingRepo(db),
+				repo.NewMaintenanceWindowRepo(db),
+				snmp,
+				state,
 			)
 
-			serv.Execute(now, &cred)
+			credentialKey := model.CredentialKey(cred)
+			run := jobLedger.Start(module.Name+"_alarm", module.Name, cred)
+			err := serv.Run(cred)
+			jobLedger.Finish(run, err, 0, 0)
+			if err != nil {
+				jobLogger.Error().Err(err).Str("credential", credentialKey).Msg("alarm finished with error")
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("credential %s: %w", credentialKey, err))
+				mu.Unlock()
+			}
 		})
 	}
 
 	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("solarman collect panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("collector recovered from panic")
+		err := fmt.Errorf("%s alarm panic: %v", module.Name, recovered.Value)
+		jobLogger.Error().Err(err).Msg("alarm recovered from panic")
 		return err
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-func runSolarmanAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "solarman_alarm")
-
-	credRepo := repo.NewSolarmanCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
-	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to find solarman credentials")
-		return err
-	}
-
-	if len(credentials) == 0 {
-		jobLogger.Info().Msg("no solarman credentials found")
-		return nil
-	}
-
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeSolarmanAlarm, config.GetConfig().SnmpList)
+func runClearPerformanceAlarm(jobLogger zerolog.Logger) error {
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
 		return err
 	}
 
-	rdb, err := infra.NewRedis()
+	es, err := container.Elastic()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to create redis client")
+		jobLogger.Error().Err(err).Msg("failed to connect elasticsearch")
 		return err
 	}
-	defer rdb.Close()
 
-	wg := conc.NewWaitGroup()
-	for _, credential := range credentials {
-		cred := credential
-		wg.Go(func() {
-			serv := alarm.NewSolarmanAlarm(
-				repo.NewSolarRepo(infra.ElasticClient),
-				snmp,
-				rdb,
-			)
-
-			serv.Run(&cred)
-		})
-	}
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
 
-	if recovered := wg.WaitAndRecover(); recovered != nil {
-		err := fmt.Errorf("solarman alarm panic: %v", recovered.Value)
-		jobLogger.Error().Err(err).Msg("alarm recovered from panic")
+	clearAlarm := alarm.NewClearAlarm(solarRepo, snmp)
+	if err := clearAlarm.ClearPerformanceAlarm(); err != nil {
+		jobLogger.Error().Err(err).Msg("failed to clear performance alarm")
 		return err
 	}
 
 	return nil
 }
 
-func runClearPerformanceAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "clear_performance_alarm")
-
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+func runLowPerformanceAlarm(jobLogger zerolog.Logger) error {
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
 		return err
 	}
 
-	clearAlarm := alarm.NewClearAlarm(repo.NewSolarRepo(infra.ElasticClient), snmp)
-	if err := clearAlarm.ClearPerformanceAlarm(); err != nil {
-		jobLogger.Error().Err(err).Msg("failed to clear performance alarm")
+	db, err := container.DB()
+	if err != nil {
+		jobLogger.Error().Err(err).Msg("failed to open database")
 		return err
 	}
 
-	return nil
-}
-
-func runLowPerformanceAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "low_performance_alarm")
-
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+	es, err := container.Elastic()
 	if err != nil {
-		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
+		jobLogger.Error().Err(err).Msg("failed to connect elasticsearch")
 		return err
 	}
 
-	solarRepo := repo.NewSolarRepo(infra.ElasticClient)
-	installedCapacityRepo := repo.NewInstalledCapacityRepo(infra.GormDB)
-	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(infra.GormDB)
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+
+	installedCapacityRepo := repo.NewInstalledCapacityRepo(db)
+	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(db)
 	lowAlarm := alarm.NewLowPerformanceAlarm(
 		solarRepo,
 		installedCapacityRepo,
@@ -639,17 +505,28 @@ func runLowPerformanceAlarm(jobLogger zerolog.Logger) error {
 }
 
 func runSumPerformanceAlarm(jobLogger zerolog.Logger) error {
-	defer guardJob(jobLogger, "sum_performance_alarm")
-
-	snmp, err := infra.NewSnmpOrchestrator(infra.TrapTypeClearAlarm, config.GetConfig().SnmpList)
+	snmp, err := container.Snmp(infra.TrapTypeClearAlarm)
 	if err != nil {
 		jobLogger.Error().Err(err).Msg("failed to create snmp orchestrator")
 		return err
 	}
 
-	solarRepo := repo.NewSolarRepo(infra.ElasticClient)
-	installedCapacityRepo := repo.NewInstalledCapacityRepo(infra.GormDB)
-	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(infra.GormDB)
+	db, err := container.DB()
+	if err != nil {
+		jobLogger.Error().Err(err).Msg("failed to open database")
+		return err
+	}
+
+	es, err := container.Elastic()
+	if err != nil {
+		jobLogger.Error().Err(err).Msg("failed to connect elasticsearch")
+		return err
+	}
+
+	solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+
+	installedCapacityRepo := repo.NewInstalledCapacityRepo(db)
+	performanceAlarmConfigRepo := repo.NewPerformanceAlarmConfigRepo(db)
 	sumAlarm := alarm.NewSumPerformanceAlarm(
 		solarRepo,
 		installedCapacityRepo,
@@ -665,15 +542,17 @@ func runSumPerformanceAlarm(jobLogger zerolog.Logger) error {
 	return nil
 }
 
-func newVendorLogger(file string) zerolog.Logger {
-	return zerolog.New(logger.NewWriter(file)).With().Timestamp().Caller().Logger()
+// vendorJobLogger returns the job logger of a vendor, writing to <vendor>.log.
+func vendorJobLogger(vendor string) zerolog.Logger {
+	if jobLogger, ok := vendorJobLoggers[vendor]; ok {
+		return jobLogger
+	}
+
+	jobLogger := newVendorLogger(vendor + ".log")
+	vendorJobLoggers[vendor] = jobLogger
+	return jobLogger
 }
 
-func guardJob(jobLogger zerolog.Logger, name string) {
-	if r := recover(); r != nil {
-		jobLogger.Error().
-			Str("job", name).
-			Any("recover", r).
-			Msg("job panicked, recovered to keep scheduler alive")
-	}
+func newVendorLogger(file string) zerolog.Logger {
+	return zerolog.New(logger.NewWriter(file)).With().Timestamp().Caller().Logger()
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/HavvokLab/true-solar/spool"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	logger.Init("spool.log")
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	time.Local = loc
+}
+
+func usage() {
+	fmt.Fprintln(flag.CommandLine.Output(), "Usage: spool <status|replay>")
+	fmt.Fprintln(flag.CommandLine.Output(), "  status  list the spool files")
+	fmt.Fprintln(flag.CommandLine.Output(), "  replay  index the spooled documents and remove the replayed files")
+}
+
+// main lists or replays the spool of documents the collectors failed to
+// index. The runner replays the spool before every collect, the replay
+// command is for replaying right after an Elasticsearch outage.
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("error load config")
+	}
+
+	container := infra.NewContainer(conf)
+	defer container.Close()
+
+	s := spool.NewSpool(conf.Spool)
+	switch flag.Arg(0) {
+	case "status":
+		files, err := s.Files()
+		if err != nil {
+			log.Fatal().Err(err).Msg("error list spool files")
+		}
+		printFiles(files)
+	case "replay":
+		// The replay indexes through the Elasticsearch repo only, documents
+		// still pending stay in their file instead of being spooled again.
+		es, err := container.Elastic()
+		if err != nil {
+			log.Fatal().Err(err).Msg("error connect elasticsearch")
+		}
+
+		solarRepo := repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool())
+
+		result, err := s.Replay(solarRepo)
+		if err != nil {
+			log.Fatal().Err(err).Int("documents", result.Documents).Int("kept", result.Kept).Int("quarantined", result.Quarantined).Msg("error replay spool")
+		}
+
+		log.Info().
+			Int("files", result.Files).
+			Int("documents", result.Documents).
+			Int("duplicates", result.Duplicates).
+			Int("corrupt", result.Corrupt).
+			Int("kept", result.Kept).
+			Int("quarantined", result.Quarantined).
+			Msg("spool replayed")
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func printFiles(files []spool.File) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tINDEX\tBYTES\tMODIFIED\tCORRUPT")
+	for _, file := range files {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%t\n",
+			file.Name,
+			file.Index,
+			file.Bytes,
+			file.ModTime.Local().Format("2006-01-02 15:04:05"),
+			file.Corrupt,
+		)
+	}
+	w.Flush()
+}
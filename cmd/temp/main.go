@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/gocarina/gocsv"
@@ -168,8 +169,18 @@ func main() {
 	}
 	log.Info().Int("records", len(temps)).Msg("CSV file unmarshaled successfully")
 
+	conf, err := config.Load("")
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	client, err := infra.NewElasticClient(conf.Elastic)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create elastic client")
+	}
+	defer infra.CloseElasticClient(client)
+
 	// Prepare msearch
-	client := infra.ElasticClient
 	ctx := context.Background()
 	var msearchRequests []SearchPayload
 
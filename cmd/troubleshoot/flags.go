@@ -9,7 +9,7 @@ import (
 // parseFlags parses the workerPoolSize, startDate, endDate, and vendor flags and returns them.
 func parseFlags() (time.Time, time.Time, string) {
 	// Define flags
-	workerPoolSize := flag.Int("workerPoolSize", 5, "Worker pool size")
+	workerPoolSize := flag.Int("workerPoolSize", 0, "Worker pool size (0 uses the configured vendor concurrency)")
 	startDateStr := flag.String("startDate", "", "Start date in format YYYY-MM-DD")
 	endDateStr := flag.String("endDate", "", "End date in format YYYY-MM-DD")
 	vendor := flag.String("vendor", "", "Vendor name")
@@ -4,17 +4,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/infra"
-	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/migration"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/pool"
+	"github.com/HavvokLab/true-solar/registry"
 	"github.com/HavvokLab/true-solar/repo"
-	"github.com/HavvokLab/true-solar/troubleshoot"
-	"github.com/gammazero/workerpool"
 	"github.com/rs/zerolog/log"
 )
 
 var (
-	WorkerPoolSize = 5
+	// WorkerPoolSize overrides the configured vendor concurrency when positive.
+	WorkerPoolSize = 0
 )
 
 func init() {
@@ -25,108 +27,65 @@ func init() {
 
 func main() {
 	start, end, vendor := parseFlags()
-	switch strings.ToLower(vendor) {
-	case model.VendorTypeGrowatt:
-		collectGrowatt(start, end)
-	case model.VendorTypeInvt:
-		collectSolarman(start, end)
-	case model.VendorTypeKstar:
-		collectKstar(start, end)
-	case model.VendorTypeHuawei:
-		collectHuawei(start, end)
-	default:
+	module, ok := registry.Lookup(strings.ToLower(vendor))
+	if !ok || !module.HasTroubleshooter() {
 		log.Panic().Msgf("vendor %s not supported", vendor)
 	}
-}
 
-func collectGrowatt(start, end time.Time) {
-	credRepo := repo.NewGrowattCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+	conf, err := config.Load("")
 	if err != nil {
-		log.Panic().Err(err).Msg("error find all credentials")
+		log.Panic().Err(err).Msg("error load config")
 	}
 
-	pool := workerpool.New(WorkerPoolSize)
-	for _, credential := range credentials {
-		serv := troubleshoot.NewGrowattTroubleshoot(
-			repo.NewSolarRepo(infra.ElasticClient),
-			repo.NewSiteRegionMappingRepo(infra.GormDB),
-		)
+	container := infra.NewContainer(conf)
+	defer container.Close()
 
-		clone := credential
-		pool.Submit(func() {
-			serv.ExecuteByRange(&clone, start, end)
-		})
-	}
-	pool.StopWait()
+	collect(container, module, start, end)
 }
 
-func collectSolarman(start, end time.Time) {
-	credRepo := repo.NewSolarmanCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+func collect(container *infra.Container, module registry.Module, start, end time.Time) {
+	db, err := container.DB()
 	if err != nil {
-		log.Panic().Err(err).Msg("error find all credentials")
+		log.Panic().Err(err).Msg("error open database")
 	}
 
-	pool := workerpool.New(WorkerPoolSize)
-	for _, credential := range credentials {
-		serv := troubleshoot.NewSolarmanTroubleshoot(
-			repo.NewSolarRepo(infra.ElasticClient),
-			repo.NewSiteRegionMappingRepo(infra.GormDB),
-		)
-
-		clone := credential
-		pool.Submit(func() {
-			serv.ExecuteByRange(&clone, start, end)
-		})
+	if err := migration.Prepare(db, container.Config().Database.MigrateOnOpen(false)); err != nil {
+		log.Panic().Err(err).Msg("error prepare database")
 	}
-	pool.StopWait()
-}
 
-func collectKstar(start, end time.Time) {
-	credRepo := repo.NewKStarCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+	es, err := container.Elastic()
 	if err != nil {
-		log.Panic().Err(err).Msg("error find all credentials")
+		log.Panic().Err(err).Msg("error connect elasticsearch")
 	}
 
-	pool := workerpool.New(WorkerPoolSize)
-	for _, credential := range credentials {
-		serv := troubleshoot.NewKstarTroubleshoot(
-			repo.NewSolarRepo(infra.ElasticClient),
-			repo.NewSiteRegionMappingRepo(infra.GormDB),
-		)
-
-		clone := credential
-		pool.Submit(func() {
-			serv.ExecuteByRange(&clone, start, end)
-		})
+	cipher, err := container.CredentialCipher()
+	if err != nil {
+		log.Panic().Err(err).Msg("error load credential key")
 	}
-	pool.StopWait()
-}
 
-func collectHuawei(start, end time.Time) {
-	credRepo := repo.NewHuaweiCredentialRepo(infra.GormDB)
-	credentials, err := credRepo.FindAll()
+	credentials, err := module.Credentials(db, cipher)
 	if err != nil {
 		log.Panic().Err(err).Msg("error find all credentials")
 	}
 
-	pool := workerpool.New(WorkerPoolSize)
-	for _, credential := range credentials {
-		if credential.Version != 1 {
-			continue
-		}
+	workers := module.Pool(container.Config().Concurrency)
+	if WorkerPoolSize > 0 {
+		workers = pool.New(WorkerPoolSize)
+	}
 
-		serv := troubleshoot.NewHuaweiTroubleshoot(
-			repo.NewSolarRepo(infra.ElasticClient),
-			repo.NewSiteRegionMappingRepo(infra.GormDB),
+	group := workers.NewGroup()
+	for _, credential := range credentials {
+		serv := module.NewTroubleshooter(
+			repo.NewSolarRepo(es, container.Config().Elastic.Bulk, container.BulkPool()),
+			repo.NewSiteRegionMappingRepo(db),
 		)
 
 		clone := credential
-		pool.Submit(func() {
-			serv.ExecuteByRange(&clone, start, end)
+		group.Go(func() {
+			if err := serv.ExecuteByRange(clone, start, end); err != nil {
+				log.Error().Err(err).Int64("credential_id", clone.GetID()).Msg("error execute troubleshoot")
+			}
 		})
 	}
-	pool.StopWait()
+	group.Wait()
 }
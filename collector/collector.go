@@ -0,0 +1,18 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+)
+
+// Collector fetches plant, device and alarm data for a single credential
+// and indexes the result into Elasticsearch.
+type Collector interface {
+	Execute(now time.Time, credential model.Credential) error
+}
+
+func invalidCredentialError(collector string, credential model.Credential) error {
+	return fmt.Errorf("%s: unsupported credential type %T", collector, credential)
+}
@@ -23,11 +23,13 @@ type GrowattCollector struct {
 	siteRegionRepo repo.SiteRegionMappingRepo
 	siteRegions    []model.SiteRegionMapping
 	logger         zerolog.Logger
+	clientOptions  []growatt.Option
 }
 
 func NewGrowattCollector(
 	solarRepo repo.SolarRepo,
 	siteRegionRepo repo.SiteRegionMappingRepo,
+	clientOptions ...growatt.Option,
 ) *GrowattCollector {
 	return &GrowattCollector{
 		vendorType:     strings.ToUpper(model.VendorTypeGrowatt),
@@ -35,30 +37,88 @@ func NewGrowattCollector(
 		siteRegionRepo: siteRegionRepo,
 		siteRegions:    make([]model.SiteRegionMapping, 0),
 		logger:         zerolog.New(logger.NewWriter("growatt_collector.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:  clientOptions,
 	}
 }
 
-func (g *GrowattCollector) Execute(now time.Time, credential *model.GrowattCredential) {
+func (g *GrowattCollector) Execute(now time.Time, cred model.Credential) (err error) {
+	credential, ok := cred.(*model.GrowattCredential)
+	if !ok {
+		return invalidCredentialError("GrowattCollector", cred)
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
 			g.logger.Error().Any("recover", r).Msg("GrowattCollector::Execute() - panic")
+			err = fmt.Errorf("GrowattCollector::Execute() - panic: %v", r)
 		}
 	}()
 
 	siteRegions, err := g.siteRegionRepo.GetSiteRegionMappings()
 	if err != nil {
 		g.logger.Error().Err(err).Msg("GrowattCollector::Execute() - failed to get site region mappings")
-		return
+		return err
 	}
 
 	g.siteRegions = siteRegions
-	plantDeviceStatusMap := make(map[string]string)
-	inverterArray := make([]string, 0)
-	documents := make([]interface{}, 0)
+	index := fmt.Sprintf("%s-%s", model.SolarIndex, time.Now().Format("2006.01.02"))
+	processor := repo.NewBulkProcessor(g.solarRepo, index)
 	siteDocuments := make([]model.SiteItem, 0)
 
+	// Plants wait for the status of their devices and inverters for their
+	// productions, fetched in batches of the Growatt batch size.
+	pendingPlants := make(map[string]model.PlantItem)
+	pendingInverters := make([]model.DeviceItem, 0, growatt.BatchSize)
+
+	add := func(doc interface{}) {
+		if addErr := processor.Add(doc); addErr != nil {
+			g.logger.Error().Err(addErr).Msg("GrowattCollector::Execute() - failed to add document")
+			err = addErr
+		}
+	}
+
+	addPlant := func(plantItem model.PlantItem) {
+		add(plantItem)
+		siteDocuments = append(siteDocuments, model.SiteItem{
+			Timestamp:   plantItem.Timestamp,
+			VendorType:  plantItem.VendorType,
+			Area:        plantItem.Area,
+			SiteID:      plantItem.SiteID,
+			NodeType:    plantItem.NodeType,
+			Name:        plantItem.Name,
+			Location:    plantItem.Location,
+			PlantStatus: plantItem.PlantStatus,
+			Owner:       plantItem.Owner,
+		})
+	}
+
+	addInverters := func() {
+		if len(pendingInverters) == 0 {
+			return
+		}
+
+		inverterSNs := make([]string, 0, len(pendingInverters))
+		for _, deviceItem := range pendingInverters {
+			inverterSNs = append(inverterSNs, pointy.StringValue(deviceItem.SN, ""))
+		}
+
+		realtimeDeviceMap, productionErr := g.CalculateInverterProductions(credential, inverterSNs)
+		if productionErr != nil {
+			g.logger.Error().Err(productionErr).Msg("GrowattCollector::Execute() - failed to calculate inverter productions")
+		}
+
+		for _, deviceItem := range pendingInverters {
+			if data, ok := realtimeDeviceMap[pointy.StringValue(deviceItem.SN, "")]; ok {
+				deviceItem.TotalPowerGeneration = data.Total
+				deviceItem.DailyPowerGeneration = data.Today
+			}
+			add(deviceItem)
+		}
+		pendingInverters = pendingInverters[:0]
+	}
+
 	documentCh := make(chan interface{})
-	inverterCh := make(chan string)
+	inverterCh := make(chan model.DeviceItem)
 	plantDeviceStatusCh := make(chan map[string]string)
 	doneCh := make(chan bool)
 	errorCh := make(chan error)
@@ -69,78 +129,54 @@ DONE:
 		select {
 		case <-doneCh:
 			break DONE
-		case err := <-errorCh:
-			g.logger.Error().Err(err).Msg("GrowattCollector::Execute() - failed")
-			return
+		case collectErr := <-errorCh:
+			g.logger.Error().Err(collectErr).Msg("GrowattCollector::Execute() - failed")
+			err = collectErr
+			break DONE
 		case doc := <-documentCh:
-			documents = append(documents, doc)
-		case plantDeviceStatus := <-plantDeviceStatusCh:
-			if err := mapstructure.Decode(&plantDeviceStatus, &plantDeviceStatusMap); err != nil {
-				g.logger.Error().Err(err).Msg("GrowattCollector::Execute() - failed to decode plant device status")
-				return
+			if plantItem, ok := doc.(model.PlantItem); ok && plantItem.ID != nil {
+				pendingPlants[*plantItem.ID] = plantItem
+				continue
 			}
-		case sn := <-inverterCh:
-			inverterArray = append(inverterArray, sn)
-		}
-	}
-
-	g.logger.Info().Msg("GrowattCollector::Execute() - calculating inverter productions")
-	realtimeDeviceMap, err := g.CalculateInverterProductions(
-		credential,
-		inverterArray,
-	)
-	g.logger.Info().Msg("GrowattCollector::Execute() - calculating inverter productions success")
-
-	if err != nil {
-		g.logger.Error().Err(err).Msg("GrowattCollector::Execute() - failed to calculate inverter productions")
-	}
-
-	for i, doc := range documents {
-		fmt.Println(i, "/", len(documents))
-		if plantItem, ok := doc.(model.PlantItem); ok {
-			if plantItem.ID != nil {
-				if plantStatus, found := plantDeviceStatusMap[*plantItem.ID]; found {
-					plantItem.PlantStatus = &plantStatus
-					documents[i] = plantItem
+			add(doc)
+		case plantDeviceStatus := <-plantDeviceStatusCh:
+			for plantID, plantStatus := range plantDeviceStatus {
+				plantItem, ok := pendingPlants[plantID]
+				if !ok {
+					continue
 				}
-			}
 
-			siteItem := model.SiteItem{
-				Timestamp:   plantItem.Timestamp,
-				VendorType:  plantItem.VendorType,
-				Area:        plantItem.Area,
-				SiteID:      plantItem.SiteID,
-				NodeType:    plantItem.NodeType,
-				Name:        plantItem.Name,
-				Location:    plantItem.Location,
-				PlantStatus: plantItem.PlantStatus,
-				Owner:       plantItem.Owner,
+				plantItem.PlantStatus = pointy.String(plantStatus)
+				addPlant(plantItem)
+				delete(pendingPlants, plantID)
 			}
-
-			siteDocuments = append(siteDocuments, siteItem)
-		} else if deviceItem, ok := doc.(model.DeviceItem); ok {
-			if deviceItem.SN != nil {
-				if data, ok := realtimeDeviceMap[*deviceItem.SN]; ok {
-					deviceItem.TotalPowerGeneration = data.Total
-					deviceItem.DailyPowerGeneration = data.Today
-					documents[i] = deviceItem
-				}
+		case deviceItem := <-inverterCh:
+			pendingInverters = append(pendingInverters, deviceItem)
+			if len(pendingInverters) >= growatt.BatchSize {
+				addInverters()
 			}
 		}
 	}
 
-	collectorIndex := fmt.Sprintf("%s-%s", model.SolarIndex, time.Now().Format("2006.01.02"))
-	if err := g.solarRepo.BulkIndex(collectorIndex, documents); err != nil {
-		g.logger.Error().Err(err).Msg("GrowattCollector::Execute() - failed to bulk index documents")
-		return
+	addInverters()
+	// Plants whose devices failed to load keep the offline status.
+	for _, plantItem := range pendingPlants {
+		addPlant(plantItem)
 	}
-	g.logger.Info().Int("count", len(documents)).Msg("GrowattCollector::Execute() - bulk index documents success")
 
-	if err := g.solarRepo.UpsertSiteStation(siteDocuments); err != nil {
-		g.logger.Error().Err(err).Msg("GrowattCollector::Execute() - failed to upsert site station")
-		return
+	if result, bulkErr := processor.Close(); bulkErr != nil {
+		g.logger.Error().Err(bulkErr).Msg("GrowattCollector::Execute() - failed to bulk index documents")
+		err = bulkErr
+	} else {
+		g.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("GrowattCollector::Execute() - bulk index documents success")
+	}
+
+	if result, upsertErr := g.solarRepo.UpsertSiteStation(siteDocuments); upsertErr != nil {
+		g.logger.Error().Err(upsertErr).Msg("GrowattCollector::Execute() - failed to upsert site station")
+		err = upsertErr
+	} else {
+		g.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("GrowattCollector::Execute() - upsert site station success")
 	}
-	g.logger.Info().Int("count", len(siteDocuments)).Msg("GrowattCollector::Execute() - upsert site station success")
 
 	g.logger.Info().Msg("GrowattCollector::Execute() - all goroutines finished")
 	close(documentCh)
@@ -148,18 +184,19 @@ DONE:
 	close(errorCh)
 	close(inverterCh)
 	close(plantDeviceStatusCh)
+	return err
 }
 
 func (g *GrowattCollector) Collect(
 	credential *model.GrowattCredential,
 	now time.Time,
 	docCh chan any,
-	inverterCh chan string,
+	inverterCh chan model.DeviceItem,
 	plantDeviceStatusCh chan map[string]string,
 	errCh chan error,
 	doneCh chan bool,
 ) {
-	client := growatt.NewGrowattClient(credential.Username, credential.Token)
+	client := growatt.NewGrowattClient(credential.Username, credential.Token, g.clientOptions...)
 
 	plantList, err := client.GetPlantList()
 	if err != nil {
@@ -751,7 +788,11 @@ func (g *GrowattCollector) Collect(
 					deviceStatusArray = append(deviceStatusArray, *deviceItem.Status)
 				}
 
-				docCh <- deviceItem
+				if deviceTypeRaw == growatt.GrowattDeviceTypeInverter && deviceItem.SN != nil {
+					inverterCh <- deviceItem
+				} else {
+					docCh <- deviceItem
+				}
 				g.logger.Info().
 					Str("plant_count", fmt.Sprintf("%v/%v", plantCount, plantSize)).
 					Str("device_count", fmt.Sprintf("%v/%v", deviceCount, deviceSize)).
@@ -761,10 +802,6 @@ func (g *GrowattCollector) Collect(
 					Str("device_id", deviceSn).
 					Any("device", deviceItem).
 					Msg("GrowattCollector::Collect() - device item added")
-
-				if deviceTypeRaw == growatt.GrowattDeviceTypeInverter {
-					inverterCh <- deviceSn
-				}
 			}
 
 			plantStatus := growatt.GrowattPlantStatusOnline
@@ -818,7 +855,7 @@ type GrowattInverterProduction struct {
 }
 
 func (g *GrowattCollector) CalculateInverterProductions(credential *model.GrowattCredential, inverterSNs []string) (map[string]GrowattInverterProduction, error) {
-	client := growatt.NewGrowattClient(credential.Username, credential.Token)
+	client := growatt.NewGrowattClient(credential.Username, credential.Token, g.clientOptions...)
 	g.logger.Info().Msg("GrowattCollector::CalculateInverterProductions() - getting realtime device batches data")
 	resp, err := client.GetRealtimeDeviceBatchesData(inverterSNs)
 	if err != nil {
@@ -0,0 +1,114 @@
+package collector_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/api/fake"
+	"github.com/HavvokLab/true-solar/api/growatt"
+	"github.com/HavvokLab/true-solar/collector"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"go.openly.dev/pointy"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fixtureNow is the collection time the fake vendor fixtures are recorded at.
+var fixtureNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// newSiteRegionRepo returns a migrated SQLite store mapping the BKK city of
+// the fixture plants to the BMA area.
+func newSiteRegionRepo(t *testing.T) repo.SiteRegionMappingRepo {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "database.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	siteRegionRepo := repo.NewSiteRegionMappingRepo(db)
+	if err := siteRegionRepo.CreateCity(&model.SiteRegionMapping{Code: "BKK", Name: "Bangkok", Area: pointy.String("BMA")}); err != nil {
+		t.Fatalf("create city: %v", err)
+	}
+
+	return siteRegionRepo
+}
+
+func TestGrowattCollectorExecute(t *testing.T) {
+	server := fake.NewGrowattServer()
+	defer server.Close()
+
+	solarRepo := repo.NewSolarMemoryRepo()
+	c := collector.NewGrowattCollector(solarRepo, newSiteRegionRepo(t), growatt.WithBaseURL(server.URL), growatt.WithRetryCount(0))
+	credential := &model.GrowattCredential{Username: "fake", Token: "fake-token", Owner: "owner"}
+	if err := c.Execute(fixtureNow, credential); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	indices := solarRepo.Indices()
+	if len(indices) != 1 {
+		t.Fatalf("indices = %v, want one solarcell index", indices)
+	}
+
+	plants := make(map[string]model.PlantItem)
+	devices := make(map[string]model.DeviceItem)
+	for _, doc := range solarRepo.Documents(indices[0]) {
+		switch item := doc.(type) {
+		case model.PlantItem:
+			plants[pointy.StringValue(item.ID, "")] = item
+		case model.DeviceItem:
+			devices[pointy.StringValue(item.SN, "")] = item
+		}
+	}
+
+	plant, ok := plants["1001"]
+	if !ok || len(plants) != 1 {
+		t.Fatalf("plants = %v, want plant 1001 only", plants)
+	}
+
+	if got := pointy.StringValue(plant.PlantStatus, ""); got != growatt.GrowattPlantStatusAlarm {
+		t.Errorf("plant status = %q, want %q", got, growatt.GrowattPlantStatusAlarm)
+	}
+
+	if plant.SiteID != "BKK01" || plant.Area != "BMA" || plant.Owner != "owner" {
+		t.Errorf("plant site = %q, area = %q, owner = %q, want BKK01, BMA, owner", plant.SiteID, plant.Area, plant.Owner)
+	}
+
+	wantProductions := map[string][2]float64{
+		"INV0001": {9120.3, 17.4},
+		"INV0002": {6110.2, 11.2},
+	}
+	if len(devices) != len(wantProductions) {
+		t.Fatalf("devices = %v, want %d inverters", devices, len(wantProductions))
+	}
+
+	for sn, want := range wantProductions {
+		device, ok := devices[sn]
+		if !ok {
+			t.Errorf("device %s not indexed", sn)
+			continue
+		}
+
+		total := pointy.Float64Value(device.TotalPowerGeneration, -1)
+		daily := pointy.Float64Value(device.DailyPowerGeneration, -1)
+		if total != want[0] || daily != want[1] {
+			t.Errorf("device %s production = %v/%v, want %v/%v", sn, total, daily, want[0], want[1])
+		}
+	}
+
+	if got := pointy.StringValue(devices["INV0002"].Status, ""); got != growatt.GrowattDeviceStatusFailure {
+		t.Errorf("device INV0002 status = %q, want %q", got, growatt.GrowattDeviceStatusFailure)
+	}
+
+	sites := solarRepo.SiteStations()
+	if len(sites) != 1 || pointy.StringValue(sites[0].PlantStatus, "") != growatt.GrowattPlantStatusAlarm {
+		t.Errorf("site stations = %+v, want BKK01 with status %s", sites, growatt.GrowattPlantStatusAlarm)
+	}
+}
@@ -21,11 +21,13 @@ type HuaweiCollector struct {
 	siteRegionRepo repo.SiteRegionMappingRepo
 	siteRegions    []model.SiteRegionMapping
 	logger         zerolog.Logger
+	clientOptions  []huawei.Option
 }
 
 func NewHuaweiCollector(
 	solarRepo repo.SolarRepo,
 	siteRegionRepo repo.SiteRegionMappingRepo,
+	clientOptions ...huawei.Option,
 ) *HuaweiCollector {
 	return &HuaweiCollector{
 		vendorType:     strings.ToUpper(model.VendorTypeHuawei),
@@ -33,18 +35,25 @@ func NewHuaweiCollector(
 		siteRegionRepo: siteRegionRepo,
 		siteRegions:    make([]model.SiteRegionMapping, 0),
 		logger:         zerolog.New(logger.NewWriter("huawei_collector.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:  clientOptions,
 	}
 }
 
-func (h *HuaweiCollector) Execute(credential *model.HuaweiCredential) {
+func (h *HuaweiCollector) Execute(now time.Time, cred model.Credential) (err error) {
+	credential, ok := cred.(*model.HuaweiCredential)
+	if !ok {
+		return invalidCredentialError("HuaweiCollector", cred)
+	}
+
 	siteRegions, err := h.siteRegionRepo.GetSiteRegionMappings()
 	if err != nil {
 		h.logger.Error().Err(err).Msg("huaweiCollector::Execute() - failed to get site region mappings")
-		return
+		return err
 	}
 	h.siteRegions = siteRegions
-	now := time.Now().UTC()
-	documents := make([]any, 0)
+	now = now.UTC()
+	index := fmt.Sprintf("%v-%v", model.SolarIndex, time.Now().Format("2006.01.02"))
+	processor := repo.NewBulkProcessor(h.solarRepo, index)
 	siteDocuments := make([]model.SiteItem, 0)
 	docCh := make(chan any)
 	errCh := make(chan error)
@@ -53,6 +62,7 @@ func (h *HuaweiCollector) Execute(credential *model.HuaweiCredential) {
 	defer func() {
 		if r := recover(); r != nil {
 			h.logger.Error().Any("recover", r).Msg("huaweiCollector::Execute() - panic")
+			err = fmt.Errorf("huaweiCollector::Execute() - panic: %v", r)
 		}
 	}()
 
@@ -64,11 +74,16 @@ COLLECT:
 		case <-doneCh:
 			h.logger.Info().Msg("huaweiCollector::Execute() - done")
 			break COLLECT
-		case err := <-errCh:
-			h.logger.Error().Err(err).Msg("huaweiCollector::Execute() - failed")
+		case collectErr := <-errCh:
+			h.logger.Error().Err(collectErr).Msg("huaweiCollector::Execute() - failed")
+			err = collectErr
 			break COLLECT
 		case doc := <-docCh:
-			documents = append(documents, doc)
+			if addErr := processor.Add(doc); addErr != nil {
+				h.logger.Error().Err(addErr).Msg("huaweiCollector::Execute() - failed to add document")
+				err = addErr
+			}
+
 			if plantItemDoc, ok := doc.(model.PlantItem); ok {
 				siteItemDoc := model.SiteItem{
 					Timestamp:   plantItemDoc.Timestamp,
@@ -86,28 +101,30 @@ COLLECT:
 		}
 	}
 
-	index := fmt.Sprintf("%v-%v", model.SolarIndex, time.Now().Format("2006.01.02"))
-	if err := h.solarRepo.BulkIndex(index, documents); err != nil {
-		h.logger.Error().Err(err).Msg("huaweiCollector::Execute() - failed to bulk index documents")
+	if result, bulkErr := processor.Close(); bulkErr != nil {
+		h.logger.Error().Err(bulkErr).Msg("huaweiCollector::Execute() - failed to bulk index documents")
+		err = bulkErr
 	} else {
-		h.logger.Info().Int("count", len(documents)).Msg("huaweiCollector::Execute() - bulk index documents success")
+		h.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("huaweiCollector::Execute() - bulk index documents success")
 	}
 
-	if err := h.solarRepo.UpsertSiteStation(siteDocuments); err != nil {
-		h.logger.Error().Err(err).Msg("huaweiCollector::Execute() - failed to upsert site station")
+	if result, upsertErr := h.solarRepo.UpsertSiteStation(siteDocuments); upsertErr != nil {
+		h.logger.Error().Err(upsertErr).Msg("huaweiCollector::Execute() - failed to upsert site station")
+		err = upsertErr
 	} else {
-		h.logger.Info().Int("count", len(siteDocuments)).Msg("huaweiCollector::Execute() - upsert site station success")
+		h.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("huaweiCollector::Execute() - upsert site station success")
 	}
 
 	close(doneCh)
 	close(errCh)
 	close(docCh)
+	return err
 }
 
 func (h *HuaweiCollector) Collect(credential *model.HuaweiCredential, now time.Time, docCh chan any, errCh chan error, doneCh chan bool) {
 	beginTime := time.Date(now.Year(), now.Month(), now.Day(), 6, 0, 0, 0, time.UTC).UnixMilli()
 	collectTime := now.UnixMilli()
-	client, err := huawei.NewHuaweiClient(credential.Username, credential.Password)
+	client, err := huawei.NewHuaweiClient(credential.Username, credential.Password, h.clientOptions...)
 	if err != nil {
 		h.logger.Error().
 			Err(err).
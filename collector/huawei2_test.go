@@ -0,0 +1,98 @@
+package collector_test
+
+import (
+	"testing"
+
+	"github.com/HavvokLab/true-solar/api/fake"
+	"github.com/HavvokLab/true-solar/api/huawei2"
+	"github.com/HavvokLab/true-solar/collector"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+	"go.openly.dev/pointy"
+)
+
+func TestHuawei2CollectorExecute(t *testing.T) {
+	server := fake.NewHuaweiServer()
+	defer server.Close()
+
+	solarRepo := repo.NewSolarMemoryRepo()
+	c := collector.NewHuawei2Collector(solarRepo, newSiteRegionRepo(t), huawei2.WithBaseURL(server.URL), huawei2.WithRetryCount(0))
+	credential := &model.HuaweiCredential{Username: "fake", Password: "fake", Owner: "owner"}
+	if err := c.Execute(fixtureNow, credential); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	indices := solarRepo.Indices()
+	if len(indices) != 1 {
+		t.Fatalf("indices = %v, want one solarcell index", indices)
+	}
+
+	plants := make(map[string]model.PlantItem)
+	devices := make(map[string]model.DeviceItem)
+	alarms := make([]model.AlarmItem, 0)
+	for _, doc := range solarRepo.Documents(indices[0]) {
+		switch item := doc.(type) {
+		case model.PlantItem:
+			plants[pointy.StringValue(item.ID, "")] = item
+		case model.DeviceItem:
+			devices[pointy.StringValue(item.SN, "")] = item
+		case model.AlarmItem:
+			alarms = append(alarms, item)
+		}
+	}
+
+	plant, ok := plants["NE=1001"]
+	if !ok || len(plants) != 1 {
+		t.Fatalf("plants = %v, want plant NE=1001 only", plants)
+	}
+
+	if got := pointy.StringValue(plant.PlantStatus, ""); got != huawei2.HuaweiStatusAlarm {
+		t.Errorf("plant status = %q, want %q", got, huawei2.HuaweiStatusAlarm)
+	}
+
+	if plant.VendorType != "HUAWEI" || pointy.StringValue(plant.Name, "") != "BKK02-AN-1P-5.00" || plant.Owner != "owner" {
+		t.Errorf("plant vendor = %q, name = %q, owner = %q, want HUAWEI, BKK02-AN-1P-5.00, owner", plant.VendorType, pointy.StringValue(plant.Name, ""), plant.Owner)
+	}
+
+	gotProduction := [4]float64{
+		pointy.Float64Value(plant.CurrentPower, -1),
+		pointy.Float64Value(plant.DailyProduction, -1),
+		pointy.Float64Value(plant.MonthlyProduction, -1),
+		pointy.Float64Value(plant.TotalProduction, -1),
+	}
+	if want := [4]float64{2.1, 14.8, 322.1, 9162.3}; gotProduction != want {
+		t.Errorf("plant current power, daily, monthly and total production = %v, want %v", gotProduction, want)
+	}
+
+	wantDevices := map[string]struct {
+		status string
+		total  float64
+		daily  float64
+	}{
+		"HWINV0001": {huawei2.HuaweiStatusAlarm, 6120.4, 9.6},
+		"HWINV0002": {huawei2.HuaweiStatusOffline, 3041.9, 5.2},
+	}
+	for sn, want := range wantDevices {
+		device, ok := devices[sn]
+		if !ok {
+			t.Errorf("device %s not indexed", sn)
+			continue
+		}
+
+		status := pointy.StringValue(device.Status, "")
+		total := pointy.Float64Value(device.TotalPowerGeneration, -1)
+		daily := pointy.Float64Value(device.DailyPowerGeneration, -1)
+		if status != want.status || total != want.total || daily != want.daily {
+			t.Errorf("device %s status and production = %s %v/%v, want %s %v/%v", sn, status, total, daily, want.status, want.total, want.daily)
+		}
+	}
+
+	if len(alarms) != 1 || pointy.StringValue(alarms[0].DeviceSN, "") != "HWINV0001" || pointy.StringValue(alarms[0].Message, "") != "Grid Loss" {
+		t.Errorf("alarms = %+v, want Grid Loss of HWINV0001", alarms)
+	}
+
+	sites := solarRepo.SiteStations()
+	if len(sites) != 1 || pointy.StringValue(sites[0].PlantStatus, "") != huawei2.HuaweiStatusAlarm {
+		t.Errorf("site stations = %+v, want NE=1001 with status %s", sites, huawei2.HuaweiStatusAlarm)
+	}
+}
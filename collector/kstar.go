@@ -20,11 +20,13 @@ type KstarCollector struct {
 	siteRegionRepo repo.SiteRegionMappingRepo
 	siteRegions    []model.SiteRegionMapping
 	logger         zerolog.Logger
+	clientOptions  []kstar.Option
 }
 
 func NewKstarCollector(
 	solarRepo repo.SolarRepo,
 	siteRegionRepo repo.SiteRegionMappingRepo,
+	clientOptions ...kstar.Option,
 ) *KstarCollector {
 	return &KstarCollector{
 		vendorType:     strings.ToUpper(model.VendorTypeKstar),
@@ -32,18 +34,25 @@ func NewKstarCollector(
 		siteRegionRepo: siteRegionRepo,
 		siteRegions:    make([]model.SiteRegionMapping, 0),
 		logger:         zerolog.New(logger.NewWriter("kstar_collector.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:  clientOptions,
 	}
 }
 
-func (k *KstarCollector) Execute(credential *model.KstarCredential) {
+func (k *KstarCollector) Execute(now time.Time, cred model.Credential) (err error) {
+	credential, ok := cred.(*model.KstarCredential)
+	if !ok {
+		return invalidCredentialError("KstarCollector", cred)
+	}
+
 	siteRegions, err := k.siteRegionRepo.GetSiteRegionMappings()
 	if err != nil {
 		k.logger.Error().Err(err).Msg("KstarCollector::Execute() - failed to get site region mappings")
-		return
+		return err
 	}
 	k.siteRegions = siteRegions
-	now := time.Now().UTC()
-	documents := make([]any, 0)
+	now = now.UTC()
+	index := fmt.Sprintf("%v-%v", model.SolarIndex, time.Now().Format("2006.01.02"))
+	processor := repo.NewBulkProcessor(k.solarRepo, index)
 	siteDocuments := make([]model.SiteItem, 0)
 	docCh := make(chan any)
 	errCh := make(chan error)
@@ -52,6 +61,7 @@ func (k *KstarCollector) Execute(credential *model.KstarCredential) {
 	defer func() {
 		if r := recover(); r != nil {
 			k.logger.Error().Any("recover", r).Msg("KstarCollector::Execute() - panic")
+			err = fmt.Errorf("KstarCollector::Execute() - panic: %v", r)
 		}
 	}()
 
@@ -63,11 +73,16 @@ COLLECT:
 		case <-doneCh:
 			k.logger.Info().Msg("KstarCollector::Execute() - done")
 			break COLLECT
-		case err := <-errCh:
-			k.logger.Error().Err(err).Msg("KstarCollector::Execute() - failed")
+		case collectErr := <-errCh:
+			k.logger.Error().Err(collectErr).Msg("KstarCollector::Execute() - failed")
+			err = collectErr
 			break COLLECT
 		case doc := <-docCh:
-			documents = append(documents, doc)
+			if addErr := processor.Add(doc); addErr != nil {
+				k.logger.Error().Err(addErr).Msg("KstarCollector::Execute() - failed to add document")
+				err = addErr
+			}
+
 			if plantItemDoc, ok := doc.(model.PlantItem); ok {
 				siteItemDoc := model.SiteItem{
 					Timestamp:   plantItemDoc.Timestamp,
@@ -85,23 +100,24 @@ COLLECT:
 		}
 	}
 
-	index := fmt.Sprintf("%v-%v", model.SolarIndex, time.Now().Format("2006.01.02"))
-	if err := k.solarRepo.BulkIndex(index, documents); err != nil {
-		k.logger.Error().Err(err).Msg("KstarCollector::Execute() - failed to bulk index documents")
+	if result, bulkErr := processor.Close(); bulkErr != nil {
+		k.logger.Error().Err(bulkErr).Msg("KstarCollector::Execute() - failed to bulk index documents")
+		err = bulkErr
 	} else {
-		k.logger.Info().Int("count", len(documents)).Msg("KstarCollector::Execute() - bulk index documents success")
+		k.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("KstarCollector::Execute() - bulk index documents success")
 	}
 
-	if err := k.solarRepo.UpsertSiteStation(siteDocuments); err != nil {
-		k.logger.Error().Err(err).Msg("KstarCollector::Execute() - failed to upsert site station")
+	if result, upsertErr := k.solarRepo.UpsertSiteStation(siteDocuments); upsertErr != nil {
+		k.logger.Error().Err(upsertErr).Msg("KstarCollector::Execute() - failed to upsert site station")
+		err = upsertErr
 	} else {
-		k.logger.Info().Int("count", len(siteDocuments)).Msg("KstarCollector::Execute() - upsert site station success")
+		k.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("KstarCollector::Execute() - upsert site station success")
 	}
 
 	close(doneCh)
 	close(errCh)
 	close(docCh)
-
+	return err
 }
 
 func (k *KstarCollector) Collect(
@@ -111,7 +127,7 @@ func (k *KstarCollector) Collect(
 	errCh chan error,
 	doneCh chan bool,
 ) {
-	client := kstar.NewKstarClient(credential.Username, credential.Password)
+	client := kstar.NewKstarClient(credential.Username, credential.Password, k.clientOptions...)
 
 	mapPlantIdToDeviceList := make(map[string][]kstar.DeviceItem)
 	devices, err := client.GetDeviceList()
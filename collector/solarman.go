@@ -22,11 +22,13 @@ type SolarmanCollector struct {
 	siteRegionRepo repo.SiteRegionMappingRepo
 	siteRegions    []model.SiteRegionMapping
 	logger         zerolog.Logger
+	clientOptions  []solarman.Option
 }
 
 func NewSolarmanCollector(
 	solarRepo repo.SolarRepo,
 	siteRegionRepo repo.SiteRegionMappingRepo,
+	clientOptions ...solarman.Option,
 ) *SolarmanCollector {
 	return &SolarmanCollector{
 		vendorType:     strings.ToUpper(model.VendorTypeInvt),
@@ -34,19 +36,25 @@ func NewSolarmanCollector(
 		siteRegionRepo: siteRegionRepo,
 		siteRegions:    make([]model.SiteRegionMapping, 0),
 		logger:         zerolog.New(logger.NewWriter("solarman_collector.log")).With().Timestamp().Caller().Logger(),
+		clientOptions:  clientOptions,
 	}
 }
 
-func (c *SolarmanCollector) Execute(now time.Time, credential *model.SolarmanCredential) {
+func (c *SolarmanCollector) Execute(now time.Time, cred model.Credential) (err error) {
+	credential, ok := cred.(*model.SolarmanCredential)
+	if !ok {
+		return invalidCredentialError("SolarmanCollector", cred)
+	}
 
 	siteRegions, err := c.siteRegionRepo.GetSiteRegionMappings()
 	if err != nil {
 		c.logger.Error().Err(err).Msg("SolarmanCollector::Execute() - failed to get site region mappings")
-		return
+		return err
 	}
 	c.siteRegions = siteRegions
 	now = now.UTC()
-	documents := make([]any, 0)
+	index := fmt.Sprintf("%v-%v", model.SolarIndex, time.Now().Format("2006.01.02"))
+	processor := repo.NewBulkProcessor(c.solarRepo, index)
 	siteDocuments := make([]model.SiteItem, 0)
 	docCh := make(chan any)
 	errCh := make(chan error)
@@ -55,6 +63,7 @@ func (c *SolarmanCollector) Execute(now time.Time, credential *model.SolarmanCre
 	defer func() {
 		if r := recover(); r != nil {
 			c.logger.Error().Any("recover", r).Msg("SolarmanCollector::Execute() - panic")
+			err = fmt.Errorf("SolarmanCollector::Execute() - panic: %v", r)
 		}
 	}()
 
@@ -66,11 +75,16 @@ COLLECT:
 		case <-doneCh:
 			c.logger.Info().Msg("SolarmanCollector::Execute() - done")
 			break COLLECT
-		case err := <-errCh:
-			c.logger.Error().Err(err).Msg("SolarmanCollector::Execute() - failed")
+		case collectErr := <-errCh:
+			c.logger.Error().Err(collectErr).Msg("SolarmanCollector::Execute() - failed")
+			err = collectErr
 			break COLLECT
 		case doc := <-docCh:
-			documents = append(documents, doc)
+			if addErr := processor.Add(doc); addErr != nil {
+				c.logger.Error().Err(addErr).Msg("SolarmanCollector::Execute() - failed to add document")
+				err = addErr
+			}
+
 			if plantItemDoc, ok := doc.(model.PlantItem); ok {
 				siteItemDoc := model.SiteItem{
 					Timestamp:   plantItemDoc.Timestamp,
@@ -88,22 +102,24 @@ COLLECT:
 		}
 	}
 
-	index := fmt.Sprintf("%v-%v", model.SolarIndex, time.Now().Format("2006.01.02"))
-	if err := c.solarRepo.BulkIndex(index, documents); err != nil {
-		c.logger.Error().Err(err).Msg("SolarmanCollector::Execute() - failed to bulk index documents")
+	if result, bulkErr := processor.Close(); bulkErr != nil {
+		c.logger.Error().Err(bulkErr).Msg("SolarmanCollector::Execute() - failed to bulk index documents")
+		err = bulkErr
 	} else {
-		c.logger.Info().Int("count", len(documents)).Msg("SolarmanCollector::Execute() - bulk index documents success")
+		c.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("SolarmanCollector::Execute() - bulk index documents success")
 	}
 
-	if err := c.solarRepo.UpsertSiteStation(siteDocuments); err != nil {
-		c.logger.Error().Err(err).Msg("SolarmanCollector::Execute() - failed to upsert site station")
+	if result, upsertErr := c.solarRepo.UpsertSiteStation(siteDocuments); upsertErr != nil {
+		c.logger.Error().Err(upsertErr).Msg("SolarmanCollector::Execute() - failed to upsert site station")
+		err = upsertErr
 	} else {
-		c.logger.Info().Int("count", len(siteDocuments)).Msg("SolarmanCollector::Execute() - upsert site station success")
+		c.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("SolarmanCollector::Execute() - upsert site station success")
 	}
 
 	close(doneCh)
 	close(errCh)
 	close(docCh)
+	return err
 }
 
 func (c *SolarmanCollector) Collect(
@@ -113,7 +129,7 @@ func (c *SolarmanCollector) Collect(
 	errCh chan error,
 	doneCh chan bool,
 ) {
-	client := solarman.NewSolarmanClient(credential.Username, credential.Password, credential.AppID, credential.AppSecret)
+	client := solarman.NewSolarmanClient(credential.Username, credential.Password, credential.AppID, credential.AppSecret, c.clientOptions...)
 	beginningOfDay := time.Date(now.Year(), now.Month(), now.Day(), 6, 0, 0, 0, time.Local)
 
 	tokenResp, err := client.GetBasicToken()
@@ -152,7 +168,7 @@ func (c *SolarmanCollector) Collect(
 		basicToken := pointy.StringValue(tokenResp.AccessToken, util.EmptyString)
 
 		producer := func() {
-			client := solarman.NewSolarmanClient(credential.Username, credential.Password, credential.AppID, credential.AppSecret)
+			client := solarman.NewSolarmanClient(credential.Username, credential.Password, credential.AppID, credential.AppSecret, c.clientOptions...)
 			client.SetAccessToken(basicToken)
 
 			businessTokenResp, err := client.GetBusinessToken(pointy.IntValue(company.CompanyID, 0))
@@ -1,37 +1,47 @@
 package config
 
 import (
+	"fmt"
 	"strings"
-	"sync"
 
 	"github.com/spf13/viper"
 )
 
-var once sync.Once
-var config *Config
+// DefaultPath is the config file Load reads when no path is given, relative
+// to the working directory.
+const DefaultPath = "config.yaml"
 
-func InitConfig() {
-	viper.AddConfigPath(".")
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
-	if err := viper.ReadInConfig(); err != nil {
-		panic(err)
+// Load reads the config file at path, DefaultPath when empty. Environment
+// variables override the file, e.g. ELASTICSEARCH_HOST for elasticsearch.host.
+func Load(path string) (Config, error) {
+	if path == "" {
+		path = DefaultPath
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
-		panic(err)
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetDefault("security.credential_key", "")
+	v.SetDefault("elasticsearch.api_key", "")
+	v.SetDefault("elasticsearch.bearer_token", "")
+	v.SetDefault("database.driver", "")
+	v.SetDefault("database.dsn", "")
+	v.SetDefault("database.auto_migrate", nil)
+	v.SetDefault("alarm.renotify_interval", "0s")
+	v.SetDefault("alarm.debounce", 1)
+	v.SetDefault("alarm.flap_transitions", 0)
+	v.SetDefault("alarm.flap_window", "0s")
+
+	var config Config
+	if err := v.ReadInConfig(); err != nil {
+		return config, fmt.Errorf("read config %s: %w", path, err)
 	}
-}
 
-func GetConfig() Config {
-	if config == nil {
-		once.Do(func() {
-			InitConfig()
-		})
+	if err := v.Unmarshal(&config); err != nil {
+		return config, fmt.Errorf("decode config %s: %w", path, err)
 	}
 
-	return *config
+	return config, nil
 }
@@ -1,6 +1,9 @@
 package config
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Performance alarm constants
 const (
@@ -19,7 +22,9 @@ const (
 	PerformanceAlarmTypeSumPerformanceLow
 )
 
-// Fallback config values for performance alarms (based on database defaults)
+// Performance alarm config values, seeded into tbl_performance_alarm_config
+// by the migrations and used for missing rows and NULL hit_day and duration
+// columns.
 const (
 	// LowPerformanceAlarm fallback values
 	LowPerformanceAlarmInterval   = 24
@@ -34,17 +39,183 @@ const (
 	SumPerformanceAlarmDuration   = 30
 )
 
+// Installed capacity values seeded into an empty tbl_installed_capacity.
+const (
+	InstalledCapacityEfficiencyFactor = 0.8
+	InstalledCapacityFocusHour        = 4
+)
+
+// DefaultConcurrency is the number of credentials of one vendor processed at
+// once when neither the vendor nor the default limit is configured.
+const DefaultConcurrency = 5
+
 type Config struct {
-	Elastic  ElasticsearchConfig `mapstructure:"elasticsearch"`
-	SnmpList []SnmpConfig        `mapstructure:"snmp_list"`
-	Redis    RedisConfig         `mapstructure:"redis"`
-	Crontab  CrontabConfig       `mapstructure:"crontab"`
+	Elastic     ElasticsearchConfig `mapstructure:"elasticsearch"`
+	Database    DatabaseConfig      `mapstructure:"database"`
+	SnmpList    []SnmpConfig        `mapstructure:"snmp_list"`
+	Redis       RedisConfig         `mapstructure:"redis"`
+	Crontab     CrontabConfig       `mapstructure:"crontab"`
+	Concurrency ConcurrencyConfig   `mapstructure:"concurrency"`
+	Admin       AdminConfig         `mapstructure:"admin"`
+	Security    SecurityConfig      `mapstructure:"security"`
+	Credentials CredentialsConfig   `mapstructure:"credentials"`
+	Metrics     MetricsConfig       `mapstructure:"metrics"`
+	Spool       SpoolConfig         `mapstructure:"spool"`
+	Alarm       AlarmConfig         `mapstructure:"alarm"`
 }
 
+// ElasticsearchConfig is the cluster connection. Hosts lists the nodes, Host
+// is kept for single node setups. Only one of Username/Password, APIKey and
+// BearerToken may be set.
 type ElasticsearchConfig struct {
-	Host     string `mapstructure:"host"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	Host        string                          `mapstructure:"host"`
+	Hosts       []string                        `mapstructure:"hosts"`
+	Username    string                          `mapstructure:"username"`
+	Password    string                          `mapstructure:"password"`
+	APIKey      string                          `mapstructure:"api_key"`
+	BearerToken string                          `mapstructure:"bearer_token"`
+	Sniff       bool                            `mapstructure:"sniff"`
+	TLS         ElasticsearchTLSConfig          `mapstructure:"tls"`
+	Lifecycle   map[string]IndexLifecycleConfig `mapstructure:"lifecycle"`
+	Bulk        BulkConfig                      `mapstructure:"bulk"`
+}
+
+// URLs returns Hosts, or Host when no hosts are listed.
+func (c ElasticsearchConfig) URLs() []string {
+	urls := make([]string, 0, len(c.Hosts)+1)
+	for _, host := range c.Hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			urls = append(urls, host)
+		}
+	}
+
+	if len(urls) == 0 && c.Host != "" {
+		urls = append(urls, c.Host)
+	}
+
+	return urls
+}
+
+// ElasticsearchTLSConfig verifies https nodes against CAFile in addition to
+// the system roots. CertFile and KeyFile are the client certificate for
+// clusters requiring one. ServerName overrides the name verified, e.g. when
+// nodes are reached by IP.
+type ElasticsearchTLSConfig struct {
+	CAFile     string `mapstructure:"ca_file"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+}
+
+// Bulk request fallbacks. Batches stay well below http.max_content_length,
+// 100mb by default, and finish within the request timeout.
+const (
+	DefaultBulkActions     = 1000
+	DefaultBulkBytes       = 5 << 20
+	DefaultBulkConcurrency = 2
+)
+
+// BulkConfig bounds the bulk requests sent to Elasticsearch. Documents are
+// split into batches of at most Actions documents and Bytes of payload, and
+// at most Concurrency batches are sent at once.
+type BulkConfig struct {
+	Actions     int `mapstructure:"actions"`
+	Bytes       int `mapstructure:"bytes"`
+	Concurrency int `mapstructure:"concurrency"`
+}
+
+func (c BulkConfig) WithDefaults() BulkConfig {
+	if c.Actions <= 0 {
+		c.Actions = DefaultBulkActions
+	}
+
+	if c.Bytes <= 0 {
+		c.Bytes = DefaultBulkBytes
+	}
+
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultBulkConcurrency
+	}
+
+	return c
+}
+
+// Index lifecycle fallbacks. Collected data is kept until a retention is
+// configured.
+const (
+	DefaultIndexWarmAfterDays   = 7
+	DefaultIndexDeleteAfterDays = 0
+)
+
+// IndexLifecycleConfig is the ILM policy of the daily indices of one index
+// family, e.g. solarcell or alarm. Zero DeleteAfterDays keeps indices forever.
+type IndexLifecycleConfig struct {
+	WarmAfterDays   int `mapstructure:"warm_after_days"`
+	DeleteAfterDays int `mapstructure:"delete_after_days"`
+}
+
+// IndexLifecycle returns the lifecycle of an index family, falling back to
+// DefaultIndexWarmAfterDays and DefaultIndexDeleteAfterDays.
+func (c ElasticsearchConfig) IndexLifecycle(family string) IndexLifecycleConfig {
+	lifecycle := c.Lifecycle[strings.ToLower(family)]
+	if lifecycle.WarmAfterDays <= 0 {
+		lifecycle.WarmAfterDays = DefaultIndexWarmAfterDays
+	}
+
+	if lifecycle.DeleteAfterDays < 0 {
+		lifecycle.DeleteAfterDays = DefaultIndexDeleteAfterDays
+	}
+
+	return lifecycle
+}
+
+// Database drivers of the relational store.
+const (
+	DatabaseDriverSQLite   = "sqlite"
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverMySQL    = "mysql"
+)
+
+// DefaultDatabaseDSN is the SQLite file used when no DSN is configured,
+// relative to the working directory.
+const DefaultDatabaseDSN = "database.db"
+
+// DatabaseConfig selects the relational store of credentials, mappings,
+// alarm configs and the job ledger. DSN is the SQLite file path, a PostgreSQL
+// URL or keyword string, or a MySQL DSN. Zero pool limits keep the defaults
+// of database/sql. AutoMigrate applies pending migrations when the store is
+// opened, otherwise opening an outdated store fails. Unset, only the commands
+// owning the schema migrate, see MigrateOnOpen.
+type DatabaseConfig struct {
+	Driver          string        `mapstructure:"driver"`
+	DSN             string        `mapstructure:"dsn"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	AutoMigrate     *bool         `mapstructure:"auto_migrate"`
+}
+
+// MigrateOnOpen reports whether pending migrations are applied when the
+// store is opened: AutoMigrate when set, fallback of the command otherwise.
+func (c DatabaseConfig) MigrateOnOpen(fallback bool) bool {
+	if c.AutoMigrate == nil {
+		return fallback
+	}
+
+	return *c.AutoMigrate
+}
+
+// WithDefaults falls back to the SQLite file DefaultDatabaseDSN.
+func (c DatabaseConfig) WithDefaults() DatabaseConfig {
+	if c.Driver == "" {
+		c.Driver = DatabaseDriverSQLite
+	}
+
+	if c.DSN == "" && c.Driver == DatabaseDriverSQLite {
+		c.DSN = DefaultDatabaseDSN
+	}
+
+	return c
 }
 
 type SnmpConfig struct {
@@ -61,9 +232,223 @@ type RedisConfig struct {
 	DB       int    `mapstructure:"db"`
 }
 
+// Vendor job kinds scheduled by the runner.
+const (
+	CrontabJobCollect = "collect"
+	CrontabJobAlarm   = "alarm"
+)
+
+// CrontabConfig holds the runner schedules. CollectTime, AlarmTime and Jitter
+// are the fallbacks for vendors that do not override them in Vendors.
 type CrontabConfig struct {
-	CollectTime             string `mapstructure:"collect_time"`
-	AlarmTime               string `mapstructure:"alarm_time"`
-	LowPerformanceAlarmTime string `mapstructure:"low_performance_alarm_time"`
-	SumPerformanceAlarmTime string `mapstructure:"sum_performance_alarm_time"`
+	CollectTime             string                         `mapstructure:"collect_time"`
+	AlarmTime               string                         `mapstructure:"alarm_time"`
+	LowPerformanceAlarmTime string                         `mapstructure:"low_performance_alarm_time"`
+	SumPerformanceAlarmTime string                         `mapstructure:"sum_performance_alarm_time"`
+	Jitter                  time.Duration                  `mapstructure:"jitter"`
+	Vendors                 map[string]VendorCrontabConfig `mapstructure:"vendors"`
+}
+
+// VendorCrontabConfig overrides the schedule of a single vendor. Disabled
+// turns off every job of the vendor regardless of the fallbacks,
+// CollectDisabled and AlarmDisabled turn off one job and keep the other.
+type VendorCrontabConfig struct {
+	CollectTime     string        `mapstructure:"collect_time"`
+	AlarmTime       string        `mapstructure:"alarm_time"`
+	Jitter          time.Duration `mapstructure:"jitter"`
+	Disabled        bool          `mapstructure:"disabled"`
+	CollectDisabled bool          `mapstructure:"collect_disabled"`
+	AlarmDisabled   bool          `mapstructure:"alarm_disabled"`
+}
+
+// JobDisabled reports whether job is turned off, by Disabled or by the
+// switch of the job.
+func (c VendorCrontabConfig) JobDisabled(job string) bool {
+	switch job {
+	case CrontabJobCollect:
+		return c.Disabled || c.CollectDisabled
+	case CrontabJobAlarm:
+		return c.Disabled || c.AlarmDisabled
+	default:
+		return c.Disabled
+	}
+}
+
+// Schedule returns the cron expression and jitter of a vendor job. An empty
+// expression means the job is disabled.
+func (c CrontabConfig) Schedule(vendor, job string) (string, time.Duration) {
+	var fallback string
+	switch job {
+	case CrontabJobCollect:
+		fallback = c.CollectTime
+	case CrontabJobAlarm:
+		fallback = c.AlarmTime
+	}
+
+	vendorConfig, ok := c.Vendors[strings.ToLower(vendor)]
+	if !ok {
+		return fallback, c.Jitter
+	}
+
+	if vendorConfig.JobDisabled(job) {
+		return "", 0
+	}
+
+	expr := fallback
+	switch job {
+	case CrontabJobCollect:
+		if vendorConfig.CollectTime != "" {
+			expr = vendorConfig.CollectTime
+		}
+	case CrontabJobAlarm:
+		if vendorConfig.AlarmTime != "" {
+			expr = vendorConfig.AlarmTime
+		}
+	}
+
+	jitter := c.Jitter
+	if vendorConfig.Jitter > 0 {
+		jitter = vendorConfig.Jitter
+	}
+
+	return expr, jitter
+}
+
+// ConcurrencyConfig limits how many credentials of a vendor are processed at
+// the same time, across collect, alarm and troubleshoot jobs.
+type ConcurrencyConfig struct {
+	Default int            `mapstructure:"default"`
+	Vendors map[string]int `mapstructure:"vendors"`
+}
+
+// Limit returns the concurrency limit of a vendor, falling back to Default
+// and then to DefaultConcurrency.
+func (c ConcurrencyConfig) Limit(vendor string) int {
+	if limit, ok := c.Vendors[strings.ToLower(vendor)]; ok && limit > 0 {
+		return limit
+	}
+
+	if c.Default > 0 {
+		return c.Default
+	}
+
+	return DefaultConcurrency
+}
+
+// AdminConfig configures the admin HTTP API. Every request must carry
+// "Authorization: Bearer <Token>"; the server refuses to start without a token.
+type AdminConfig struct {
+	Address string `mapstructure:"address"`
+	Token   string `mapstructure:"token"`
+}
+
+// SecurityConfig holds the master key of the credential store, a 32-byte key
+// encoded as base64 or hex. It can also be set with SECURITY_CREDENTIAL_KEY.
+type SecurityConfig struct {
+	CredentialKey string `mapstructure:"credential_key"`
+}
+
+// Credential providers of named credentials.
+const (
+	CredentialProviderEnv   = "env"
+	CredentialProviderFile  = "file"
+	CredentialProviderVault = "vault"
+)
+
+// CredentialsConfig lists credentials resolved at run time, in addition to
+// the rows of the credential tables.
+type CredentialsConfig struct {
+	Sources []CredentialSourceConfig `mapstructure:"sources"`
+	FileDir string                   `mapstructure:"file_dir"`
+	Vault   VaultConfig              `mapstructure:"vault"`
+}
+
+// CredentialSourceConfig references one named credential. Key is the
+// environment variable, the file path (relative to FileDir) or the KV path
+// holding a JSON object with the credential fields.
+type CredentialSourceConfig struct {
+	Name     string `mapstructure:"name"`
+	Vendor   string `mapstructure:"vendor"`
+	Provider string `mapstructure:"provider"`
+	Key      string `mapstructure:"key"`
+}
+
+// VaultConfig points at a Vault-compatible KV HTTP API. Address and Token
+// fall back to VAULT_ADDR and VAULT_TOKEN.
+type VaultConfig struct {
+	Address string        `mapstructure:"address"`
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// MetricsConfig enables the Prometheus /metrics listener of the runner when
+// Address is set.
+type MetricsConfig struct {
+	Address string `mapstructure:"address"`
+}
+
+// Spool fallbacks.
+const (
+	DefaultSpoolDir       = "spool"
+	DefaultSpoolMaxBytes  = 1 << 30
+	DefaultSpoolWarnBytes = 256 << 20
+)
+
+// SpoolConfig configures the on-disk spool of documents that could not be
+// indexed. Batches that would grow the spool beyond MaxBytes are dropped,
+// above WarnBytes every write logs a warning.
+type SpoolConfig struct {
+	Disabled  bool   `mapstructure:"disabled"`
+	Dir       string `mapstructure:"dir"`
+	MaxBytes  int64  `mapstructure:"max_bytes"`
+	WarnBytes int64  `mapstructure:"warn_bytes"`
+}
+
+// WithDefaults fills the unset fields with the spool fallbacks.
+func (c SpoolConfig) WithDefaults() SpoolConfig {
+	if c.Dir == "" {
+		c.Dir = DefaultSpoolDir
+	}
+
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = DefaultSpoolMaxBytes
+	}
+
+	if c.WarnBytes <= 0 {
+		c.WarnBytes = DefaultSpoolWarnBytes
+	}
+
+	return c
+}
+
+// DefaultAlarmFlapWindow is the flap window when flap detection is enabled
+// without one.
+const DefaultAlarmFlapWindow = time.Hour
+
+// AlarmConfig controls the traps of the vendor alarm handlers. An alarm still
+// active after RenotifyInterval since its last raise trap gets another one,
+// zero sends raise traps only for new and changed alarms.
+//
+// An alarm is raised once observed in Debounce consecutive runs and cleared
+// once missed in as many. An alarm raised or cleared FlapTransitions times
+// within FlapWindow is flapping, zero FlapTransitions disables the detection.
+type AlarmConfig struct {
+	RenotifyInterval time.Duration `mapstructure:"renotify_interval"`
+	Debounce         int           `mapstructure:"debounce"`
+	FlapTransitions  int           `mapstructure:"flap_transitions"`
+	FlapWindow       time.Duration `mapstructure:"flap_window"`
+}
+
+// WithDefaults raises and clears alarms on their first run and falls back to
+// DefaultAlarmFlapWindow.
+func (c AlarmConfig) WithDefaults() AlarmConfig {
+	if c.Debounce < 1 {
+		c.Debounce = 1
+	}
+
+	if c.FlapWindow <= 0 {
+		c.FlapWindow = DefaultAlarmFlapWindow
+	}
+
+	return c
 }
@@ -0,0 +1,137 @@
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/imroc/req/v3"
+)
+
+const defaultVaultTimeout = 10 * time.Second
+
+// Provider fetches the fields of a named secret as a JSON object.
+type Provider interface {
+	Fetch(ctx context.Context, key string) (map[string]interface{}, error)
+}
+
+// EnvProvider reads a JSON object from the environment variable named by key.
+type EnvProvider struct{}
+
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+func (p *EnvProvider) Fetch(_ context.Context, key string) (map[string]interface{}, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", key)
+	}
+
+	return decodeFields([]byte(value))
+}
+
+// FileProvider reads a JSON object from a file, typically a mounted secret.
+// Relative keys are resolved against dir.
+type FileProvider struct {
+	dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+func (p *FileProvider) Fetch(_ context.Context, key string) (map[string]interface{}, error) {
+	path := key
+	if !filepath.IsAbs(path) && p.dir != "" {
+		path = filepath.Join(p.dir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeFields(data)
+}
+
+// VaultProvider reads a secret from a Vault-compatible KV HTTP API. Both KV
+// v2 ({"data": {"data": {...}}}) and v1 ({"data": {...}}) responses are read.
+type VaultProvider struct {
+	reqClient *req.Client
+	address   string
+	token     string
+}
+
+func NewVaultProvider(cfg config.VaultConfig) (*VaultProvider, error) {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	if address == "" {
+		return nil, errors.New("vault address is required")
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultVaultTimeout
+	}
+
+	return &VaultProvider{
+		reqClient: req.C().SetTimeout(timeout),
+		address:   strings.TrimRight(address, "/"),
+		token:     token,
+	}, nil
+}
+
+type vaultResponse struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+func (p *VaultProvider) Fetch(ctx context.Context, key string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/v1/%s", p.address, strings.TrimLeft(key, "/"))
+	result := vaultResponse{}
+	res, err := p.reqClient.R().
+		SetContext(ctx).
+		SetHeader("X-Vault-Token", p.token).
+		SetSuccessResult(&result).
+		Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.IsSuccessState() {
+		return nil, fmt.Errorf("vault returned status %d for %s", res.StatusCode, key)
+	}
+
+	if nested, ok := result.Data["data"].(map[string]interface{}); ok {
+		return nested, nil
+	}
+
+	if result.Data == nil {
+		return nil, fmt.Errorf("vault secret %s has no data", key)
+	}
+
+	return result.Data, nil
+}
+
+func decodeFields(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("credential must be a JSON object: %w", err)
+	}
+
+	return fields, nil
+}
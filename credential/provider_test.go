@@ -0,0 +1,147 @@
+package credential
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/HavvokLab/true-solar/config"
+)
+
+const testVaultToken = "s.test-token"
+
+// newVaultStub serves the given bodies by path and answers 404 otherwise. It
+// rejects requests without the test token like Vault does.
+func newVaultStub(t *testing.T, bodies map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("method = %s, want GET", r.Method)
+		}
+
+		if r.Header.Get("X-Vault-Token") != testVaultToken {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestVaultProviderFetch(t *testing.T) {
+	server := newVaultStub(t, map[string]string{
+		"/v1/secret/data/growatt": `{"data":{"data":{"username":"grw","token":"t0k"},"metadata":{"version":3}}}`,
+		"/v1/kv/kstar":            `{"lease_duration":2764800,"data":{"username":"kst","password":"p4ss"}}`,
+		"/v1/kv/empty":            `{"lease_duration":0}`,
+	})
+
+	tests := []struct {
+		name    string
+		key     string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "kv v2",
+			key:  "secret/data/growatt",
+			want: map[string]interface{}{"username": "grw", "token": "t0k"},
+		},
+		{
+			name: "kv v1",
+			key:  "kv/kstar",
+			want: map[string]interface{}{"username": "kst", "password": "p4ss"},
+		},
+		{
+			name: "leading slash",
+			key:  "/kv/kstar",
+			want: map[string]interface{}{"username": "kst", "password": "p4ss"},
+		},
+		{
+			name:    "missing secret",
+			key:     "kv/solarman",
+			wantErr: true,
+		},
+		{
+			name:    "no data",
+			key:     "kv/empty",
+			wantErr: true,
+		},
+	}
+
+	provider, err := NewVaultProvider(config.VaultConfig{Address: server.URL + "/", Token: testVaultToken})
+	if err != nil {
+		t.Fatalf("NewVaultProvider() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.Fetch(context.Background(), tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fetch(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultProviderFetchForbidden(t *testing.T) {
+	server := newVaultStub(t, map[string]string{
+		"/v1/kv/kstar": `{"data":{"username":"kst"}}`,
+	})
+
+	provider, err := NewVaultProvider(config.VaultConfig{Address: server.URL, Token: "s.wrong"})
+	if err != nil {
+		t.Fatalf("NewVaultProvider() error = %v", err)
+	}
+
+	if _, err := provider.Fetch(context.Background(), "kv/kstar"); err == nil {
+		t.Fatal("Fetch() with a wrong token succeeded")
+	}
+}
+
+func TestVaultProviderEnvFallback(t *testing.T) {
+	server := newVaultStub(t, map[string]string{
+		"/v1/kv/kstar": `{"data":{"username":"kst"}}`,
+	})
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", testVaultToken)
+
+	provider, err := NewVaultProvider(config.VaultConfig{})
+	if err != nil {
+		t.Fatalf("NewVaultProvider() error = %v", err)
+	}
+
+	got, err := provider.Fetch(context.Background(), "kv/kstar")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if got["username"] != "kst" {
+		t.Errorf("Fetch() = %v, want username kst", got)
+	}
+}
+
+func TestNewVaultProviderRequiresAddress(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+
+	if _, err := NewVaultProvider(config.VaultConfig{Token: testVaultToken}); err == nil {
+		t.Fatal("NewVaultProvider() without an address succeeded")
+	}
+}
@@ -0,0 +1,186 @@
+package credential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/pkg/util"
+	"github.com/HavvokLab/true-solar/registry"
+	"gorm.io/gorm"
+)
+
+// CredentialSource resolves the credentials of a vendor module at run time.
+// On partial failure it returns the credentials it could resolve together
+// with the error.
+type CredentialSource interface {
+	FindAll(ctx context.Context, vendor string) ([]model.Credential, error)
+}
+
+// NewSource returns the credential tables, decrypted with cipher, combined
+// with the named credentials configured in cfg.
+func NewSource(db *gorm.DB, cipher *secret.Cipher, cfg config.CredentialsConfig) (CredentialSource, error) {
+	sources := []CredentialSource{NewDatabaseSource(db, cipher)}
+	if len(cfg.Sources) > 0 {
+		named, err := NewNamedSource(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, named)
+	}
+
+	return MultiSource(sources), nil
+}
+
+// DatabaseSource reads the credential tables through the vendor registry.
+type DatabaseSource struct {
+	db     *gorm.DB
+	cipher *secret.Cipher
+}
+
+func NewDatabaseSource(db *gorm.DB, cipher *secret.Cipher) *DatabaseSource {
+	return &DatabaseSource{db: db, cipher: cipher}
+}
+
+func (s *DatabaseSource) FindAll(_ context.Context, vendor string) ([]model.Credential, error) {
+	module, ok := registry.Lookup(vendor)
+	if !ok {
+		return nil, fmt.Errorf("vendor %s not supported", vendor)
+	}
+
+	return module.Credentials(s.db, s.cipher)
+}
+
+// NamedSource resolves credentials referenced by name in the config from
+// their providers. Every call fetches again, so rotated secrets are picked up
+// by the next job without touching the database.
+type NamedSource struct {
+	entries   []config.CredentialSourceConfig
+	providers map[string]Provider
+}
+
+func NewNamedSource(cfg config.CredentialsConfig) (*NamedSource, error) {
+	providers := map[string]Provider{
+		config.CredentialProviderEnv:  NewEnvProvider(),
+		config.CredentialProviderFile: NewFileProvider(cfg.FileDir),
+	}
+
+	for _, entry := range cfg.Sources {
+		if entry.Name == "" || entry.Key == "" {
+			return nil, fmt.Errorf("credential source %q: name and key are required", entry.Name)
+		}
+
+		if _, ok := registry.Lookup(entry.Vendor); !ok {
+			return nil, fmt.Errorf("credential source %s: vendor %q not supported", entry.Name, entry.Vendor)
+		}
+
+		if entry.Provider == config.CredentialProviderVault && providers[config.CredentialProviderVault] == nil {
+			vault, err := NewVaultProvider(cfg.Vault)
+			if err != nil {
+				return nil, fmt.Errorf("credential source %s: %w", entry.Name, err)
+			}
+
+			providers[config.CredentialProviderVault] = vault
+		}
+
+		if _, ok := providers[entry.Provider]; !ok {
+			return nil, fmt.Errorf("credential source %s: provider %q not supported", entry.Name, entry.Provider)
+		}
+	}
+
+	return &NamedSource{
+		entries:   cfg.Sources,
+		providers: providers,
+	}, nil
+}
+
+func (s *NamedSource) FindAll(ctx context.Context, vendor string) ([]model.Credential, error) {
+	module, ok := registry.Lookup(vendor)
+	if !ok {
+		return nil, fmt.Errorf("vendor %s not supported", vendor)
+	}
+
+	credentials := make([]model.Credential, 0)
+	var errs []error
+	for _, entry := range s.entries {
+		if entryModule, _ := registry.Lookup(entry.Vendor); entryModule.Name != module.Name {
+			continue
+		}
+
+		fields, err := s.providers[entry.Provider].Fetch(ctx, entry.Key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("credential source %s: %w", entry.Name, err))
+			continue
+		}
+
+		credential, err := Decode(module.Name, entry.Name, fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("credential source %s: %w", entry.Name, err))
+			continue
+		}
+
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, errors.Join(errs...)
+}
+
+// MultiSource concatenates the credentials of several sources.
+type MultiSource []CredentialSource
+
+func (m MultiSource) FindAll(ctx context.Context, vendor string) ([]model.Credential, error) {
+	credentials := make([]model.Credential, 0)
+	var errs []error
+	for _, source := range m {
+		found, err := source.FindAll(ctx, vendor)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		credentials = append(credentials, found...)
+	}
+
+	return credentials, errors.Join(errs...)
+}
+
+// Decode builds the credential model of a module from the fields of the
+// secret of the named credential name, using the same JSON names as the
+// credential tables. name is empty for credentials given otherwise.
+func Decode(vendor, name string, fields map[string]interface{}) (model.Credential, error) {
+	var credential model.Credential
+	switch vendor {
+	case model.VendorTypeGrowatt:
+		credential = &model.GrowattCredential{Name: name}
+	case model.VendorTypeHuawei, model.ModuleHuawei2:
+		credential = &model.HuaweiCredential{Name: name}
+	case model.VendorTypeKstar:
+		credential = &model.KstarCredential{Name: name}
+	case model.VendorTypeSolarman:
+		credential = &model.SolarmanCredential{Name: name}
+	default:
+		return nil, fmt.Errorf("vendor %s has no credential model", vendor)
+	}
+
+	if err := util.Recast(fields, credential); err != nil {
+		return nil, err
+	}
+
+	// The module, not the secret, decides which FusionSolar API is used.
+	if huawei, ok := credential.(*model.HuaweiCredential); ok {
+		huawei.Version = registry.HuaweiSupportedVersion
+		if vendor == model.ModuleHuawei2 {
+			huawei.Version = registry.Huawei2SupportedVersion
+		}
+	}
+
+	if strings.TrimSpace(credential.GetUsername()) == "" {
+		return nil, errors.New("username is required")
+	}
+
+	return credential, nil
+}
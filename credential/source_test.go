@@ -0,0 +1,50 @@
+package credential
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/registry"
+)
+
+func TestNamedSourceFindAll(t *testing.T) {
+	t.Setenv("TEST_HUAWEI2_MAIN", `{"username":"main","password":"secret","owner":"TRUE"}`)
+	t.Setenv("TEST_HUAWEI2_OPS", `{"username":"ops","password":"secret","owner":"TRUE"}`)
+
+	source, err := NewNamedSource(config.CredentialsConfig{
+		Sources: []config.CredentialSourceConfig{
+			{Name: "huawei2-main", Vendor: model.ModuleHuawei2, Provider: config.CredentialProviderEnv, Key: "TEST_HUAWEI2_MAIN"},
+			{Name: "huawei2-ops", Vendor: model.ModuleHuawei2, Provider: config.CredentialProviderEnv, Key: "TEST_HUAWEI2_OPS"},
+			{Name: "huawei-main", Vendor: model.VendorTypeHuawei, Provider: config.CredentialProviderEnv, Key: "TEST_HUAWEI2_MAIN"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewNamedSource() error = %v", err)
+	}
+
+	credentials, err := source.FindAll(context.Background(), model.ModuleHuawei2)
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+
+	if len(credentials) != 2 {
+		t.Fatalf("FindAll() returned %d credentials, want 2", len(credentials))
+	}
+
+	for i, want := range []string{"huawei2-main", "huawei2-ops"} {
+		huawei, ok := credentials[i].(*model.HuaweiCredential)
+		if !ok {
+			t.Fatalf("credential %d is %T, want *model.HuaweiCredential", i, credentials[i])
+		}
+
+		if huawei.GetID() != 0 || model.CredentialKey(huawei) != want {
+			t.Errorf("credential %d id = %d, key = %q, want id 0 and key %q", i, huawei.GetID(), model.CredentialKey(huawei), want)
+		}
+
+		if huawei.Version != registry.Huawei2SupportedVersion {
+			t.Errorf("credential %d version = %d, want %d", i, huawei.Version, registry.Huawei2SupportedVersion)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package healthcheck
+
+import (
+	"fmt"
+
+	"github.com/HavvokLab/true-solar/api/growatt"
+	"github.com/HavvokLab/true-solar/model"
+	"go.openly.dev/pointy"
+)
+
+type GrowattChecker struct{}
+
+func NewGrowattChecker() Checker {
+	return &GrowattChecker{}
+}
+
+// Check reads a single plant of the account. Growatt answers invalid tokens
+// with HTTP 200 and a non-zero error_code.
+func (c *GrowattChecker) Check(credential model.Credential) Result {
+	cred, ok := credential.(*model.GrowattCredential)
+	if !ok {
+		return invalidCredentialResult(model.VendorTypeGrowatt, credential)
+	}
+
+	probe := newProbe(model.VendorTypeGrowatt, "Growatt")
+	client := growatt.NewGrowattClient(
+		cred.Username,
+		cred.Token,
+		growatt.WithRetryCount(0),
+		growatt.WithTimeout(Timeout),
+		growatt.WithResponseHook(probe.hook),
+	)
+
+	resp, err := client.GetPlantListWithPagination(1, 1)
+	if err == nil && pointy.IntValue(resp.ErrorCode, 0) != 0 {
+		return probe.fail(cred, fmt.Sprintf(
+			"API returned error code %d: %s",
+			pointy.IntValue(resp.ErrorCode, 0),
+			pointy.StringValue(resp.ErrorMsg, "API error"),
+		))
+	}
+
+	return probe.result(cred, err)
+}
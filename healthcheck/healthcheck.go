@@ -0,0 +1,126 @@
+package healthcheck
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/imroc/req/v3"
+)
+
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+	StatusError     = "error"
+)
+
+// Timeout bounds every request of a health check. Checks never retry, so a
+// dead vendor API fails fast instead of waiting for the collector backoff.
+const Timeout = 10 * time.Second
+
+// Result is a single health check outcome. The JSON shape is the one the
+// former scripts/*_healthcheck.sh printed, so monitors can parse both.
+type Result struct {
+	Vendor       string `json:"vendor"`
+	Status       string `json:"status"`
+	HealthCode   int    `json:"health_code"`
+	Message      string `json:"message"`
+	HTTPCode     int    `json:"http_code"`
+	Raw          string `json:"raw"`
+	Timestamp    string `json:"timestamp"`
+	CredentialID int64  `json:"credential_id,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+}
+
+func (r Result) Healthy() bool {
+	return r.Status == StatusHealthy
+}
+
+// Checker logs in with a single credential and calls a cheap read-only
+// endpoint of the vendor API through the same client the collector uses.
+type Checker interface {
+	Check(credential model.Credential) Result
+}
+
+// probe remembers the status code and body of the last response of a
+// client, since the clients only return decoded results.
+type probe struct {
+	mu       sync.Mutex
+	vendor   string
+	title    string
+	httpCode int
+	raw      string
+}
+
+func newProbe(vendor, title string) *probe {
+	return &probe{vendor: vendor, title: title}
+}
+
+func (p *probe) hook(_ *req.Client, resp *req.Response) error {
+	if resp == nil || resp.Response == nil {
+		return nil
+	}
+
+	raw := resp.String()
+	p.mu.Lock()
+	p.httpCode = resp.StatusCode
+	p.raw = raw
+	p.mu.Unlock()
+	return nil
+}
+
+// result turns the outcome of the last call into a Result. A call that
+// never got a response is an error, a non-200 response is unhealthy and
+// an error reported inside a 200 response is an error again.
+func (p *probe) result(credential model.Credential, err error) Result {
+	p.mu.Lock()
+	httpCode, raw := p.httpCode, p.raw
+	p.mu.Unlock()
+
+	switch {
+	case err != nil && httpCode == 0:
+		return p.build(credential, StatusError, fmt.Sprintf("Failed to connect to %s API: %v", p.title, err), 0, "")
+	case httpCode != http.StatusOK:
+		return p.build(credential, StatusUnhealthy, "API health check failed", httpCode, raw)
+	case err != nil:
+		return p.build(credential, StatusError, fmt.Sprintf("API returned error: %v", err), httpCode, raw)
+	default:
+		return p.build(credential, StatusHealthy, "API is responding correctly", httpCode, raw)
+	}
+}
+
+func (p *probe) fail(credential model.Credential, message string) Result {
+	p.mu.Lock()
+	httpCode, raw := p.httpCode, p.raw
+	p.mu.Unlock()
+
+	return p.build(credential, StatusError, message, httpCode, raw)
+}
+
+func (p *probe) build(credential model.Credential, status, message string, httpCode int, raw string) Result {
+	result := Result{
+		Vendor:    p.vendor,
+		Status:    status,
+		Message:   message,
+		HTTPCode:  httpCode,
+		Raw:       raw,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if status == StatusHealthy {
+		result.HealthCode = 1
+	}
+
+	if credential != nil {
+		result.CredentialID = credential.GetID()
+		result.Owner = credential.GetOwner()
+	}
+
+	return result
+}
+
+func invalidCredentialResult(vendor string, credential model.Credential) Result {
+	return newProbe(vendor, vendor).build(credential, StatusError, fmt.Sprintf("unsupported credential type %T", credential), 0, "")
+}
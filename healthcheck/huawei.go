@@ -0,0 +1,66 @@
+package healthcheck
+
+import (
+	"github.com/HavvokLab/true-solar/api/huawei"
+	"github.com/HavvokLab/true-solar/api/huawei2"
+	"github.com/HavvokLab/true-solar/model"
+)
+
+type HuaweiChecker struct{}
+
+func NewHuaweiChecker() Checker {
+	return &HuaweiChecker{}
+}
+
+// Check logs in through /thirdData/login, which also handles the XSRF
+// cookie, and lists the stations of the account.
+func (c *HuaweiChecker) Check(credential model.Credential) Result {
+	cred, ok := credential.(*model.HuaweiCredential)
+	if !ok {
+		return invalidCredentialResult(model.VendorTypeHuawei, credential)
+	}
+
+	probe := newProbe(model.VendorTypeHuawei, "Huawei")
+	client, err := huawei.NewHuaweiClient(
+		cred.Username,
+		cred.Password,
+		huawei.WithRetryCount(0),
+		huawei.WithTimeout(Timeout),
+		huawei.WithResponseHook(probe.hook),
+	)
+	if err != nil {
+		return probe.result(cred, err)
+	}
+
+	_, err = client.GetPlantList()
+	return probe.result(cred, err)
+}
+
+type Huawei2Checker struct{}
+
+func NewHuawei2Checker() Checker {
+	return &Huawei2Checker{}
+}
+
+// Check logs in and reads the first page of the northbound v2 station list.
+func (c *Huawei2Checker) Check(credential model.Credential) Result {
+	cred, ok := credential.(*model.HuaweiCredential)
+	if !ok {
+		return invalidCredentialResult(model.ModuleHuawei2, credential)
+	}
+
+	probe := newProbe(model.ModuleHuawei2, "Huawei2")
+	client, err := huawei2.NewHuawei2Client(
+		cred.Username,
+		cred.Password,
+		huawei2.WithRetryCount(0),
+		huawei2.WithTimeout(Timeout),
+		huawei2.WithResponseHook(probe.hook),
+	)
+	if err != nil {
+		return probe.result(cred, err)
+	}
+
+	_, err = client.GetPlantListWithPagination(1)
+	return probe.result(cred, err)
+}
@@ -0,0 +1,32 @@
+package healthcheck
+
+import (
+	"github.com/HavvokLab/true-solar/api/kstar"
+	"github.com/HavvokLab/true-solar/model"
+)
+
+type KstarChecker struct{}
+
+func NewKstarChecker() Checker {
+	return &KstarChecker{}
+}
+
+// Check calls /power/info with the signed user code and password.
+func (c *KstarChecker) Check(credential model.Credential) Result {
+	cred, ok := credential.(*model.KstarCredential)
+	if !ok {
+		return invalidCredentialResult(model.VendorTypeKstar, credential)
+	}
+
+	probe := newProbe(model.VendorTypeKstar, "Kstar")
+	client := kstar.NewKstarClient(
+		cred.Username,
+		cred.Password,
+		kstar.WithRetryCount(0),
+		kstar.WithTimeout(Timeout),
+		kstar.WithResponseHook(probe.hook),
+	)
+
+	_, err := client.GetPlantList()
+	return probe.result(cred, err)
+}
@@ -0,0 +1,50 @@
+package healthcheck
+
+import (
+	"github.com/HavvokLab/true-solar/api/solarman"
+	"github.com/HavvokLab/true-solar/model"
+	"go.openly.dev/pointy"
+)
+
+type SolarmanChecker struct{}
+
+func NewSolarmanChecker() Checker {
+	return &SolarmanChecker{}
+}
+
+// Check requests a basic token with the app credentials and reads the
+// account info with it.
+func (c *SolarmanChecker) Check(credential model.Credential) Result {
+	cred, ok := credential.(*model.SolarmanCredential)
+	if !ok {
+		return invalidCredentialResult(model.VendorTypeSolarman, credential)
+	}
+
+	probe := newProbe(model.VendorTypeSolarman, "Solarman")
+	client := solarman.NewSolarmanClient(
+		cred.Username,
+		cred.Password,
+		cred.AppID,
+		cred.AppSecret,
+		solarman.WithRetryCount(0),
+		solarman.WithTimeout(Timeout),
+		solarman.WithResponseHook(probe.hook),
+	)
+
+	token, err := client.GetBasicToken()
+	if err != nil {
+		return probe.result(cred, err)
+	}
+
+	if pointy.StringValue(token.AccessToken, "") == "" {
+		return probe.fail(cred, pointy.StringValue(token.Message, "Failed to extract access token from response"))
+	}
+
+	client.SetAccessToken(pointy.StringValue(token.AccessToken, ""))
+	info, err := client.GetUserInfo()
+	if err == nil && !pointy.BoolValue(info.Success, true) {
+		return probe.fail(cred, pointy.StringValue(info.Message, "API returned unexpected response"))
+	}
+
+	return probe.result(cred, err)
+}
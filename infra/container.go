@@ -0,0 +1,176 @@
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/pkg/pool"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/go-redis/redis/v8"
+	"github.com/olivere/elastic/v7"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Container holds the connections of a command, built from its config.
+// Connections are opened on first use, so a command only connects to what it
+// needs and importing infra connects to nothing. A failed connection is
+// retried by the next call. Close releases every opened connection.
+type Container struct {
+	conf config.Config
+
+	gormLogger gormlogger.Interface
+	bulkPool   *pool.Pool
+	cipher     *secret.Cipher
+	cipherErr  error
+
+	mu      sync.Mutex
+	elastic *elastic.Client
+	db      *gorm.DB
+	redis   *redis.Client
+}
+
+type ContainerOption func(*Container)
+
+// WithGormLogger replaces the default GORM logger, which writes to stdout,
+// for the store opened by DB.
+func WithGormLogger(logger gormlogger.Interface) ContainerOption {
+	return func(c *Container) {
+		c.gormLogger = logger
+	}
+}
+
+// NewContainer returns a container of conf. It warns when no credential key
+// is set, credential secrets are then written in plaintext.
+func NewContainer(conf config.Config, opts ...ContainerOption) *Container {
+	if conf.Security.CredentialKey == "" {
+		log.Warn().Msg("security.credential_key is not set, credential secrets are stored in plaintext")
+	}
+
+	c := &Container{
+		conf:     conf,
+		bulkPool: pool.New(conf.Elastic.Bulk.WithDefaults().Concurrency),
+	}
+	c.cipher, c.cipherErr = secret.NewCipherFromKey(conf.Security.CredentialKey)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Config returns the config the container was built from.
+func (c *Container) Config() config.Config {
+	return c.conf
+}
+
+// Elastic returns the Elasticsearch client, connecting on first use.
+func (c *Container) Elastic() (*elastic.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.elastic == nil {
+		client, err := NewElasticClient(c.conf.Elastic)
+		if err != nil {
+			return nil, fmt.Errorf("connect elasticsearch: %w", err)
+		}
+		c.elastic = client
+	}
+
+	return c.elastic, nil
+}
+
+// DB returns the relational store, opening it on first use. It neither
+// migrates nor checks the schema, commands do so with migration.Prepare.
+func (c *Container) DB() (*gorm.DB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db == nil {
+		opts := make([]gorm.Option, 0, 1)
+		if c.gormLogger != nil {
+			opts = append(opts, &gorm.Config{Logger: c.gormLogger})
+		}
+
+		db, err := NewGormDB(c.conf.Database, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
+		c.db = db
+	}
+
+	return c.db, nil
+}
+
+// Redis returns the Redis client, connecting on first use.
+func (c *Container) Redis() (*redis.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.redis == nil {
+		rdb, err := NewRedis(c.conf.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("connect redis: %w", err)
+		}
+		c.redis = rdb
+	}
+
+	return c.redis, nil
+}
+
+// Snmp returns a new orchestrator sending traps of trapType to the
+// configured targets.
+func (c *Container) Snmp(trapType TrapType) (*SnmpOrchestrator, error) {
+	return NewSnmpOrchestrator(trapType, c.conf.SnmpList)
+}
+
+// BulkPool returns the pool every solar repository of the command sends its
+// bulk requests on, sized by the bulk concurrency.
+func (c *Container) BulkPool() *pool.Pool {
+	return c.bulkPool
+}
+
+// CredentialCipher returns the cipher of the credential secrets, built from
+// security.credential_key. It is nil when no key is set, secrets are then
+// stored as is. An invalid key fails every call.
+func (c *Container) CredentialCipher() (*secret.Cipher, error) {
+	if c.cipherErr != nil {
+		return nil, fmt.Errorf("credential key: %w", c.cipherErr)
+	}
+
+	return c.cipher, nil
+}
+
+// Close releases the opened connections. The container can be used again
+// afterwards, connections are then opened anew.
+func (c *Container) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	if c.elastic != nil {
+		CloseElasticClient(c.elastic)
+		c.elastic = nil
+	}
+
+	if c.db != nil {
+		if sqlDB, err := c.db.DB(); err != nil {
+			errs = append(errs, err)
+		} else if err := sqlDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close database: %w", err))
+		}
+		c.db = nil
+	}
+
+	if c.redis != nil {
+		if err := c.redis.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close redis: %w", err))
+		}
+		c.redis = nil
+	}
+
+	return errors.Join(errs...)
+}
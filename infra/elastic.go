@@ -2,9 +2,13 @@ package infra
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
-	"regexp"
+	"net/url"
+	"os"
 	"time"
 
 	"github.com/HavvokLab/true-solar/config"
@@ -30,23 +34,32 @@ const (
 	ESHealthcheckTimeout = 60 * time.Second
 )
 
-var httpsRegexp = regexp.MustCompile("^https")
+// NewElasticClient creates a new Elasticsearch client with optimized connection
+// pooling. It pings the cluster and fails when no node answers.
+func NewElasticClient(conf config.ElasticsearchConfig) (*elastic.Client, error) {
+	urls := conf.URLs()
+	if len(urls) == 0 {
+		return nil, errors.New("elasticsearch: no host configured")
+	}
 
-var ElasticClient *elastic.Client
+	scheme, err := elasticScheme(urls)
+	if err != nil {
+		return nil, err
+	}
 
-func init() {
-	var err error
-	ElasticClient, err = NewElasticClient()
+	tlsConfig, err := elasticTLSConfig(conf.TLS)
 	if err != nil {
-		log.Panic().Err(err).Msg("failed to initialize elasticsearch client")
+		return nil, err
+	}
+
+	auth, err := elasticAuth(conf)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// NewElasticClient creates a new Elasticsearch client with optimized connection pooling
-func NewElasticClient() (*elastic.Client, error) {
 	httpClient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig:     tlsConfig,
 			MaxIdleConns:        ESMaxIdleConns,
 			MaxIdleConnsPerHost: ESMaxIdleConnsPerHost,
 			MaxConnsPerHost:     ESMaxConnsPerHost,
@@ -60,27 +73,112 @@ func NewElasticClient() (*elastic.Client, error) {
 		},
 	}
 
-	conf := config.GetConfig().Elastic
-	scheme := "http"
-	if httpsRegexp.MatchString(conf.Host) {
-		scheme = "http" // Keep HTTP even for HTTPS URLs (user preference)
-	}
-
-	return elastic.NewClient(
-		elastic.SetURL(conf.Host),
+	options := []elastic.ClientOptionFunc{
+		elastic.SetURL(urls...),
+		// Sniffed nodes are reached with the scheme of the configured hosts.
 		elastic.SetScheme(scheme),
-		elastic.SetBasicAuth(conf.Username, conf.Password),
-		elastic.SetSniff(false),
+		elastic.SetSniff(conf.Sniff),
 		elastic.SetHttpClient(httpClient),
 		elastic.SetHealthcheckTimeout(ESHealthcheckTimeout),
-	)
+	}
+
+	return elastic.NewClient(append(options, auth...)...)
+}
+
+// elasticScheme returns the scheme shared by the hosts. Mixing http and https
+// hosts is refused, a typo would otherwise send credentials in clear text.
+func elasticScheme(urls []string) (string, error) {
+	scheme := ""
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", fmt.Errorf("elasticsearch: invalid host %q: %w", rawURL, err)
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return "", fmt.Errorf("elasticsearch: host %q must start with http:// or https://", rawURL)
+		}
+
+		if scheme != "" && u.Scheme != scheme {
+			return "", errors.New("elasticsearch: hosts mix http and https")
+		}
+		scheme = u.Scheme
+	}
+
+	return scheme, nil
+}
+
+// elasticTLSConfig verifies the cluster certificate against the system roots
+// and the configured CA file, and presents the client certificate if set.
+func elasticTLSConfig(conf config.ElasticsearchTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: conf.ServerName,
+	}
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("elasticsearch: read ca file: %w", err)
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("elasticsearch: no certificate found in ca file %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, errors.New("elasticsearch: tls cert_file and key_file must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("elasticsearch: load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// elasticAuth returns the client option of the configured authentication.
+// API keys are the base64 "encoded" value returned when the key is created.
+func elasticAuth(conf config.ElasticsearchConfig) ([]elastic.ClientOptionFunc, error) {
+	methods := 0
+	for _, set := range []bool{conf.Username != "", conf.APIKey != "", conf.BearerToken != ""} {
+		if set {
+			methods++
+		}
+	}
+
+	if methods > 1 {
+		return nil, errors.New("elasticsearch: set only one of username, api_key and bearer_token")
+	}
+
+	switch {
+	case conf.APIKey != "":
+		return []elastic.ClientOptionFunc{elastic.SetHeaders(http.Header{"Authorization": {"ApiKey " + conf.APIKey}})}, nil
+	case conf.BearerToken != "":
+		return []elastic.ClientOptionFunc{elastic.SetHeaders(http.Header{"Authorization": {"Bearer " + conf.BearerToken}})}, nil
+	case conf.Username != "":
+		return []elastic.ClientOptionFunc{elastic.SetBasicAuth(conf.Username, conf.Password)}, nil
+	default:
+		return nil, nil
+	}
 }
 
 // CloseElasticClient gracefully closes the Elasticsearch client
 // Call this during application shutdown to release resources
-func CloseElasticClient() {
-	if ElasticClient != nil {
-		ElasticClient.Stop()
+func CloseElasticClient(client *elastic.Client) {
+	if client != nil {
+		client.Stop()
 		log.Info().Msg("elasticsearch client stopped")
 	}
 }
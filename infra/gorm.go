@@ -1,31 +1,71 @@
 package infra
 
 import (
-	"github.com/rs/zerolog/log"
+	"fmt"
+
+	"github.com/HavvokLab/true-solar/config"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-var GormDB *gorm.DB
-
-func init() {
-	var err error
-	GormDB, err = NewGormDB()
+// NewGormDB opens the relational store of conf, the SQLite file
+// config.DefaultDatabaseDSN when nothing is configured. opts are passed to
+// gorm.Open, e.g. a *gorm.Config with another logger.
+func NewGormDB(conf config.DatabaseConfig, opts ...gorm.Option) (*gorm.DB, error) {
+	conf = conf.WithDefaults()
+	dialector, err := gormDialector(conf)
 	if err != nil {
-		log.Panic().Err(err).Msg("failed to initialize gorm db")
+		return nil, err
 	}
-}
 
-func NewGormDB(paths ...string) (*gorm.DB, error) {
-	var path string = "database.db"
-	if len(paths) > 0 {
-		path = paths[0]
+	db, err := gorm.Open(dialector, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
 
+	if conf.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+
+	if conf.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+
+	if conf.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	}
+
 	return db, nil
 }
+
+func gormDialector(conf config.DatabaseConfig) (gorm.Dialector, error) {
+	if conf.DSN == "" {
+		return nil, fmt.Errorf("database dsn of driver %s is not set", conf.Driver)
+	}
+
+	switch conf.Driver {
+	case config.DatabaseDriverSQLite:
+		return sqlite.Open(conf.DSN), nil
+	case config.DatabaseDriverPostgres:
+		return postgres.Open(conf.DSN), nil
+	case config.DatabaseDriverMySQL:
+		// Timestamps are scanned into time.Time only with parseTime.
+		dsn, err := mysqldriver.ParseDSN(conf.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("parse mysql dsn: %w", err)
+		}
+
+		dsn.ParseTime = true
+		return mysql.Open(dsn.FormatDSN()), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", conf.Driver)
+	}
+}
@@ -7,8 +7,7 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
-func NewRedis() (*redis.Client, error) {
-	conf := config.GetConfig().Redis
+func NewRedis(conf config.RedisConfig) (*redis.Client, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     conf.Host + ":" + conf.Port,
 		Username: conf.Username,
@@ -18,6 +17,7 @@ func NewRedis() (*redis.Client, error) {
 
 	ctx := context.Background()
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
 		return nil, err
 	}
 
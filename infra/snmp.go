@@ -5,6 +5,7 @@ import (
 
 	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
 	"github.com/gosnmp/gosnmp"
 	"github.com/rs/zerolog"
 )
@@ -69,6 +70,7 @@ func (s *SnmpOrchestrator) SendTrap(deviceName, alertName, description, severity
 				Str("severity", severity).
 				Str("lasted_update_time", lastedUpdateTime).
 				Msg("failed to send trap")
+			metrics.IncSnmpTrap(s.trapType.String(), metrics.TrapResultFailed)
 		} else {
 			s.logger.Info().
 				Str("agent_host", client.agentHost).
@@ -81,6 +83,7 @@ func (s *SnmpOrchestrator) SendTrap(deviceName, alertName, description, severity
 				Str("severity", severity).
 				Str("lasted_update_time", lastedUpdateTime).
 				Msg("send trap success")
+			metrics.IncSnmpTrap(s.trapType.String(), metrics.TrapResultSent)
 		}
 	}
 }
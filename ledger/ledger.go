@@ -0,0 +1,67 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/rs/zerolog"
+)
+
+// Ledger records job runs into tbl_job_runs. Failing to write the ledger is
+// logged but never fails the job being recorded.
+type Ledger struct {
+	jobRunRepo repo.JobRunRepo
+	logger     zerolog.Logger
+}
+
+func NewLedger(jobRunRepo repo.JobRunRepo) *Ledger {
+	return &Ledger{
+		jobRunRepo: jobRunRepo,
+		logger:     zerolog.New(logger.NewWriter("ledger.log")).With().Timestamp().Caller().Logger(),
+	}
+}
+
+// Start records a running job. credential is nil for job-level runs. Named
+// credentials have no id and are recorded by name.
+func (l *Ledger) Start(jobName, vendor string, credential model.Credential) *model.JobRun {
+	run := &model.JobRun{
+		JobName:   jobName,
+		Vendor:    vendor,
+		StartedAt: time.Now(),
+		Status:    model.JobRunStatusRunning,
+	}
+
+	if credential != nil {
+		if name := credential.GetName(); name != "" {
+			run.CredentialName = name
+		} else {
+			credentialID := credential.GetID()
+			run.CredentialID = &credentialID
+		}
+	}
+
+	if err := l.jobRunRepo.Create(run); err != nil {
+		l.logger.Error().Err(err).Str("job", jobName).Msg("Ledger::Start() - failed to create job run")
+	}
+
+	return run
+}
+
+// Finish closes a run with the outcome of err and the counters of the run.
+func (l *Ledger) Finish(run *model.JobRun, err error, documentsIndexed, sitesUpserted int64) {
+	now := time.Now()
+	run.FinishedAt = &now
+	run.DocumentsIndexed = documentsIndexed
+	run.SitesUpserted = sitesUpserted
+	run.Status = model.JobRunStatusSuccess
+	if err != nil {
+		run.Status = model.JobRunStatusFailed
+		run.Error = err.Error()
+	}
+
+	if updateErr := l.jobRunRepo.Update(run); updateErr != nil {
+		l.logger.Error().Err(updateErr).Str("job", run.JobName).Msg("Ledger::Finish() - failed to update job run")
+	}
+}
@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// The lock held by Migrate: lockID keys the PostgreSQL advisory lock and
+// lockName names the MySQL lock, which is waited for up to lockTimeout.
+const (
+	lockID      = 4711020240601
+	lockName    = "true_solar_schema_migrations"
+	lockTimeout = 5 * time.Minute
+)
+
+// Migration is one versioned change of the relational store. Up runs in a
+// transaction together with the record of the version, except on MySQL
+// where DDL commits implicitly.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// Status is a migration and when it was applied, nil while pending.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the migrations of the store, oldest first.
+func Migrations() []Migration {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return sorted
+}
+
+// Latest returns the version of the newest migration.
+func Latest() int {
+	all := Migrations()
+	return all[len(all)-1].Version
+}
+
+// Migrate applies the pending migrations in version order and returns them.
+// It holds the migration lock of the store meanwhile, so hosts starting
+// together apply each migration once. It stops at the first failing
+// migration, the earlier ones stay applied.
+func Migrate(db *gorm.DB) ([]Migration, error) {
+	done := make([]Migration, 0)
+	err := db.Connection(func(conn *gorm.DB) error {
+		// Statements on conn would share its clauses, e.g. the table of the
+		// first one.
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		unlock, err := lock(conn)
+		if err != nil {
+			return fmt.Errorf("lock schema migrations: %w", err)
+		}
+		defer unlock()
+
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range Migrations() {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+
+				return tx.Create(&model.SchemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// lock takes the lock serializing the migrations of several hosts on conn, a
+// single connection, and returns its release: a session advisory lock on
+// PostgreSQL and a named lock on MySQL. A SQLite file is not shared by hosts,
+// its write lock serializes the migration transactions and the version
+// primary key rejects a migration recorded twice.
+func lock(conn *gorm.DB) (func(), error) {
+	switch conn.Dialector.Name() {
+	case config.DatabaseDriverPostgres:
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+			return nil, err
+		}
+
+		return func() {
+			conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+		}, nil
+	case config.DatabaseDriverMySQL:
+		var locked *int
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&locked).Error; err != nil {
+			return nil, err
+		}
+
+		if locked == nil || *locked != 1 {
+			return nil, fmt.Errorf("lock %s not acquired within %s", lockName, lockTimeout)
+		}
+
+		return func() {
+			conn.Exec("SELECT RELEASE_LOCK(?)", lockName)
+		}, nil
+	default:
+		return func() {}, nil
+	}
+}
+
+// Version returns the newest applied version, 0 for a store never migrated.
+// It does not create the version table.
+func Version(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&model.SchemaMigration{}) {
+		return 0, nil
+	}
+
+	var version *int
+	if err := db.Model(&model.SchemaMigration{}).Select("MAX(version)").Scan(&version).Error; err != nil {
+		return 0, err
+	}
+
+	if version == nil {
+		return 0, nil
+	}
+
+	return *version, nil
+}
+
+// Check fails with ErrSchemaOutdated while migrations of this build are not
+// applied. A store migrated by a newer build passes, migrations only add.
+func Check(db *gorm.DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	if latest := Latest(); version < latest {
+		return fmt.Errorf("%w: version %d, this build needs %d, run db migrate", ErrSchemaOutdated, version, latest)
+	}
+
+	return nil
+}
+
+// Prepare readies a store a command just opened: it applies the pending
+// migrations when migrate is set and logs them, otherwise it runs Check.
+// Commands pass database.auto_migrate through DatabaseConfig.MigrateOnOpen.
+func Prepare(db *gorm.DB, migrate bool) error {
+	if !migrate {
+		return Check(db)
+	}
+
+	applied, err := Migrate(db)
+	if err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+
+	for _, m := range applied {
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("applied database migration")
+	}
+
+	return nil
+}
+
+// Statuses lists every migration with the time it was applied.
+func Statuses(db *gorm.DB) ([]Status, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range Migrations() {
+		status := Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// appliedVersions creates the version table when missing and returns its rows
+// by version.
+func appliedVersions(db *gorm.DB) (map[int]model.SchemaMigration, error) {
+	if err := db.AutoMigrate(&model.SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("create schema version table: %w", err)
+	}
+
+	var rows []model.SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]model.SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
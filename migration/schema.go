@@ -0,0 +1,362 @@
+package migration
+
+import (
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"go.openly.dev/pointy"
+	"gorm.io/gorm"
+)
+
+// migrations lists every schema change. A released migration is never
+// edited, later changes get a new version. The structs below are the tables
+// as of their migration and do not follow the models of package model.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create credential, site region, installed capacity and performance alarm config tables",
+		Up: func(tx *gorm.DB) error {
+			err := createOrExtend(tx,
+				&huaweiCredentialV1{},
+				&kstarCredentialV1{},
+				&growattCredentialV1{},
+				&solarmanCredentialV1{},
+				&siteRegionMappingV1{},
+				&installedCapacityV1{},
+				&performanceAlarmConfigV1{},
+			)
+			if err != nil {
+				return err
+			}
+
+			return seedV1(tx)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create job run ledger",
+		Up: func(tx *gorm.DB) error {
+			return createOrExtend(tx, &jobRunV2{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create and seed alarm severity mapping",
+		Up: func(tx *gorm.DB) error {
+			if err := createOrExtend(tx, &alarmSeverityMappingV3{}); err != nil {
+				return err
+			}
+
+			return seedV3(tx)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create maintenance windows",
+		Up: func(tx *gorm.DB) error {
+			return createOrExtend(tx, &maintenanceWindowV4{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add credential name to job run ledger",
+		Up: func(tx *gorm.DB) error {
+			return createOrExtend(tx, &jobRunV5{})
+		},
+	},
+}
+
+// createOrExtend creates the tables of models, or adds the missing columns
+// and indexes to tables created before migrations existed. Existing columns
+// are left as they are.
+func createOrExtend(tx *gorm.DB, models ...interface{}) error {
+	migrator := tx.Migrator()
+	for _, m := range models {
+		if !migrator.HasTable(m) {
+			if err := migrator.CreateTable(m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(m); err != nil {
+			return err
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || migrator.HasColumn(m, field.DBName) {
+				continue
+			}
+
+			if err := migrator.AddColumn(m, field.DBName); err != nil {
+				return err
+			}
+		}
+
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if migrator.HasIndex(m, index.Name) {
+				continue
+			}
+
+			if err := migrator.CreateIndex(m, index.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// seedV1 writes the installed capacity row and the low and sum performance
+// alarm configs the alarms need. Rows already present are kept.
+func seedV1(tx *gorm.DB) error {
+	now := time.Now()
+
+	var capacities int64
+	if err := tx.Model(&installedCapacityV1{}).Count(&capacities).Error; err != nil {
+		return err
+	}
+
+	if capacities == 0 {
+		err := tx.Create(&installedCapacityV1{
+			EfficiencyFactor: config.InstalledCapacityEfficiencyFactor,
+			FocusHour:        config.InstalledCapacityFocusHour,
+			CreatedAt:        &now,
+			UpdatedAt:        &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	alarmConfigs := []performanceAlarmConfigV1{
+		{
+			Name:       config.LowPerformanceAlarm,
+			Interval:   config.LowPerformanceAlarmInterval,
+			HitDay:     pointy.Int(config.LowPerformanceAlarmHitDay),
+			Percentage: config.LowPerformanceAlarmPercentage,
+			Duration:   pointy.Int(config.LowPerformanceAlarmDuration),
+		},
+		{
+			Name:       config.SumPerformanceAlarm,
+			Interval:   config.SumPerformanceAlarmInterval,
+			HitDay:     pointy.Int(config.SumPerformanceAlarmHitDay),
+			Percentage: config.SumPerformanceAlarmPercentage,
+			Duration:   pointy.Int(config.SumPerformanceAlarmDuration),
+		},
+	}
+
+	for _, alarmConfig := range alarmConfigs {
+		var count int64
+		if err := tx.Model(&performanceAlarmConfigV1{}).Where("name = ?", alarmConfig.Name).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		alarmConfig.CreatedAt = &now
+		alarmConfig.UpdatedAt = &now
+		if err := tx.Create(&alarmConfig).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedV3 writes the severity mappings of an empty table. Alarms without a
+// better match stay major and Growatt disconnects minor as before, Huawei
+// alarms follow their level: 1 critical, 2 major, 3 minor and 4 warning.
+func seedV3(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&alarmSeverityMappingV3{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	mappings := []alarmSeverityMappingV3{
+		{Vendor: "*", Code: "*", Severity: "5"},
+		{Vendor: "growatt", Code: "Disconnect", Severity: "4"},
+		{Vendor: "huawei", Code: "*", Level: pointy.Int(1), Severity: "6"},
+		{Vendor: "huawei", Code: "*", Level: pointy.Int(2), Severity: "5"},
+		{Vendor: "huawei", Code: "*", Level: pointy.Int(3), Severity: "4"},
+		{Vendor: "huawei", Code: "*", Level: pointy.Int(4), Severity: "3"},
+	}
+
+	for i := range mappings {
+		mappings[i].CreatedAt = &now
+		mappings[i].UpdatedAt = &now
+	}
+
+	return tx.Create(&mappings).Error
+}
+
+type huaweiCredentialV1 struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Username  string `gorm:"column:username"`
+	Password  string `gorm:"column:password"`
+	Owner     string `gorm:"column:owner"`
+	Version   int    `gorm:"column:version"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*huaweiCredentialV1) TableName() string {
+	return "tbl_huawei_credentials"
+}
+
+type kstarCredentialV1 struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Username  string `gorm:"column:username"`
+	Password  string `gorm:"column:password"`
+	Owner     string `gorm:"column:owner"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*kstarCredentialV1) TableName() string {
+	return "tbl_kstar_credentials"
+}
+
+type growattCredentialV1 struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Username  string `gorm:"column:username"`
+	Password  string `gorm:"column:password"`
+	Token     string `gorm:"column:token"`
+	Owner     string `gorm:"column:owner"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*growattCredentialV1) TableName() string {
+	return "tbl_growatt_credentials"
+}
+
+type solarmanCredentialV1 struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Username  string `gorm:"column:username"`
+	Password  string `gorm:"column:password"`
+	AppSecret string `gorm:"column:app_secret"`
+	AppID     string `gorm:"column:app_id"`
+	Owner     string `gorm:"column:owner"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*solarmanCredentialV1) TableName() string {
+	return "tbl_solarman_credentials"
+}
+
+type siteRegionMappingV1 struct {
+	ID        int64   `gorm:"column:id;primaryKey;autoIncrement"`
+	Code      string  `gorm:"column:code;size:64;index"`
+	Name      string  `gorm:"column:name"`
+	Area      *string `gorm:"column:area"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*siteRegionMappingV1) TableName() string {
+	return "tbl_site_region_mapping"
+}
+
+type installedCapacityV1 struct {
+	ID               int64   `gorm:"column:id;primaryKey;autoIncrement"`
+	EfficiencyFactor float64 `gorm:"column:efficiency_factor"`
+	FocusHour        int     `gorm:"column:focus_hour"`
+	CreatedAt        *time.Time
+	UpdatedAt        *time.Time
+}
+
+func (*installedCapacityV1) TableName() string {
+	return "tbl_installed_capacity"
+}
+
+type performanceAlarmConfigV1 struct {
+	ID         int64   `gorm:"column:id;primaryKey;autoIncrement"`
+	Name       string  `gorm:"column:name;size:64;index"`
+	Interval   int     `gorm:"column:interval"`
+	HitDay     *int    `gorm:"column:hit_day"`
+	Percentage float64 `gorm:"column:percentage"`
+	Duration   *int    `gorm:"column:duration"`
+	CreatedAt  *time.Time
+	UpdatedAt  *time.Time
+}
+
+func (*performanceAlarmConfigV1) TableName() string {
+	return "tbl_performance_alarm_config"
+}
+
+type jobRunV2 struct {
+	ID               int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	JobName          string     `gorm:"column:job_name;size:128;index"`
+	Vendor           string     `gorm:"column:vendor;size:32;index"`
+	CredentialID     *int64     `gorm:"column:credential_id"`
+	StartedAt        time.Time  `gorm:"column:started_at;index"`
+	FinishedAt       *time.Time `gorm:"column:finished_at"`
+	Status           string     `gorm:"column:status;size:16;index"`
+	Error            string     `gorm:"column:error"`
+	DocumentsIndexed int64      `gorm:"column:documents_indexed"`
+	SitesUpserted    int64      `gorm:"column:sites_upserted"`
+}
+
+func (*jobRunV2) TableName() string {
+	return "tbl_job_runs"
+}
+
+type alarmSeverityMappingV3 struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Vendor    string `gorm:"column:vendor;size:32;index"`
+	Code      string `gorm:"column:code;size:255"`
+	Level     *int   `gorm:"column:level"`
+	Severity  string `gorm:"column:severity;size:8"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*alarmSeverityMappingV3) TableName() string {
+	return "tbl_alarm_severity_mapping"
+}
+
+type maintenanceWindowV4 struct {
+	ID        int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	Vendor    string    `gorm:"column:vendor;size:32"`
+	SiteID    string    `gorm:"column:site_id;size:64"`
+	Plant     string    `gorm:"column:plant;size:255"`
+	DeviceSN  string    `gorm:"column:device_sn;size:255"`
+	Area      string    `gorm:"column:area;size:255"`
+	StartAt   time.Time `gorm:"column:start_at;index"`
+	EndAt     time.Time `gorm:"column:end_at;index"`
+	Reason    string    `gorm:"column:reason;size:255"`
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+func (*maintenanceWindowV4) TableName() string {
+	return "tbl_maintenance_windows"
+}
+
+type jobRunV5 struct {
+	ID               int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	JobName          string     `gorm:"column:job_name;size:128;index"`
+	Vendor           string     `gorm:"column:vendor;size:32;index"`
+	CredentialID     *int64     `gorm:"column:credential_id"`
+	CredentialName   string     `gorm:"column:credential_name;size:255"`
+	StartedAt        time.Time  `gorm:"column:started_at;index"`
+	FinishedAt       *time.Time `gorm:"column:finished_at"`
+	Status           string     `gorm:"column:status;size:16;index"`
+	Error            string     `gorm:"column:error"`
+	DocumentsIndexed int64      `gorm:"column:documents_indexed"`
+	SitesUpserted    int64      `gorm:"column:sites_upserted"`
+}
+
+func (*jobRunV5) TableName() string {
+	return "tbl_job_runs"
+}
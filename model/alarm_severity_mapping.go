@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// AlarmSeverityWildcard matches any vendor, or any run of characters in an
+// alarm code.
+const AlarmSeverityWildcard = "*"
+
+// AlarmSeverityMapping maps vendor alarms to a trap severity. Code matches the
+// alarm code or name and may contain AlarmSeverityWildcard, Vendor is a vendor
+// type or the wildcard. A nil Level matches every level the vendor reports.
+type AlarmSeverityMapping struct {
+	ID        int64      `gorm:"column:id" json:"id"`
+	Vendor    string     `gorm:"column:vendor" json:"vendor"`
+	Code      string     `gorm:"column:code" json:"code"`
+	Level     *int       `gorm:"column:level" json:"level"`
+	Severity  string     `gorm:"column:severity" json:"severity"`
+	CreatedAt *time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (*AlarmSeverityMapping) TableName() string {
+	return "tbl_alarm_severity_mapping"
+}
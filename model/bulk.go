@@ -16,3 +16,39 @@ type BulkDocument struct {
 	DocumentId string         `json:"document_id"`
 	Document   map[string]any `json:"document,omitempty"`
 }
+
+// BulkResult counts the items of a bulk request. Retried counts the items
+// sent again after a connection error or a rejection by a busy cluster, an
+// item sent three times counts twice. Failed items were rejected for good,
+// DeadLettered of them were written to the dead-letter index. Pending holds
+// the positions, among the documents of one BulkIndex call, of those left
+// unindexed by connection errors or a busy cluster; sending them again may
+// succeed.
+type BulkResult struct {
+	Indexed      int
+	Retried      int
+	Failed       int
+	DeadLettered int
+	Pending      []int
+}
+
+// Add sums the counts of other into r. Pending is left out, its positions
+// belong to the call that returned it.
+func (r *BulkResult) Add(other BulkResult) {
+	r.Indexed += other.Indexed
+	r.Retried += other.Retried
+	r.Failed += other.Failed
+	r.DeadLettered += other.DeadLettered
+}
+
+// DeadLetterItem is a document Elasticsearch refused to index. Source is the
+// document as a string, so it cannot conflict with the mapping again.
+type DeadLetterItem struct {
+	Timestamp  time.Time `json:"@timestamp"`
+	Index      string    `json:"index"`
+	DocumentID string    `json:"document_id"`
+	Status     int       `json:"status"`
+	ErrorType  string    `json:"error_type"`
+	Reason     string    `json:"reason"`
+	Source     string    `json:"source"`
+}
@@ -1,9 +1,42 @@
 package model
 
 import (
+	"encoding/json"
+	"strconv"
 	"time"
 )
 
+// Credential is the vendor-agnostic view of a credential row, used by the
+// vendor registry to hand credentials to collectors, alarms and troubleshooters.
+type Credential interface {
+	GetID() int64
+	GetName() string
+	GetUsername() string
+	GetOwner() string
+}
+
+// CredentialKey identifies a credential in the job ledger and the metrics:
+// the name of a named credential, the id of a credential row otherwise.
+func CredentialKey(c Credential) string {
+	if name := c.GetName(); name != "" {
+		return name
+	}
+
+	return strconv.FormatInt(c.GetID(), 10)
+}
+
+// RedactedSecret replaces secret fields whenever a credential is marshaled to
+// JSON, so secrets never reach logs or API responses.
+const RedactedSecret = "******"
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return RedactedSecret
+}
+
 type HuaweiCredential struct {
 	ID        int64      `gorm:"column:id" json:"id"`
 	Username  string     `gorm:"column:username" json:"username"`
@@ -12,12 +45,43 @@ type HuaweiCredential struct {
 	Version   int        `gorm:"column:version" json:"version"`
 	CreatedAt *time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt *time.Time `gorm:"column:updated_at" json:"updated_at"`
+
+	// Name is the name of a credential of credentials.sources, empty for
+	// the rows of the credential table.
+	Name string `gorm:"-" json:"-"`
 }
 
 func (*HuaweiCredential) TableName() string {
 	return "tbl_huawei_credentials"
 }
 
+func (c *HuaweiCredential) GetID() int64 {
+	return c.ID
+}
+
+func (c *HuaweiCredential) GetName() string {
+	return c.Name
+}
+
+func (c *HuaweiCredential) GetUsername() string {
+	return c.Username
+}
+
+func (c *HuaweiCredential) GetOwner() string {
+	return c.Owner
+}
+
+// SecretFields returns the columns encrypted at rest.
+func (c *HuaweiCredential) SecretFields() []*string {
+	return []*string{&c.Password}
+}
+
+func (c HuaweiCredential) MarshalJSON() ([]byte, error) {
+	type alias HuaweiCredential
+	c.Password = redact(c.Password)
+	return json.Marshal(alias(c))
+}
+
 type KstarCredential struct {
 	ID        int64      `gorm:"column:id" json:"id"`
 	Username  string     `gorm:"column:username" json:"username"`
@@ -25,12 +89,43 @@ type KstarCredential struct {
 	Owner     string     `gorm:"column:owner" json:"owner"`
 	CreatedAt *time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt *time.Time `gorm:"column:updated_at" json:"updated_at"`
+
+	// Name is the name of a credential of credentials.sources, empty for
+	// the rows of the credential table.
+	Name string `gorm:"-" json:"-"`
 }
 
 func (*KstarCredential) TableName() string {
 	return "tbl_kstar_credentials"
 }
 
+func (c *KstarCredential) GetID() int64 {
+	return c.ID
+}
+
+func (c *KstarCredential) GetName() string {
+	return c.Name
+}
+
+func (c *KstarCredential) GetUsername() string {
+	return c.Username
+}
+
+func (c *KstarCredential) GetOwner() string {
+	return c.Owner
+}
+
+// SecretFields returns the columns encrypted at rest.
+func (c *KstarCredential) SecretFields() []*string {
+	return []*string{&c.Password}
+}
+
+func (c KstarCredential) MarshalJSON() ([]byte, error) {
+	type alias KstarCredential
+	c.Password = redact(c.Password)
+	return json.Marshal(alias(c))
+}
+
 type GrowattCredential struct {
 	ID        int64      `gorm:"column:id" json:"id"`
 	Username  string     `gorm:"column:username" json:"username"`
@@ -39,12 +134,44 @@ type GrowattCredential struct {
 	Owner     string     `gorm:"column:owner" json:"owner"`
 	CreatedAt *time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt *time.Time `gorm:"column:updated_at" json:"updated_at"`
+
+	// Name is the name of a credential of credentials.sources, empty for
+	// the rows of the credential table.
+	Name string `gorm:"-" json:"-"`
 }
 
 func (*GrowattCredential) TableName() string {
 	return "tbl_growatt_credentials"
 }
 
+func (c *GrowattCredential) GetID() int64 {
+	return c.ID
+}
+
+func (c *GrowattCredential) GetName() string {
+	return c.Name
+}
+
+func (c *GrowattCredential) GetUsername() string {
+	return c.Username
+}
+
+func (c *GrowattCredential) GetOwner() string {
+	return c.Owner
+}
+
+// SecretFields returns the columns encrypted at rest.
+func (c *GrowattCredential) SecretFields() []*string {
+	return []*string{&c.Password, &c.Token}
+}
+
+func (c GrowattCredential) MarshalJSON() ([]byte, error) {
+	type alias GrowattCredential
+	c.Password = redact(c.Password)
+	c.Token = redact(c.Token)
+	return json.Marshal(alias(c))
+}
+
 type SolarmanCredential struct {
 	ID        int64      `gorm:"column:id" json:"id"`
 	Username  string     `gorm:"column:username" json:"username"`
@@ -54,8 +181,40 @@ type SolarmanCredential struct {
 	Owner     string     `gorm:"column:owner" json:"owner"`
 	CreatedAt *time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt *time.Time `gorm:"column:updated_at" json:"updated_at"`
+
+	// Name is the name of a credential of credentials.sources, empty for
+	// the rows of the credential table.
+	Name string `gorm:"-" json:"-"`
 }
 
 func (*SolarmanCredential) TableName() string {
 	return "tbl_solarman_credentials"
 }
+
+func (c *SolarmanCredential) GetID() int64 {
+	return c.ID
+}
+
+func (c *SolarmanCredential) GetName() string {
+	return c.Name
+}
+
+func (c *SolarmanCredential) GetUsername() string {
+	return c.Username
+}
+
+func (c *SolarmanCredential) GetOwner() string {
+	return c.Owner
+}
+
+// SecretFields returns the columns encrypted at rest.
+func (c *SolarmanCredential) SecretFields() []*string {
+	return []*string{&c.Password, &c.AppSecret}
+}
+
+func (c SolarmanCredential) MarshalJSON() ([]byte, error) {
+	type alias SolarmanCredential
+	c.Password = redact(c.Password)
+	c.AppSecret = redact(c.AppSecret)
+	return json.Marshal(alias(c))
+}
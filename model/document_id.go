@@ -0,0 +1,90 @@
+package model
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.openly.dev/pointy"
+)
+
+// Identifiable is implemented by documents that carry their own Elasticsearch
+// _id. Indexing the same document twice overwrites it instead of adding a
+// duplicate.
+type Identifiable interface {
+	DocumentID() string
+}
+
+// SlotLocation is the time zone of the collection slots, the one of the
+// plants. It does not follow time.Local, so every command derives the same
+// document IDs whether or not it switched to Asia/Bangkok.
+var SlotLocation = loadSlotLocation()
+
+// loadSlotLocation falls back to a fixed UTC+7 when the system has no zone
+// database, Thailand has no daylight saving time.
+func loadSlotLocation() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Bangkok"); err == nil {
+		return loc
+	}
+
+	return time.FixedZone("ICT", 7*60*60)
+}
+
+// CollectionSlot is the period a collected document belongs to. Collectors
+// run once a day and write to daily indices, so the slot is the date of the
+// document in SlotLocation, the date the solarcell index is named after.
+func CollectionSlot(t time.Time) string {
+	return t.In(SlotLocation).Format("2006-01-02")
+}
+
+// DocumentID returns the plant ID of the collection slot, or an empty string
+// when the plant has no ID and Elasticsearch should generate one.
+func (p PlantItem) DocumentID() string {
+	id := pointy.StringValue(p.ID, "")
+	if id == "" {
+		return ""
+	}
+
+	return documentID(p.VendorType, p.DataType, id, CollectionSlot(p.Timestamp))
+}
+
+// DocumentID returns the device ID of the collection slot. Devices without an
+// ID are identified by serial number.
+func (d DeviceItem) DocumentID() string {
+	id := pointy.StringValue(d.ID, pointy.StringValue(d.SN, ""))
+	if id == "" {
+		return ""
+	}
+
+	return documentID(d.VendorType, d.DataType, pointy.StringValue(d.PlantID, ""), id, CollectionSlot(d.Timestamp))
+}
+
+// DocumentID returns the alarm ID of the collection slot. A device can raise
+// several alarms at once and some vendors do not send an alarm code, so the
+// message and the alarm time are part of the ID.
+func (a AlarmItem) DocumentID() string {
+	device := pointy.StringValue(a.DeviceID, pointy.StringValue(a.DeviceSN, ""))
+	plant := pointy.StringValue(a.PlantID, "")
+	if device == "" && plant == "" {
+		return ""
+	}
+
+	alarmTime := ""
+	if a.AlarmTime != nil {
+		alarmTime = a.AlarmTime.UTC().Format(time.RFC3339)
+	}
+
+	return documentID(
+		a.VendorType, a.DataType, plant, device,
+		pointy.StringValue(a.ID, ""), pointy.StringValue(a.Message, ""), alarmTime,
+		CollectionSlot(a.Timestamp),
+	)
+}
+
+// documentID hashes the parts, so IDs have a fixed length whatever vendors
+// put in names and messages.
+func documentID(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"go.openly.dev/pointy"
+)
+
+func TestCollectionSlotIgnoresLocal(t *testing.T) {
+	local := time.Local
+	t.Cleanup(func() { time.Local = local })
+
+	// 20:30 UTC is already the next day in Bangkok.
+	collected := time.Date(2024, 1, 14, 20, 30, 0, 0, time.UTC)
+	plant := PlantItem{Timestamp: collected, VendorType: VendorTypeGrowatt, DataType: DataTypePlant, ID: pointy.String("1001")}
+
+	ids := make(map[string]string)
+	for _, zone := range []*time.Location{time.UTC, SlotLocation, time.FixedZone("EST", -5*60*60)} {
+		time.Local = zone
+		if got := CollectionSlot(collected); got != "2024-01-15" {
+			t.Errorf("CollectionSlot() with time.Local %s = %s, want 2024-01-15", zone, got)
+		}
+
+		ids[zone.String()] = plant.DocumentID()
+	}
+
+	if ids["UTC"] != ids["EST"] || ids["UTC"] != ids[SlotLocation.String()] {
+		t.Errorf("DocumentID() differs by time.Local: %v", ids)
+	}
+}
@@ -7,6 +7,7 @@ const (
 	SiteStationIndex      = "site-station"
 	AlarmIndex            = "alarm"
 	PerformanceAlarmIndex = "performance-alarm"
+	DeadLetterIndex       = "dead-letter"
 )
 
 const (
@@ -28,6 +29,10 @@ const (
 	VendorTypeSolarman = "solarman"
 )
 
+// ModuleHuawei2 is the name of the module collecting Huawei plants through
+// the version 2 FusionSolar API. Its documents keep VendorTypeHuawei.
+const ModuleHuawei2 = "huawei2"
+
 type PlantItem struct {
 	Timestamp         time.Time  `json:"@timestamp"`
 	Month             string     `json:"month"`
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+const (
+	JobRunStatusRunning = "running"
+	JobRunStatusSuccess = "success"
+	JobRunStatusFailed  = "failed"
+)
+
+// JobRun is one entry of the job run ledger. Job-level runs have no
+// credential; per-credential runs of collectors and alarms carry the
+// CredentialID of a credential row or the CredentialName of a named one.
+type JobRun struct {
+	ID               int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	JobName          string     `gorm:"column:job_name;index" json:"job_name"`
+	Vendor           string     `gorm:"column:vendor;index" json:"vendor"`
+	CredentialID     *int64     `gorm:"column:credential_id" json:"credential_id"`
+	CredentialName   string     `gorm:"column:credential_name" json:"credential_name"`
+	StartedAt        time.Time  `gorm:"column:started_at;index" json:"started_at"`
+	FinishedAt       *time.Time `gorm:"column:finished_at" json:"finished_at"`
+	Status           string     `gorm:"column:status;index" json:"status"`
+	Error            string     `gorm:"column:error" json:"error"`
+	DocumentsIndexed int64      `gorm:"column:documents_indexed" json:"documents_indexed"`
+	SitesUpserted    int64      `gorm:"column:sites_upserted" json:"sites_upserted"`
+}
+
+func (*JobRun) TableName() string {
+	return "tbl_job_runs"
+}
+
+// Duration returns how long the run took, or zero while it is still running.
+func (r *JobRun) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return 0
+	}
+
+	return r.FinishedAt.Sub(r.StartedAt)
+}
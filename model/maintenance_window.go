@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// MaintenanceWindow silences the raise traps of the alarms in its scope from
+// StartAt until EndAt. Vendor, SiteID, Plant, DeviceSN and Area narrow the
+// scope, an empty one matches everything and a window sets at least one.
+type MaintenanceWindow struct {
+	ID        int64      `gorm:"column:id" json:"id"`
+	Vendor    string     `gorm:"column:vendor" json:"vendor"`
+	SiteID    string     `gorm:"column:site_id" json:"site_id"`
+	Plant     string     `gorm:"column:plant" json:"plant"`
+	DeviceSN  string     `gorm:"column:device_sn" json:"device_sn"`
+	Area      string     `gorm:"column:area" json:"area"`
+	StartAt   time.Time  `gorm:"column:start_at" json:"start_at"`
+	EndAt     time.Time  `gorm:"column:end_at" json:"end_at"`
+	Reason    string     `gorm:"column:reason" json:"reason"`
+	CreatedAt *time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (*MaintenanceWindow) TableName() string {
+	return "tbl_maintenance_windows"
+}
+
+// Active tells whether the window is open at t.
+func (w MaintenanceWindow) Active(t time.Time) bool {
+	return !t.Before(w.StartAt) && t.Before(w.EndAt)
+}
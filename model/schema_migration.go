@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// SchemaMigration records one applied migration of the relational store.
+type SchemaMigration struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"column:name" json:"name"`
+	AppliedAt time.Time `gorm:"column:applied_at" json:"applied_at"`
+}
+
+func (*SchemaMigration) TableName() string {
+	return "tbl_schema_migrations"
+}
@@ -12,6 +12,15 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// consoleOutput is where the console writers of the loggers write.
+var consoleOutput io.Writer = os.Stdout
+
+// SetConsoleOutput sends the console logs of the loggers created afterwards
+// to w instead of stdout. Call it before Init and before creating clients.
+func SetConsoleOutput(w io.Writer) {
+	consoleOutput = w
+}
+
 func Init(file string) {
 	writers := io.MultiWriter(
 		NewConsoleWriter(),
@@ -31,7 +40,7 @@ func NewWriter(file string) io.Writer {
 }
 
 func NewConsoleWriter() io.Writer {
-	return zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	return zerolog.ConsoleWriter{Out: consoleOutput, TimeFormat: time.RFC3339}
 }
 
 func NewLumberjack(file string) io.Writer {
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "true_solar"
+
+// Kinds of collected items.
+const (
+	KindPlant  = "plant"
+	KindDevice = "device"
+	KindAlarm  = "alarm"
+)
+
+// Results of spooled documents.
+const (
+	SpoolResultSpooled  = "spooled"
+	SpoolResultReplayed = "replayed"
+	SpoolResultDropped  = "dropped"
+)
+
+// Results of SNMP traps.
+const (
+	TrapResultSent   = "sent"
+	TrapResultFailed = "failed"
+)
+
+var (
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "vendor_api_requests_total",
+		Help:      "Vendor API requests by vendor, endpoint and HTTP status.",
+	}, []string{"vendor", "endpoint", "status"})
+
+	apiDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "vendor_api_request_duration_seconds",
+		Help:      "Vendor API request latency by vendor and endpoint.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"vendor", "endpoint"})
+
+	collectedItems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "collected_items",
+		Help:      "Plants, devices and alarms indexed by the last collect of a credential.",
+	}, []string{"vendor", "credential_id", "kind"})
+
+	bulkIndexFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bulk_index_failures_total",
+		Help:      "BulkIndex calls that failed after all retries.",
+	}, []string{"index"})
+
+	bulkIndexDeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bulk_index_dead_letters_total",
+		Help:      "Documents rejected for good by a bulk request and sent to the dead-letter index.",
+	}, []string{"index"})
+
+	bulkIndexRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bulk_index_retries_total",
+		Help:      "BulkIndex attempts retried after a connection error or rejected items.",
+	}, []string{"index"})
+
+	spoolSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "spool_size_bytes",
+		Help:      "Size of the spool of documents waiting to be indexed.",
+	})
+
+	spoolDocuments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spool_documents_total",
+		Help:      "Documents written to, replayed from or dropped by the spool, by index and result.",
+	}, []string{"index", "result"})
+
+	snmpTraps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "snmp_traps_total",
+		Help:      "SNMP traps by trap type and result.",
+	}, []string{"trap_type", "result"})
+
+	jobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run of a job.",
+	}, []string{"job"})
+)
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveAPIResponse returns a req response hook recording the request count
+// and latency of a vendor API client.
+func ObserveAPIResponse(vendor string) req.ResponseMiddleware {
+	return func(_ *req.Client, resp *req.Response) error {
+		endpoint := ""
+		if resp.Request != nil {
+			endpoint = endpointOf(resp.Request.RawURL)
+		}
+
+		status := "error"
+		if resp.Response != nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+
+		apiRequests.WithLabelValues(vendor, endpoint, status).Inc()
+		apiDuration.WithLabelValues(vendor, endpoint).Observe(resp.TotalTime().Seconds())
+		return nil
+	}
+}
+
+// endpointOf keeps the URL path only, so query strings do not blow up the
+// label cardinality.
+func endpointOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "unknown"
+	}
+
+	return u.Path
+}
+
+// SetCollectedItems records the items indexed by the last collect of a
+// credential, identified by model.CredentialKey.
+func SetCollectedItems(vendor, credential, kind string, count int64) {
+	collectedItems.WithLabelValues(vendor, credential, kind).Set(float64(count))
+}
+
+// datedIndexSuffix matches the daily suffix of indices like solarcell-2024.01.31.
+var datedIndexSuffix = regexp.MustCompile(`-\d{4}\.\d{2}\.\d{2}$`)
+
+// indexLabel drops the daily suffix so each index family is one series.
+func indexLabel(index string) string {
+	return datedIndexSuffix.ReplaceAllString(index, "")
+}
+
+func IncBulkIndexFailure(index string) {
+	bulkIndexFailures.WithLabelValues(indexLabel(index)).Inc()
+}
+
+func AddBulkIndexDeadLetters(index string, count int) {
+	bulkIndexDeadLetters.WithLabelValues(indexLabel(index)).Add(float64(count))
+}
+
+func IncBulkIndexRetry(index string) {
+	bulkIndexRetries.WithLabelValues(indexLabel(index)).Inc()
+}
+
+func SetSpoolSize(bytes int64) {
+	spoolSize.Set(float64(bytes))
+}
+
+func AddSpoolDocuments(index, result string, count int) {
+	spoolDocuments.WithLabelValues(indexLabel(index), result).Add(float64(count))
+}
+
+func IncSnmpTrap(trapType, result string) {
+	snmpTraps.WithLabelValues(trapType, result).Inc()
+}
+
+func SetJobLastSuccess(job string, at time.Time) {
+	jobLastSuccess.WithLabelValues(job).Set(float64(at.Unix()))
+}
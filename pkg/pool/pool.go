@@ -0,0 +1,70 @@
+package pool
+
+import (
+	"github.com/sourcegraph/conc"
+	"github.com/sourcegraph/conc/panics"
+)
+
+// Pool bounds how many tasks run at the same time. A single pool can be
+// shared by several jobs; each job tracks its own tasks through a Group.
+type Pool struct {
+	slots chan struct{}
+}
+
+func New(size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Pool{
+		slots: make(chan struct{}, size),
+	}
+}
+
+// Size returns the maximum number of tasks running at once.
+func (p *Pool) Size() int {
+	return cap(p.slots)
+}
+
+// NewGroup returns a group whose tasks run on the pool.
+func (p *Pool) NewGroup() *Group {
+	return &Group{
+		pool: p,
+		wg:   conc.NewWaitGroup(),
+	}
+}
+
+func (p *Pool) acquire() {
+	p.slots <- struct{}{}
+}
+
+func (p *Pool) release() {
+	<-p.slots
+}
+
+// Group is the set of tasks submitted by one job. Tasks wait for a free slot
+// of the pool before running, so groups sharing a pool never exceed its size.
+type Group struct {
+	pool *Pool
+	wg   *conc.WaitGroup
+}
+
+// Go submits fn to the pool without blocking the caller.
+func (g *Group) Go(fn func()) {
+	g.wg.Go(func() {
+		g.pool.acquire()
+		defer g.pool.release()
+		fn()
+	})
+}
+
+// Wait blocks until every task of the group has finished, propagating panics.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
+
+// WaitAndRecover blocks until every task of the group has finished and
+// returns the first recovered panic, if any.
+func (g *Group) WaitAndRecover() *panics.Recovered {
+	return g.wg.WaitAndRecover()
+}
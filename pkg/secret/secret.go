@@ -0,0 +1,188 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks an encrypted value. Values without it are plaintext rows that
+// have not been migrated yet.
+const Prefix = "enc:v1:"
+
+const keySize = 32
+
+var (
+	ErrMissingKey   = errors.New("secret: value is encrypted but no key is configured")
+	ErrInvalidValue = errors.New("secret: malformed encrypted value")
+)
+
+// Cipher implements envelope encryption: every value is sealed with its own
+// random data key, and the data key is sealed with the master key. A nil
+// Cipher leaves plaintext untouched and refuses to decrypt.
+type Cipher struct {
+	master cipher.AEAD
+}
+
+// NewCipher builds a cipher from a 32-byte master key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("secret: master key must be %d bytes, got %d", keySize, len(key))
+	}
+
+	master, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{master: master}, nil
+}
+
+// NewCipherFromKey builds a cipher from a master key encoded as base64 or
+// hex. An empty key returns a nil Cipher, which stores values as is.
+func NewCipherFromKey(encoded string) (*Cipher, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := ParseKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCipher(key)
+}
+
+// ParseKey decodes a master key given as base64 or hex.
+func ParseKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == keySize {
+		return key, nil
+	}
+
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == keySize {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("secret: master key must be %d bytes encoded as base64 or hex", keySize)
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encrypt seals plaintext. Empty values are returned unchanged, and so are
+// encrypted values once they open with c, so Encrypt is safe to apply twice
+// but never stores a prefixed value that cannot be decrypted.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if IsEncrypted(plaintext) {
+		if _, err := c.Decrypt(plaintext); err != nil {
+			return "", err
+		}
+
+		return plaintext, nil
+	}
+
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealedKey, err := seal(c.master, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealedData, err := seal(data, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + base64.RawURLEncoding.EncodeToString(sealedKey) + ":" + base64.RawURLEncoding.EncodeToString(sealedData), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Plaintext values are returned
+// unchanged so rows written before encryption keep working.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	if c == nil {
+		return "", ErrMissingKey
+	}
+
+	encodedKey, encodedData, ok := strings.Cut(strings.TrimPrefix(value, Prefix), ":")
+	if !ok {
+		return "", ErrInvalidValue
+	}
+
+	sealedKey, err := base64.RawURLEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return "", ErrInvalidValue
+	}
+
+	sealedData, err := base64.RawURLEncoding.DecodeString(encodedData)
+	if err != nil {
+		return "", ErrInvalidValue
+	}
+
+	dataKey, err := open(c.master, sealedKey)
+	if err != nil {
+		return "", fmt.Errorf("secret: failed to unwrap data key: %w", err)
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(data, sealedData)
+	if err != nil {
+		return "", fmt.Errorf("secret: failed to decrypt value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidValue
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,53 @@
+package secret
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptVerifiesEncryptedValues(t *testing.T) {
+	first, err := NewCipherFromKey(strings.Repeat("11", 32))
+	if err != nil {
+		t.Fatalf("NewCipherFromKey() error = %v", err)
+	}
+
+	second, err := NewCipherFromKey(strings.Repeat("22", 32))
+	if err != nil {
+		t.Fatalf("NewCipherFromKey() error = %v", err)
+	}
+
+	encrypted, err := first.Encrypt("p4ss")
+	if err != nil || !IsEncrypted(encrypted) {
+		t.Fatalf("Encrypt() = %q, %v, want an encrypted value", encrypted, err)
+	}
+
+	// Encrypting twice with the key keeps the value.
+	if again, err := first.Encrypt(encrypted); err != nil || again != encrypted {
+		t.Errorf("Encrypt() of an encrypted value = %q, %v, want it unchanged", again, err)
+	}
+
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		value  string
+		want   error
+	}{
+		{name: "other key", cipher: second, value: encrypted},
+		{name: "no key", cipher: nil, value: encrypted, want: ErrMissingKey},
+		{name: "malformed", cipher: first, value: Prefix + "garbage", want: ErrInvalidValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cipher.Encrypt(tt.value)
+			if err == nil {
+				t.Fatalf("Encrypt() = %q, want an error", got)
+			}
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Encrypt() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
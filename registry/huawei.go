@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/collector"
+	"github.com/HavvokLab/true-solar/healthcheck"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/HavvokLab/true-solar/troubleshoot"
+	"gorm.io/gorm"
+)
+
+// Huawei credentials share one table; the version column selects which
+// FusionSolar API (and therefore which module) handles the account.
+const (
+	HuaweiSupportedVersion  = 1
+	Huawei2SupportedVersion = 2
+)
+
+func init() {
+	Register(Module{
+		Name:        model.VendorTypeHuawei,
+		TrapType:    infra.TrapTypeHuaweiAlarm,
+		Credentials: huaweiCredentialsByVersion(HuaweiSupportedVersion),
+		NewCollector: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) collector.Collector {
+			return collector.NewHuaweiCollector(solarRepo, siteRegionRepo)
+		},
+		NewAlarm: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *alarm.StateStore) alarm.Handler {
+			return alarm.NewHuaweiAlarm(solarRepo, siteRegionRepo, severityRepo, maintenanceRepo, snmp, state)
+		},
+		NewTroubleshooter: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) troubleshoot.Troubleshooter {
+			return troubleshoot.NewHuaweiTroubleshoot(solarRepo, siteRegionRepo)
+		},
+		NewHealthChecker: healthcheck.NewHuaweiChecker,
+	})
+
+	Register(Module{
+		Name:        model.ModuleHuawei2,
+		Vendor:      model.VendorTypeHuawei,
+		TrapType:    infra.TrapTypeHuaweiAlarm,
+		Credentials: huaweiCredentialsByVersion(Huawei2SupportedVersion),
+		NewCollector: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) collector.Collector {
+			return collector.NewHuawei2Collector(solarRepo, siteRegionRepo)
+		},
+		NewHealthChecker: healthcheck.NewHuawei2Checker,
+	})
+}
+
+func huaweiCredentialsByVersion(version int) func(db *gorm.DB, cipher *secret.Cipher) ([]model.Credential, error) {
+	return func(db *gorm.DB, cipher *secret.Cipher) ([]model.Credential, error) {
+		credentials, err := repo.NewHuaweiCredentialRepo(db, cipher).FindAll()
+		if err != nil {
+			return nil, err
+		}
+
+		filtered := make([]model.HuaweiCredential, 0, len(credentials))
+		for _, credential := range credentials {
+			if credential.Version == version {
+				filtered = append(filtered, credential)
+			}
+		}
+
+		return toCredentials(filtered), nil
+	}
+}
@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"sync"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/pkg/pool"
+)
+
+var (
+	pools   = make(map[string]*pool.Pool)
+	poolsMu sync.Mutex
+)
+
+// Pool returns the worker pool of the module's vendor. The pool is created
+// on first use, sized from conf, and shared by every collect, alarm and
+// troubleshoot job of that vendor in the process.
+func (m Module) Pool(conf config.ConcurrencyConfig) *pool.Pool {
+	vendor := m.vendor()
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	if p, ok := pools[vendor]; ok {
+		return p
+	}
+
+	p := pool.New(conf.Limit(vendor))
+	pools[vendor] = p
+	return p
+}
+
+func (m Module) vendor() string {
+	if m.Vendor != "" {
+		return m.Vendor
+	}
+
+	return m.Name
+}
@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/collector"
+	"github.com/HavvokLab/true-solar/healthcheck"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/HavvokLab/true-solar/troubleshoot"
+	"gorm.io/gorm"
+)
+
+// Module binds credential loading, collector, alarm handler, troubleshooter
+// and health checker of a single vendor together. A nil factory means the
+// vendor does not support that kind of job.
+type Module struct {
+	// Name identifies the vendor in job names, log files and command flags.
+	Name string
+	// Aliases are alternative names accepted by Lookup.
+	Aliases []string
+	// Vendor is the API vendor whose concurrency limit and worker pool the
+	// module uses. It defaults to Name.
+	Vendor string
+	// TrapType is the SNMP trap type used by the alarm handler.
+	TrapType infra.TrapType
+
+	Credentials       func(db *gorm.DB, cipher *secret.Cipher) ([]model.Credential, error)
+	NewCollector      func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) collector.Collector
+	NewAlarm          func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *alarm.StateStore) alarm.Handler
+	NewTroubleshooter func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) troubleshoot.Troubleshooter
+	NewHealthChecker  func() healthcheck.Checker
+}
+
+func (m Module) HasCollector() bool {
+	return m.NewCollector != nil
+}
+
+func (m Module) HasAlarm() bool {
+	return m.NewAlarm != nil
+}
+
+func (m Module) HasTroubleshooter() bool {
+	return m.NewTroubleshooter != nil
+}
+
+func (m Module) HasHealthChecker() bool {
+	return m.NewHealthChecker != nil
+}
+
+var modules []Module
+
+// Register adds a vendor module to the registry. It panics when the name or
+// one of the aliases is already taken, since that is a programming error.
+func Register(module Module) {
+	if module.Name == "" {
+		panic("registry: module name is required")
+	}
+
+	if module.Credentials == nil {
+		panic(fmt.Sprintf("registry: module %s has no credential loader", module.Name))
+	}
+
+	for _, name := range append([]string{module.Name}, module.Aliases...) {
+		if _, found := Lookup(name); found {
+			panic(fmt.Sprintf("registry: vendor %s already registered", name))
+		}
+	}
+
+	modules = append(modules, module)
+}
+
+// Modules returns every registered module in registration order.
+func Modules() []Module {
+	result := make([]Module, len(modules))
+	copy(result, modules)
+	return result
+}
+
+// Lookup finds a module by name or alias, case-insensitively.
+func Lookup(name string) (Module, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, module := range modules {
+		if module.Name == name {
+			return module, true
+		}
+
+		for _, alias := range module.Aliases {
+			if alias == name {
+				return module, true
+			}
+		}
+	}
+
+	return Module{}, false
+}
+
+// Names returns the names of every registered module.
+func Names() []string {
+	names := make([]string, 0, len(modules))
+	for _, module := range modules {
+		names = append(names, module.Name)
+	}
+
+	return names
+}
+
+func toCredentials[T any, PT interface {
+	*T
+	model.Credential
+}](items []T) []model.Credential {
+	credentials := make([]model.Credential, 0, len(items))
+	for i := range items {
+		credentials = append(credentials, PT(&items[i]))
+	}
+
+	return credentials
+}
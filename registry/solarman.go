@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"github.com/HavvokLab/true-solar/alarm"
+	"github.com/HavvokLab/true-solar/collector"
+	"github.com/HavvokLab/true-solar/healthcheck"
+	"github.com/HavvokLab/true-solar/infra"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/HavvokLab/true-solar/troubleshoot"
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Module{
+		Name:     model.VendorTypeSolarman,
+		Aliases:  []string{model.VendorTypeInvt},
+		TrapType: infra.TrapTypeSolarmanAlarm,
+		Credentials: func(db *gorm.DB, cipher *secret.Cipher) ([]model.Credential, error) {
+			credentials, err := repo.NewSolarmanCredentialRepo(db, cipher).FindAll()
+			if err != nil {
+				return nil, err
+			}
+
+			return toCredentials(credentials), nil
+		},
+		NewCollector: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) collector.Collector {
+			return collector.NewSolarmanCollector(solarRepo, siteRegionRepo)
+		},
+		NewAlarm: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo, severityRepo repo.AlarmSeverityMappingRepo, maintenanceRepo repo.MaintenanceWindowRepo, snmp *infra.SnmpOrchestrator, state *alarm.StateStore) alarm.Handler {
+			return alarm.NewSolarmanAlarm(solarRepo, siteRegionRepo, severityRepo, maintenanceRepo, snmp, state)
+		},
+		NewTroubleshooter: func(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegionMappingRepo) troubleshoot.Troubleshooter {
+			return troubleshoot.NewSolarmanTroubleshoot(solarRepo, siteRegionRepo)
+		},
+		NewHealthChecker: healthcheck.NewSolarmanChecker,
+	})
+}
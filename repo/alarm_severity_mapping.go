@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"github.com/HavvokLab/true-solar/model"
+	"gorm.io/gorm"
+)
+
+type AlarmSeverityMappingRepo interface {
+	FindAll() ([]model.AlarmSeverityMapping, error)
+	FindByID(id int64) (*model.AlarmSeverityMapping, error)
+	Create(data *model.AlarmSeverityMapping) error
+	Update(id int64, data *model.AlarmSeverityMapping) error
+	Delete(id int64) error
+}
+
+type alarmSeverityMappingRepo struct {
+	db *gorm.DB
+}
+
+func NewAlarmSeverityMappingRepo(db *gorm.DB) AlarmSeverityMappingRepo {
+	return &alarmSeverityMappingRepo{
+		db: db,
+	}
+}
+
+func (r *alarmSeverityMappingRepo) FindAll() ([]model.AlarmSeverityMapping, error) {
+	tx := r.db.Session(&gorm.Session{})
+	var mappings []model.AlarmSeverityMapping
+	if err := tx.Order("id").Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+func (r *alarmSeverityMappingRepo) FindByID(id int64) (*model.AlarmSeverityMapping, error) {
+	tx := r.db.Session(&gorm.Session{})
+	var mapping model.AlarmSeverityMapping
+	if err := tx.First(&mapping, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+func (r *alarmSeverityMappingRepo) Create(data *model.AlarmSeverityMapping) error {
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Create(data).Error
+}
+
+// Update replaces the mapping with the given id, a nil level included.
+func (r *alarmSeverityMappingRepo) Update(id int64, data *model.AlarmSeverityMapping) error {
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Model(&model.AlarmSeverityMapping{}).Where("id = ?", id).
+		Select("vendor", "code", "level", "severity", "updated_at").
+		Updates(data).Error
+}
+
+func (r *alarmSeverityMappingRepo) Delete(id int64) error {
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Delete(&model.AlarmSeverityMapping{}, id).Error
+}
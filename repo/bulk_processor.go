@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+)
+
+// BulkProcessor indexes documents into one index while they are added, so a
+// collector does not keep a whole day of documents in memory. Full batches
+// go to BulkIndex of the wrapped SolarRepo in the background, decorators like
+// counting and spooling see every batch. Add blocks while Concurrency batches
+// are handed over, which bounds the documents held in memory; the requests
+// in flight are bounded by the bulk pool of the repo. Batches are bounded by
+// the BulkConfig of the repo.
+type BulkProcessor struct {
+	solarRepo SolarRepo
+	index     string
+	conf      config.BulkConfig
+	slots     chan struct{}
+	wg        sync.WaitGroup
+
+	mu    sync.Mutex
+	batch []interface{}
+	bytes int
+
+	resultMu sync.Mutex
+	result   model.BulkResult
+	errs     []error
+}
+
+func NewBulkProcessor(solarRepo SolarRepo, index string) *BulkProcessor {
+	conf := solarRepo.BulkConfig().WithDefaults()
+	return &BulkProcessor{
+		solarRepo: solarRepo,
+		index:     index,
+		conf:      conf,
+		slots:     make(chan struct{}, conf.Concurrency),
+		batch:     make([]interface{}, 0, conf.Actions),
+	}
+}
+
+// Add queues doc and sends the batch once it holds Actions documents or
+// Bytes of payload. It fails only when doc cannot be marshaled, failures of
+// sent batches are returned by Close.
+func (p *BulkProcessor) Add(doc interface{}) error {
+	source, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("marshal document of index %s: %w", p.index, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.batch) > 0 && p.bytes+len(source) > p.conf.Bytes {
+		p.flush()
+	}
+
+	p.batch = append(p.batch, doc)
+	p.bytes += len(source)
+	if len(p.batch) >= p.conf.Actions {
+		p.flush()
+	}
+
+	return nil
+}
+
+// Flush sends the queued documents without waiting for them to be indexed.
+func (p *BulkProcessor) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flush()
+}
+
+// Close sends the queued documents, waits for every batch and returns the
+// summed result and the errors of the failed batches. The processor must not
+// be used afterwards.
+func (p *BulkProcessor) Close() (model.BulkResult, error) {
+	p.Flush()
+	p.wg.Wait()
+
+	p.resultMu.Lock()
+	defer p.resultMu.Unlock()
+	return p.result, errors.Join(p.errs...)
+}
+
+// flush hands the batch to a sender. The caller holds mu.
+func (p *BulkProcessor) flush() {
+	if len(p.batch) == 0 {
+		return
+	}
+
+	batch := p.batch
+	p.batch = make([]interface{}, 0, p.conf.Actions)
+	p.bytes = 0
+
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.slots
+			p.wg.Done()
+		}()
+
+		result, err := p.solarRepo.BulkIndex(p.index, batch)
+
+		p.resultMu.Lock()
+		defer p.resultMu.Unlock()
+		p.result.Add(result)
+		if err != nil {
+			p.errs = append(p.errs, err)
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package repo
+
+import "github.com/HavvokLab/true-solar/pkg/secret"
+
+// secretHolder is implemented by credential models with encrypted columns.
+type secretHolder interface {
+	SecretFields() []*string
+}
+
+// encryptSecrets encrypts the secret fields in place with c before a write.
+// The returned restore puts the plaintext back so callers keep a usable
+// model. A nil c leaves the fields as is.
+func encryptSecrets(c *secret.Cipher, holder secretHolder) (func(), error) {
+	fields := holder.SecretFields()
+	plaintexts := make([]string, len(fields))
+	restore := func() {
+		for i, field := range fields {
+			*field = plaintexts[i]
+		}
+	}
+
+	for i, field := range fields {
+		plaintexts[i] = *field
+		encrypted, err := c.Encrypt(*field)
+		if err != nil {
+			restore()
+			return func() {}, err
+		}
+
+		*field = encrypted
+	}
+
+	return restore, nil
+}
+
+// decryptSecrets decrypts the secret fields in place with c after a read.
+func decryptSecrets(c *secret.Cipher, holder secretHolder) error {
+	for _, field := range holder.SecretFields() {
+		plaintext, err := c.Decrypt(*field)
+		if err != nil {
+			return err
+		}
+
+		*field = plaintext
+	}
+
+	return nil
+}
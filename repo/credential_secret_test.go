@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCredentialRepoCipher(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	first, err := secret.NewCipherFromKey(strings.Repeat("11", 32))
+	if err != nil {
+		t.Fatalf("NewCipherFromKey() error = %v", err)
+	}
+
+	second, err := secret.NewCipherFromKey(strings.Repeat("22", 32))
+	if err != nil {
+		t.Fatalf("NewCipherFromKey() error = %v", err)
+	}
+
+	credential := &model.HuaweiCredential{Username: "user", Password: "p4ss", Version: 1}
+	if err := NewHuaweiCredentialRepo(db, first).Create(credential); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if credential.Password != "p4ss" {
+		t.Errorf("Create() left password %q, want the plaintext", credential.Password)
+	}
+
+	var stored model.HuaweiCredential
+	if err := db.First(&stored, credential.ID).Error; err != nil {
+		t.Fatalf("find credential: %v", err)
+	}
+
+	if !secret.IsEncrypted(stored.Password) {
+		t.Errorf("stored password = %q, want an encrypted value", stored.Password)
+	}
+
+	got, err := NewHuaweiCredentialRepo(db, first).FindByID(credential.ID)
+	if err != nil || got.Password != "p4ss" {
+		t.Fatalf("FindByID() = %+v, %v, want the plaintext password", got, err)
+	}
+
+	// Each repository uses the cipher it was built with.
+	if _, err := NewHuaweiCredentialRepo(db, second).FindByID(credential.ID); err == nil {
+		t.Error("FindByID() with another key opened the password")
+	}
+
+	if _, err := NewHuaweiCredentialRepo(db, nil).FindAll(); err == nil {
+		t.Error("FindAll() without a key opened the password")
+	}
+}
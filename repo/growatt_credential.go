@@ -2,22 +2,25 @@ package repo
 
 import (
 	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
 	"gorm.io/gorm"
 )
 
 type GrowattCredentialRepo interface {
 	FindAll() ([]model.GrowattCredential, error)
+	FindByID(id int64) (*model.GrowattCredential, error)
 	Create(credential *model.GrowattCredential) error
 	Update(id int64, credential *model.GrowattCredential) error
 	Delete(id int64) error
 }
 
 type growattCredentialRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	cipher *secret.Cipher
 }
 
-func NewGrowattCredentialRepo(db *gorm.DB) GrowattCredentialRepo {
-	return &growattCredentialRepo{db: db}
+func NewGrowattCredentialRepo(db *gorm.DB, cipher *secret.Cipher) GrowattCredentialRepo {
+	return &growattCredentialRepo{db: db, cipher: cipher}
 }
 
 func (r *growattCredentialRepo) FindAll() ([]model.GrowattCredential, error) {
@@ -27,10 +30,36 @@ func (r *growattCredentialRepo) FindAll() ([]model.GrowattCredential, error) {
 		return nil, err
 	}
 
+	for i := range credentials {
+		if err := decryptSecrets(r.cipher, &credentials[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	return credentials, nil
 }
 
+func (r *growattCredentialRepo) FindByID(id int64) (*model.GrowattCredential, error) {
+	var credential model.GrowattCredential
+	tx := r.db.Session(&gorm.Session{})
+	if err := tx.First(&credential, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := decryptSecrets(r.cipher, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
 func (r *growattCredentialRepo) Create(credential *model.GrowattCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
 	if err := tx.Create(credential).Error; err != nil {
 		return err
@@ -39,9 +68,18 @@ func (r *growattCredentialRepo) Create(credential *model.GrowattCredential) erro
 	return nil
 }
 
+// Update replaces the credential with the given id, emptied fields included.
 func (r *growattCredentialRepo) Update(id int64, credential *model.GrowattCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
-	if err := tx.Where("id = ?", id).Updates(credential).Error; err != nil {
+	if err := tx.Model(&model.GrowattCredential{}).Where("id = ?", id).
+		Select("username", "password", "token", "owner", "updated_at").
+		Updates(credential).Error; err != nil {
 		return err
 	}
 
@@ -2,22 +2,25 @@ package repo
 
 import (
 	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
 	"gorm.io/gorm"
 )
 
 type HuaweiCredentialRepo interface {
 	FindAll() ([]model.HuaweiCredential, error)
+	FindByID(id int64) (*model.HuaweiCredential, error)
 	Create(credential *model.HuaweiCredential) error
 	Update(id int64, credential *model.HuaweiCredential) error
 	Delete(id int64) error
 }
 
 type huaweiCredentialRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	cipher *secret.Cipher
 }
 
-func NewHuaweiCredentialRepo(db *gorm.DB) HuaweiCredentialRepo {
-	return &huaweiCredentialRepo{db: db}
+func NewHuaweiCredentialRepo(db *gorm.DB, cipher *secret.Cipher) HuaweiCredentialRepo {
+	return &huaweiCredentialRepo{db: db, cipher: cipher}
 }
 
 func (r *huaweiCredentialRepo) FindAll() ([]model.HuaweiCredential, error) {
@@ -27,10 +30,36 @@ func (r *huaweiCredentialRepo) FindAll() ([]model.HuaweiCredential, error) {
 		return nil, err
 	}
 
+	for i := range credentials {
+		if err := decryptSecrets(r.cipher, &credentials[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	return credentials, nil
 }
 
+func (r *huaweiCredentialRepo) FindByID(id int64) (*model.HuaweiCredential, error) {
+	var credential model.HuaweiCredential
+	tx := r.db.Session(&gorm.Session{})
+	if err := tx.First(&credential, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := decryptSecrets(r.cipher, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
 func (r *huaweiCredentialRepo) Create(credential *model.HuaweiCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
 	if err := tx.Create(credential).Error; err != nil {
 		return err
@@ -39,9 +68,18 @@ func (r *huaweiCredentialRepo) Create(credential *model.HuaweiCredential) error
 	return nil
 }
 
+// Update replaces the credential with the given id, emptied fields included.
 func (r *huaweiCredentialRepo) Update(id int64, credential *model.HuaweiCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
-	if err := tx.Where("id = ?", id).Updates(credential).Error; err != nil {
+	if err := tx.Model(&model.HuaweiCredential{}).Where("id = ?", id).
+		Select("username", "password", "owner", "version", "updated_at").
+		Updates(credential).Error; err != nil {
 		return err
 	}
 
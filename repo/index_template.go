@@ -0,0 +1,459 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/olivere/elastic/v7"
+)
+
+// IndexTemplateVersion is the version of the templates below. Bump it on every
+// change of a template, Install does not overwrite templates of a newer
+// version installed by a newer release.
+const IndexTemplateVersion = 1
+
+// indexTemplatePriority is above the priority of templates created by hand or
+// by Kibana for the same patterns.
+const indexTemplatePriority = 200
+
+// IndexTemplate describes the mapping of an index family. Policy is empty for
+// indices that are never rolled over or deleted.
+type IndexTemplate struct {
+	Name       string
+	Family     string
+	Pattern    string
+	Policy     string
+	Properties map[string]interface{}
+}
+
+// Every string field gets a keyword sub-field, the one dynamic mapping
+// creates, so queries and dashboards on "vendor_type.keyword" or "id.keyword"
+// keep working across indices created before and after the templates.
+func keywordField() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   "keyword",
+		"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword"}},
+	}
+}
+
+func textField() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   "text",
+		"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256}},
+	}
+}
+
+func typedField(fieldType string) map[string]interface{} {
+	return map[string]interface{}{"type": fieldType}
+}
+
+// geoPointField ignores malformed locations, a plant with coordinates out of
+// range must not fail the bulk request of its vendor.
+func geoPointField() map[string]interface{} {
+	return map[string]interface{}{"type": "geo_point", "ignore_malformed": true}
+}
+
+// collectedFields are the fields shared by PlantItem, DeviceItem and AlarmItem.
+func collectedFields() map[string]interface{} {
+	return map[string]interface{}{
+		"@timestamp":     typedField("date"),
+		"month":          keywordField(),
+		"year":           keywordField(),
+		"month_year":     keywordField(),
+		"vendor_type":    keywordField(),
+		"data_type":      keywordField(),
+		"area":           keywordField(),
+		"site_id":        keywordField(),
+		"site_city_name": keywordField(),
+		"site_city_code": keywordField(),
+		"node_type":      keywordField(),
+		"ac_phase":       typedField("integer"),
+		"id":             keywordField(),
+		"name":           textField(),
+		"lat":            typedField("double"),
+		"lng":            typedField("double"),
+		"location":       geoPointField(),
+		"owner":          keywordField(),
+	}
+}
+
+func solarcellProperties() map[string]interface{} {
+	properties := collectedFields()
+	for _, field := range []string{"plant_id", "sn", "device_id", "device_sn"} {
+		properties[field] = keywordField()
+	}
+
+	for _, field := range []string{"plant_name", "device_name", "location_address", "message"} {
+		properties[field] = textField()
+	}
+
+	for _, field := range []string{"currency", "plant_status", "device_type", "status", "device_status"} {
+		properties[field] = keywordField()
+	}
+
+	for _, field := range []string{"created_date", "last_update_time", "alarm_time"} {
+		properties[field] = typedField("date")
+	}
+
+	for _, field := range []string{
+		"installed_capacity", "total_co2", "monthly_co2", "total_saving_price", "current_power",
+		"total_production", "daily_production", "monthly_production", "yearly_production",
+		"total_power_generation", "daily_power_generation", "monthly_power_generation", "yearly_power_generation",
+	} {
+		properties[field] = typedField("float")
+	}
+
+	return properties
+}
+
+// snmpAlarmProperties covers SnmpAlarmItem and SnmpPerformanceAlarmItem.
+// lasted_update_time holds the vendor's own time format and stays a string.
+func snmpAlarmProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"@timestamp":         typedField("date"),
+		"vendor_type":        keywordField(),
+		"type":               keywordField(),
+		"device_name":        keywordField(),
+		"alert_name":         keywordField(),
+		"description":        textField(),
+		"severity":           keywordField(),
+		"lasted_update_time": keywordField(),
+	}
+}
+
+func siteStationProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"@timestamp":   typedField("date"),
+		"vendor_type":  keywordField(),
+		"area":         keywordField(),
+		"site_id":      keywordField(),
+		"node_type":    keywordField(),
+		"name":         textField(),
+		"location":     geoPointField(),
+		"plant_status": keywordField(),
+		"owner":        keywordField(),
+	}
+}
+
+// deadLetterProperties covers DeadLetterItem. The source is kept but not
+// indexed, it holds documents of any index.
+func deadLetterProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"@timestamp":  typedField("date"),
+		"index":       keywordField(),
+		"document_id": keywordField(),
+		"status":      typedField("integer"),
+		"error_type":  keywordField(),
+		"reason":      textField(),
+		"source":      map[string]interface{}{"type": "text", "index": false},
+	}
+}
+
+// IndexTemplates are the templates installed by the app. The daily indices
+// roll over by name, the collectors write to the index of the current day,
+// so the policies only move and delete indices by age. site-station is a
+// single upserted index and has no policy.
+var IndexTemplates = []IndexTemplate{
+	{
+		Name:       "true-solar-" + model.SolarIndex,
+		Family:     model.SolarIndex,
+		Pattern:    model.SolarIndex + "-*",
+		Policy:     "true-solar-" + model.SolarIndex,
+		Properties: solarcellProperties(),
+	},
+	{
+		Name:       "true-solar-" + model.AlarmIndex,
+		Family:     model.AlarmIndex,
+		Pattern:    model.AlarmIndex + "-*",
+		Policy:     "true-solar-" + model.AlarmIndex,
+		Properties: snmpAlarmProperties(),
+	},
+	{
+		Name:       "true-solar-" + model.PerformanceAlarmIndex,
+		Family:     model.PerformanceAlarmIndex,
+		Pattern:    model.PerformanceAlarmIndex + "-*",
+		Policy:     "true-solar-" + model.PerformanceAlarmIndex,
+		Properties: snmpAlarmProperties(),
+	},
+	{
+		Name:       "true-solar-" + model.SiteStationIndex,
+		Family:     model.SiteStationIndex,
+		Pattern:    model.SiteStationIndex,
+		Properties: siteStationProperties(),
+	},
+	{
+		Name:       "true-solar-" + model.DeadLetterIndex,
+		Family:     model.DeadLetterIndex,
+		Pattern:    model.DeadLetterIndex + "-*",
+		Policy:     "true-solar-" + model.DeadLetterIndex,
+		Properties: deadLetterProperties(),
+	},
+}
+
+// MappingMismatch is a field of a live index or template whose type differs
+// from the expected one. Actual is empty when the field is not mapped.
+type MappingMismatch struct {
+	Index    string
+	Field    string
+	Expected string
+	Actual   string
+}
+
+type IndexTemplateRepo interface {
+	Install(conf config.ElasticsearchConfig) error
+	CheckTemplates() ([]MappingMismatch, error)
+	CheckIndices() ([]MappingMismatch, error)
+}
+
+type indexTemplateRepo struct {
+	elastic *elastic.Client
+}
+
+func NewIndexTemplateRepo(elastic *elastic.Client) *indexTemplateRepo {
+	return &indexTemplateRepo{
+		elastic: elastic,
+	}
+}
+
+// |=> Implementation
+
+// Install puts the lifecycle policies and the templates. Templates apply to
+// indices created afterwards, existing indices keep their mapping. A template
+// whose policy cannot be put, e.g. on a cluster without ILM or a user without
+// the manage_ilm privilege, is put without it. Every template is tried and the
+// errors are joined.
+func (r *indexTemplateRepo) Install(conf config.ElasticsearchConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, template := range IndexTemplates {
+		if template.Policy != "" {
+			_, err := r.elastic.XPackIlmPutLifecycle().
+				Policy(template.Policy).
+				BodyJson(lifecyclePolicy(conf.IndexLifecycle(template.Family))).
+				Do(ctx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("put lifecycle policy %s: %w", template.Policy, err))
+				template.Policy = ""
+			}
+		}
+
+		if err := r.putTemplate(ctx, template); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// putTemplate puts template unless the live one has a newer version.
+func (r *indexTemplateRepo) putTemplate(ctx context.Context, template IndexTemplate) error {
+	version, err := r.liveVersion(ctx, template.Name)
+	if err != nil {
+		return err
+	}
+
+	if version > IndexTemplateVersion {
+		return nil
+	}
+
+	result, err := r.elastic.IndexPutIndexTemplate(template.Name).BodyJson(templateBody(template)).Do(ctx)
+	if err != nil {
+		return fmt.Errorf("put index template %s: %w", template.Name, err)
+	}
+
+	if !result.Acknowledged {
+		return fmt.Errorf("put index template %s: elasticsearch did not acknowledge", template.Name)
+	}
+
+	return nil
+}
+
+// CheckTemplates reports templates that are missing or older than
+// IndexTemplateVersion as a mismatch of their version.
+func (r *indexTemplateRepo) CheckTemplates() ([]MappingMismatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
+	defer cancel()
+
+	mismatches := make([]MappingMismatch, 0)
+	for _, template := range IndexTemplates {
+		version, err := r.liveVersion(ctx, template.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if version < IndexTemplateVersion {
+			actual := ""
+			if version > 0 {
+				actual = fmt.Sprint(version)
+			}
+
+			mismatches = append(mismatches, MappingMismatch{
+				Index:    template.Name,
+				Field:    "version",
+				Expected: fmt.Sprint(IndexTemplateVersion),
+				Actual:   actual,
+			})
+		}
+	}
+
+	return mismatches, nil
+}
+
+// CheckIndices compares the mapping of every live index of the templates with
+// the expected field types.
+func (r *indexTemplateRepo) CheckIndices() ([]MappingMismatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ScrollESTimeout)
+	defer cancel()
+
+	mismatches := make([]MappingMismatch, 0)
+	for _, template := range IndexTemplates {
+		response, err := r.elastic.IndexGet(template.Pattern).Feature("_mappings").AllowNoIndices(true).IgnoreUnavailable(true).Do(ctx)
+		if err != nil {
+			if elastic.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		indices := make([]string, 0, len(response))
+		for index := range response {
+			indices = append(indices, index)
+		}
+		sort.Strings(indices)
+
+		for _, index := range indices {
+			live := make(map[string]string)
+			if response[index] != nil {
+				flattenTypes("", response[index].Mappings["properties"], live)
+			}
+
+			expected := make(map[string]string)
+			flattenTypes("", template.Properties, expected)
+			for _, field := range sortedKeys(expected) {
+				// A field that is not mapped is reported once, not once
+				// per sub-field.
+				if parent, _, ok := strings.Cut(field, "."); ok && live[parent] == "" && expected[parent] != "" {
+					continue
+				}
+
+				if live[field] != expected[field] {
+					mismatches = append(mismatches, MappingMismatch{
+						Index:    index,
+						Field:    field,
+						Expected: expected[field],
+						Actual:   live[field],
+					})
+				}
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// liveVersion returns the version of an installed template, or 0 when it is
+// not installed.
+func (r *indexTemplateRepo) liveVersion(ctx context.Context, name string) (int, error) {
+	result, err := r.elastic.IndexGetIndexTemplate(name).Do(ctx)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("get index template %s: %w", name, err)
+	}
+
+	live, ok := result.IndexTemplates.ByName(name)
+	if !ok || live.IndexTemplate == nil {
+		return 0, nil
+	}
+
+	return live.IndexTemplate.Version, nil
+}
+
+func templateBody(template IndexTemplate) map[string]interface{} {
+	settings := map[string]interface{}{}
+	if template.Policy != "" {
+		settings["index.lifecycle.name"] = template.Policy
+	}
+
+	return map[string]interface{}{
+		"index_patterns": []string{template.Pattern},
+		"priority":       indexTemplatePriority,
+		"version":        IndexTemplateVersion,
+		"_meta":          map[string]interface{}{"managed_by": "true-solar"},
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": map[string]interface{}{
+				"properties": template.Properties,
+			},
+		},
+	}
+}
+
+// lifecyclePolicy lowers the priority of indices no longer written by the
+// collectors and deletes them after the retention, if any. Indices are not
+// made read-only, the troubleshoot module re-collects past days.
+func lifecyclePolicy(lifecycle config.IndexLifecycleConfig) map[string]interface{} {
+	phases := map[string]interface{}{
+		"hot": map[string]interface{}{
+			"min_age": "0ms",
+			"actions": map[string]interface{}{"set_priority": map[string]interface{}{"priority": 100}},
+		},
+		"warm": map[string]interface{}{
+			"min_age": fmt.Sprintf("%dd", lifecycle.WarmAfterDays),
+			"actions": map[string]interface{}{"set_priority": map[string]interface{}{"priority": 50}},
+		},
+	}
+
+	if lifecycle.DeleteAfterDays > 0 {
+		phases["delete"] = map[string]interface{}{
+			"min_age": fmt.Sprintf("%dd", max(lifecycle.DeleteAfterDays, lifecycle.WarmAfterDays)),
+			"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+		}
+	}
+
+	return map[string]interface{}{
+		"policy": map[string]interface{}{"phases": phases},
+	}
+}
+
+// flattenTypes collects the types of properties by dotted field name,
+// including multi-fields like "id.keyword".
+func flattenTypes(prefix string, properties interface{}, types map[string]string) {
+	fields, ok := properties.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for name, value := range fields {
+		field, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		fullName := prefix + name
+		if fieldType, ok := field["type"].(string); ok {
+			types[fullName] = fieldType
+		}
+
+		flattenTypes(fullName+".", field["properties"], types)
+		flattenTypes(fullName+".", field["fields"], types)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
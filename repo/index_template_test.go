@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/olivere/elastic/v7"
+)
+
+// newTemplateCluster serves the index template API and records the put
+// templates by name. With ilm false the lifecycle API answers like a cluster
+// without ILM.
+func newTemplateCluster(t *testing.T, ilm bool) (*elastic.Client, map[string]map[string]interface{}) {
+	t.Helper()
+	var mu sync.Mutex
+	templates := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/_ilm/policy/") && r.Method == http.MethodPut:
+			if !ilm {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"no handler found for uri [/_ilm/policy]"},"status":400}`)
+				return
+			}
+
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		case strings.HasPrefix(r.URL.Path, "/_index_template/") && r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error":{"type":"resource_not_found_exception"},"status":404}`)
+		case strings.HasPrefix(r.URL.Path, "/_index_template/") && r.Method == http.MethodPut:
+			body := make(map[string]interface{})
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode template: %v", err)
+			}
+
+			mu.Lock()
+			templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = body
+			mu.Unlock()
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		t.Fatalf("create elastic client: %v", err)
+	}
+
+	return client, templates
+}
+
+func lifecycleName(template map[string]interface{}) interface{} {
+	body, _ := template["template"].(map[string]interface{})
+	settings, _ := body["settings"].(map[string]interface{})
+	return settings["index.lifecycle.name"]
+}
+
+func TestIndexTemplateInstall(t *testing.T) {
+	client, templates := newTemplateCluster(t, true)
+
+	if err := NewIndexTemplateRepo(client).Install(config.ElasticsearchConfig{}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	for _, template := range IndexTemplates {
+		put, ok := templates[template.Name]
+		if !ok {
+			t.Errorf("template %s was not put", template.Name)
+			continue
+		}
+
+		if template.Policy != "" && lifecycleName(put) != template.Policy {
+			t.Errorf("template %s lifecycle = %v, want %s", template.Name, lifecycleName(put), template.Policy)
+		}
+	}
+}
+
+func TestIndexTemplateInstallWithoutILM(t *testing.T) {
+	client, templates := newTemplateCluster(t, false)
+
+	err := NewIndexTemplateRepo(client).Install(config.ElasticsearchConfig{})
+	if err == nil {
+		t.Fatal("Install() without ILM succeeded")
+	}
+
+	for _, template := range IndexTemplates {
+		put, ok := templates[template.Name]
+		if !ok {
+			t.Errorf("template %s was not put", template.Name)
+			continue
+		}
+
+		if lifecycleName(put) != nil {
+			t.Errorf("template %s lifecycle = %v, want none", template.Name, lifecycleName(put))
+		}
+
+		if template.Policy != "" && !strings.Contains(err.Error(), "put lifecycle policy "+template.Policy) {
+			t.Errorf("Install() error = %v, want the error of policy %s", err, template.Policy)
+		}
+	}
+}
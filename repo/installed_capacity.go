@@ -1,12 +1,15 @@
 package repo
 
 import (
+	"errors"
+
 	"github.com/HavvokLab/true-solar/model"
 	"gorm.io/gorm"
 )
 
 type InstalledCapacityRepo interface {
 	FindOne() (*model.InstalledCapacity, error)
+	Save(data *model.InstalledCapacity) error
 }
 
 type installedCapacityRepo struct {
@@ -27,3 +30,21 @@ func (r *installedCapacityRepo) FindOne() (*model.InstalledCapacity, error) {
 
 	return &installedCapacity, nil
 }
+
+// Save updates the single installed capacity row, creating it when the
+// table is still empty.
+func (r *installedCapacityRepo) Save(data *model.InstalledCapacity) error {
+	tx := r.db.Session(&gorm.Session{})
+	var current model.InstalledCapacity
+	err := tx.First(&current).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err == nil {
+		data.ID = current.ID
+		data.CreatedAt = current.CreatedAt
+	}
+
+	return tx.Save(data).Error
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"gorm.io/gorm"
+)
+
+// JobRunFilter narrows FindAll. Zero values are ignored.
+type JobRunFilter struct {
+	JobName string
+	Vendor  string
+	Status  string
+	Since   time.Time
+	Limit   int
+}
+
+type JobRunRepo interface {
+	Create(run *model.JobRun) error
+	Update(run *model.JobRun) error
+	FindAll(filter JobRunFilter) ([]model.JobRun, error)
+}
+
+type jobRunRepo struct {
+	db *gorm.DB
+}
+
+func NewJobRunRepo(db *gorm.DB) JobRunRepo {
+	return &jobRunRepo{db: db}
+}
+
+func (r *jobRunRepo) Create(run *model.JobRun) error {
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Create(run).Error
+}
+
+func (r *jobRunRepo) Update(run *model.JobRun) error {
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Save(run).Error
+}
+
+func (r *jobRunRepo) FindAll(filter JobRunFilter) ([]model.JobRun, error) {
+	tx := r.db.Session(&gorm.Session{})
+	if filter.JobName != "" {
+		tx = tx.Where("job_name = ?", filter.JobName)
+	}
+
+	if filter.Vendor != "" {
+		tx = tx.Where("vendor = ?", filter.Vendor)
+	}
+
+	if filter.Status != "" {
+		tx = tx.Where("status = ?", filter.Status)
+	}
+
+	if !filter.Since.IsZero() {
+		tx = tx.Where("started_at >= ?", filter.Since)
+	}
+
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+
+	var data []model.JobRun
+	if err := tx.Order("started_at DESC").Order("id DESC").Find(&data).Error; err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -2,22 +2,25 @@ package repo
 
 import (
 	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
 	"gorm.io/gorm"
 )
 
 type KStarCredentialRepo interface {
 	FindAll() ([]model.KstarCredential, error)
+	FindByID(id int64) (*model.KstarCredential, error)
 	Create(credential *model.KstarCredential) error
 	Update(id int64, credential *model.KstarCredential) error
 	Delete(id int64) error
 }
 
 type kStarCredentialRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	cipher *secret.Cipher
 }
 
-func NewKStarCredentialRepo(db *gorm.DB) KStarCredentialRepo {
-	return &kStarCredentialRepo{db: db}
+func NewKStarCredentialRepo(db *gorm.DB, cipher *secret.Cipher) KStarCredentialRepo {
+	return &kStarCredentialRepo{db: db, cipher: cipher}
 }
 
 func (r *kStarCredentialRepo) FindAll() ([]model.KstarCredential, error) {
@@ -27,10 +30,36 @@ func (r *kStarCredentialRepo) FindAll() ([]model.KstarCredential, error) {
 		return nil, err
 	}
 
+	for i := range credentials {
+		if err := decryptSecrets(r.cipher, &credentials[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	return credentials, nil
 }
 
+func (r *kStarCredentialRepo) FindByID(id int64) (*model.KstarCredential, error) {
+	var credential model.KstarCredential
+	tx := r.db.Session(&gorm.Session{})
+	if err := tx.First(&credential, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := decryptSecrets(r.cipher, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
 func (r *kStarCredentialRepo) Create(credential *model.KstarCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
 	if err := tx.Create(credential).Error; err != nil {
 		return err
@@ -39,9 +68,18 @@ func (r *kStarCredentialRepo) Create(credential *model.KstarCredential) error {
 	return nil
 }
 
+// Update replaces the credential with the given id, emptied fields included.
 func (r *kStarCredentialRepo) Update(id int64, credential *model.KstarCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
-	if err := tx.Where("id = ?", id).Updates(credential).Error; err != nil {
+	if err := tx.Model(&model.KstarCredential{}).Where("id = ?", id).
+		Select("username", "password", "owner", "updated_at").
+		Updates(credential).Error; err != nil {
 		return err
 	}
 
@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"gorm.io/gorm"
+)
+
+type MaintenanceWindowRepo interface {
+	FindAll() ([]model.MaintenanceWindow, error)
+	FindActive(at time.Time) ([]model.MaintenanceWindow, error)
+	FindByID(id int64) (*model.MaintenanceWindow, error)
+	Create(data *model.MaintenanceWindow) error
+	Update(id int64, data *model.MaintenanceWindow) error
+	Delete(id int64) error
+}
+
+type maintenanceWindowRepo struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceWindowRepo(db *gorm.DB) MaintenanceWindowRepo {
+	return &maintenanceWindowRepo{
+		db: db,
+	}
+}
+
+// FindAll returns the windows, the latest start first.
+func (r *maintenanceWindowRepo) FindAll() ([]model.MaintenanceWindow, error) {
+	tx := r.db.Session(&gorm.Session{})
+	var windows []model.MaintenanceWindow
+	if err := tx.Order("start_at desc, id").Find(&windows).Error; err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// FindActive returns the windows open at the given time. The bounds are
+// stored in UTC and SQLite compares them as text, so at is queried in UTC.
+func (r *maintenanceWindowRepo) FindActive(at time.Time) ([]model.MaintenanceWindow, error) {
+	tx := r.db.Session(&gorm.Session{})
+	var windows []model.MaintenanceWindow
+	at = at.UTC()
+	if err := tx.Where("start_at <= ? AND end_at > ?", at, at).Order("id").Find(&windows).Error; err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+func (r *maintenanceWindowRepo) FindByID(id int64) (*model.MaintenanceWindow, error) {
+	tx := r.db.Session(&gorm.Session{})
+	var window model.MaintenanceWindow
+	if err := tx.First(&window, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return &window, nil
+}
+
+// Create stores the window with its bounds in UTC, see FindActive.
+func (r *maintenanceWindowRepo) Create(data *model.MaintenanceWindow) error {
+	toUTC(data)
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Create(data).Error
+}
+
+// Update replaces the window with the given id, emptied scope fields
+// included. The bounds are stored in UTC as by Create.
+func (r *maintenanceWindowRepo) Update(id int64, data *model.MaintenanceWindow) error {
+	toUTC(data)
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Model(&model.MaintenanceWindow{}).Where("id = ?", id).
+		Select("vendor", "site_id", "plant", "device_sn", "area", "start_at", "end_at", "reason", "updated_at").
+		Updates(data).Error
+}
+
+func (r *maintenanceWindowRepo) Delete(id int64) error {
+	tx := r.db.Session(&gorm.Session{})
+	return tx.Delete(&model.MaintenanceWindow{}, id).Error
+}
+
+// toUTC moves the bounds of a window to UTC, the same instants.
+func toUTC(data *model.MaintenanceWindow) {
+	data.StartAt = data.StartAt.UTC()
+	data.EndAt = data.EndAt.UTC()
+}
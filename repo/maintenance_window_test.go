@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMaintenanceWindowFindActiveTimezone(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	bangkok := time.FixedZone("Asia/Bangkok", 7*60*60)
+	windowRepo := NewMaintenanceWindowRepo(db)
+
+	// Written in UTC as by the admin API, and in another zone as by a caller
+	// passing local times.
+	utcWindow := &model.MaintenanceWindow{
+		Vendor:  model.VendorTypeHuawei,
+		StartAt: time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC),
+		EndAt:   time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC),
+	}
+	localWindow := &model.MaintenanceWindow{
+		Vendor:  model.VendorTypeKstar,
+		StartAt: time.Date(2024, 5, 1, 8, 0, 0, 0, bangkok),
+		EndAt:   time.Date(2024, 5, 1, 9, 0, 0, 0, bangkok),
+	}
+	for _, window := range []*model.MaintenanceWindow{utcWindow, localWindow} {
+		if err := windowRepo.Create(window); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{name: "utc inside", at: time.Date(2024, 5, 1, 1, 30, 0, 0, time.UTC), want: 2},
+		{name: "local inside", at: time.Date(2024, 5, 1, 8, 30, 0, 0, bangkok), want: 2},
+		{name: "local at end", at: time.Date(2024, 5, 1, 9, 0, 0, 0, bangkok), want: 0},
+		{name: "local before start", at: time.Date(2024, 5, 1, 7, 59, 0, 0, bangkok), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := windowRepo.FindActive(tt.at)
+			if err != nil {
+				t.Fatalf("FindActive() error = %v", err)
+			}
+
+			if len(windows) != tt.want {
+				t.Errorf("FindActive(%s) = %d windows, want %d", tt.at, len(windows), tt.want)
+			}
+		})
+	}
+
+	// An update keeps the instant when given another zone.
+	localWindow.EndAt = time.Date(2024, 5, 1, 10, 0, 0, 0, bangkok)
+	if err := windowRepo.Update(localWindow.ID, localWindow); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	windows, err := windowRepo.FindActive(time.Date(2024, 5, 1, 2, 30, 0, 0, time.UTC))
+	if err != nil || len(windows) != 1 || windows[0].ID != localWindow.ID {
+		t.Errorf("FindActive() after the update = %+v, %v, want the updated window", windows, err)
+	}
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/model"
@@ -12,6 +13,7 @@ import (
 type PerformanceAlarmConfigRepo interface {
 	GetLowPerformanceAlarmConfig() (*model.PerformanceAlarmConfig, error)
 	GetSumPerformanceAlarmConfig() (*model.PerformanceAlarmConfig, error)
+	Save(data *model.PerformanceAlarmConfig) error
 }
 
 type performanceAlarmConfigRepo struct {
@@ -25,41 +27,53 @@ func NewPerformanceAlarmConfigRepo(db *gorm.DB) PerformanceAlarmConfigRepo {
 }
 
 func (r *performanceAlarmConfigRepo) GetLowPerformanceAlarmConfig() (*model.PerformanceAlarmConfig, error) {
+	return r.findByName(config.LowPerformanceAlarm, model.PerformanceAlarmConfig{
+		Name:       config.LowPerformanceAlarm,
+		Interval:   config.LowPerformanceAlarmInterval,
+		HitDay:     pointy.Int(config.LowPerformanceAlarmHitDay),
+		Percentage: config.LowPerformanceAlarmPercentage,
+		Duration:   pointy.Int(config.LowPerformanceAlarmDuration),
+	})
+}
+
+func (r *performanceAlarmConfigRepo) GetSumPerformanceAlarmConfig() (*model.PerformanceAlarmConfig, error) {
+	return r.findByName(config.SumPerformanceAlarm, model.PerformanceAlarmConfig{
+		Name:       config.SumPerformanceAlarm,
+		Interval:   config.SumPerformanceAlarmInterval,
+		HitDay:     pointy.Int(config.SumPerformanceAlarmHitDay),
+		Percentage: config.SumPerformanceAlarmPercentage,
+		Duration:   pointy.Int(config.SumPerformanceAlarmDuration),
+	})
+}
+
+// findByName returns the config row of an alarm, or the built-in defaults
+// of fallback when the row is missing.
+func (r *performanceAlarmConfigRepo) findByName(name string, fallback model.PerformanceAlarmConfig) (*model.PerformanceAlarmConfig, error) {
 	tx := r.db.Session(&gorm.Session{})
 	data := model.PerformanceAlarmConfig{}
-	if err := tx.First(&data, "name = ?", config.LowPerformanceAlarm).Error; err != nil {
+	if err := tx.First(&data, "name = ?", name).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Return fallback config when not found
-			return &model.PerformanceAlarmConfig{
-				Name:       config.LowPerformanceAlarm,
-				Interval:   config.LowPerformanceAlarmInterval,
-				HitDay:     pointy.Int(config.LowPerformanceAlarmHitDay),
-				Percentage: config.LowPerformanceAlarmPercentage,
-				Duration:   pointy.Int(config.LowPerformanceAlarmDuration),
-			}, nil
+			return &fallback, nil
 		}
-		return nil, err
+		return nil, fmt.Errorf("performance alarm config %s: %w", name, err)
 	}
 
 	return &data, nil
 }
 
-func (r *performanceAlarmConfigRepo) GetSumPerformanceAlarmConfig() (*model.PerformanceAlarmConfig, error) {
+// Save updates the config with the same name, creating it when missing.
+func (r *performanceAlarmConfigRepo) Save(data *model.PerformanceAlarmConfig) error {
 	tx := r.db.Session(&gorm.Session{})
-	data := model.PerformanceAlarmConfig{}
-	if err := tx.First(&data, "name = ?", config.SumPerformanceAlarm).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Return fallback config when not found
-			return &model.PerformanceAlarmConfig{
-				Name:       config.SumPerformanceAlarm,
-				Interval:   config.SumPerformanceAlarmInterval,
-				HitDay:     pointy.Int(config.SumPerformanceAlarmHitDay),
-				Percentage: config.SumPerformanceAlarmPercentage,
-				Duration:   pointy.Int(config.SumPerformanceAlarmDuration),
-			}, nil
-		}
-		return nil, err
+	current := model.PerformanceAlarmConfig{}
+	err := tx.First(&current, "name = ?", data.Name).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
 	}
 
-	return &data, nil
+	if err == nil {
+		data.ID = current.ID
+		data.CreatedAt = current.CreatedAt
+	}
+
+	return tx.Save(data).Error
 }
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/migration"
+	"github.com/HavvokLab/true-solar/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPerformanceAlarmConfigFallback(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	if _, err := migration.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	configRepo := NewPerformanceAlarmConfigRepo(db)
+	low := &model.PerformanceAlarmConfig{Name: config.LowPerformanceAlarm, Interval: 12, Percentage: 70}
+	if err := configRepo.Save(low); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := db.Where("name = ?", config.SumPerformanceAlarm).Delete(&model.PerformanceAlarmConfig{}).Error; err != nil {
+		t.Fatalf("delete sum config: %v", err)
+	}
+
+	got, err := configRepo.GetLowPerformanceAlarmConfig()
+	if err != nil || got.Interval != 12 || got.Percentage != 70 {
+		t.Fatalf("GetLowPerformanceAlarmConfig() = %+v, %v, want the saved row", got, err)
+	}
+
+	got, err = configRepo.GetSumPerformanceAlarmConfig()
+	if err != nil {
+		t.Fatalf("GetSumPerformanceAlarmConfig() error = %v", err)
+	}
+
+	if got.ID != 0 || got.Interval != config.SumPerformanceAlarmInterval || got.Percentage != config.SumPerformanceAlarmPercentage ||
+		got.HitDay == nil || *got.HitDay != config.SumPerformanceAlarmHitDay || got.Duration == nil || *got.Duration != config.SumPerformanceAlarmDuration {
+		t.Errorf("GetSumPerformanceAlarmConfig() = %+v, want the built-in defaults", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 type SiteRegionMappingRepo interface {
 	Count() (int64, error)
+	FindByID(id int64) (*model.SiteRegionMapping, error)
 	GetSiteRegionMappings() ([]model.SiteRegionMapping, error)
 	GetSiteRegionMappingsWithPagination(limit, offset int) ([]model.SiteRegionMapping, error)
 	GetAreaNotNull() ([]model.SiteRegionMapping, error)
@@ -14,7 +15,7 @@ type SiteRegionMappingRepo interface {
 	UpdateCity(id int64, data *model.SiteRegionMapping) error
 	DeleteCity(id int64) error
 	UpdateCityToNullArea(area string) error
-	UpdateSiteRegionMapping(codeListString, area string) error
+	UpdateSiteRegionMapping(codes []string, area string) error
 }
 
 type siteRegionMappingRepo struct {
@@ -28,7 +29,7 @@ func NewSiteRegionMappingRepo(db *gorm.DB) *siteRegionMappingRepo {
 func (r *siteRegionMappingRepo) Count() (int64, error) {
 	tx := r.db.Session(&gorm.Session{})
 	var count int64
-	err := tx.Model(&model.SiteRegionMapping{}).Count(&count).Error
+	err := tx.Model(&model.SiteRegionMapping{}).Where("code NOT LIKE 'EMPTY-%'").Count(&count).Error
 	if err != nil {
 		return 0, err
 	}
@@ -36,6 +37,17 @@ func (r *siteRegionMappingRepo) Count() (int64, error) {
 	return count, nil
 }
 
+func (r *siteRegionMappingRepo) FindByID(id int64) (*model.SiteRegionMapping, error) {
+	tx := r.db.Session(&gorm.Session{})
+	var siteRegionMapping model.SiteRegionMapping
+	err := tx.First(&siteRegionMapping, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &siteRegionMapping, nil
+}
+
 func (r *siteRegionMappingRepo) GetSiteRegionMappings() ([]model.SiteRegionMapping, error) {
 	tx := r.db.Session(&gorm.Session{})
 	var siteRegionMappings []model.SiteRegionMapping
@@ -109,9 +121,9 @@ func (r *siteRegionMappingRepo) UpdateCityToNullArea(area string) error {
 	return nil
 }
 
-func (r *siteRegionMappingRepo) UpdateSiteRegionMapping(codeListString, area string) error {
+func (r *siteRegionMappingRepo) UpdateSiteRegionMapping(codes []string, area string) error {
 	tx := r.db.Session(&gorm.Session{})
-	err := tx.Model(&model.SiteRegionMapping{}).Where("code IN ?", codeListString).Update("area", area).Error
+	err := tx.Model(&model.SiteRegionMapping{}).Where("code IN ?", codes).Update("area", area).Error
 	if err != nil {
 		return err
 	}
@@ -8,7 +8,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/pool"
 	"github.com/olivere/elastic/v7"
 )
 
@@ -39,105 +41,127 @@ func isRetryableError(err error) bool {
 }
 
 type SolarRepo interface {
-	BulkIndex(index string, docs []interface{}) error
-	UpsertSiteStation(docs []model.SiteItem) error
+	BulkIndex(index string, docs []interface{}) (model.BulkResult, error)
+	UpsertSiteStation(docs []model.SiteItem) (model.BulkResult, error)
 	GetPerformanceLow(duration int, efficiencyFactor float64, focusHour int, thresholdPct float64) ([]*elastic.AggregationBucketCompositeItem, error)
 	GetSumPerformanceLow(duration int) ([]*elastic.AggregationBucketCompositeItem, error)
 	GetUniquePlantByIndex(index string) ([]*elastic.AggregationBucketKeyItem, error)
 	GetPerformanceAlarm(index string) ([]*model.SnmpPerformanceAlarmItem, error)
+	BulkConfig() config.BulkConfig
 }
 
 type solarRepo struct {
-	elastic *elastic.Client
+	elastic    *elastic.Client
+	bulkConf   config.BulkConfig
+	bulkPool   *pool.Pool
+	retryDelay time.Duration
 }
 
-func NewSolarRepo(elastic *elastic.Client) *solarRepo {
+// NewSolarRepo returns the repository of the solar documents in elastic.
+// Bulk requests are split by bulkConf and sent on bulkPool, which the repos
+// of a command share so bulkConf.Concurrency bounds the requests in flight of
+// the whole command.
+func NewSolarRepo(elastic *elastic.Client, bulkConf config.BulkConfig, bulkPool *pool.Pool) *solarRepo {
 	return &solarRepo{
-		elastic: elastic,
+		elastic:    elastic,
+		bulkConf:   bulkConf.WithDefaults(),
+		bulkPool:   bulkPool,
+		retryDelay: BaseRetryDelay,
 	}
 }
 
+// BulkConfig returns the bounds of the bulk requests of the repo.
+func (r *solarRepo) BulkConfig() config.BulkConfig {
+	return r.bulkConf
+}
+
 func (r *solarRepo) SearchIndex() *elastic.SearchService {
 	index := fmt.Sprintf("%v*", model.SolarIndex)
 	return r.elastic.Search(index)
 }
 
+// CreateIndexIfNotExist creates index from its template. Collectors of
+// several credentials create the daily index at the same time, an index
+// created in between is not an error.
 func (r *solarRepo) CreateIndexIfNotExist(index string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
 	defer cancel()
 
-	if exist, err := r.elastic.IndexExists(index).Do(ctx); err != nil {
-		if !exist {
-			result, err := r.elastic.CreateIndex(index).Do(ctx)
-			if err != nil {
-				return err
-			}
+	exist, err := r.elastic.IndexExists(index).Do(ctx)
+	if err != nil {
+		return err
+	}
 
-			if !result.Acknowledged {
-				return errors.New("elasticsearch did not acknowledge")
-			}
+	if exist {
+		return nil
+	}
+
+	result, err := r.elastic.CreateIndex(index).Do(ctx)
+	if err != nil {
+		if isIndexAlreadyExists(err) {
+			return nil
 		}
+		return err
+	}
+
+	if !result.Acknowledged {
+		return errors.New("elasticsearch did not acknowledge")
 	}
 
 	return nil
 }
 
-// |=> Implementation
-func (r *solarRepo) BulkIndex(index string, docs []interface{}) error {
-	if err := r.CreateIndexIfNotExist(index); err != nil {
-		return err
+func isIndexAlreadyExists(err error) bool {
+	var elasticErr *elastic.Error
+	if !errors.As(err, &elasticErr) || elasticErr.Details == nil {
+		return false
 	}
 
-	bulk := r.elastic.Bulk()
-	for _, doc := range docs {
-		bulk.Add(elastic.NewBulkIndexRequest().Index(index).Doc(doc))
-	}
+	return elasticErr.Details.Type == "resource_already_exists_exception"
+}
 
-	var lastErr error
-	for attempt := 0; attempt <= MaxRetryAttempts; attempt++ {
-		ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
-		_, lastErr = bulk.Do(ctx)
-		cancel()
+// documentID returns the deterministic _id of a document, or an empty string
+// to let Elasticsearch generate one.
+func documentID(doc interface{}) string {
+	if identifiable, ok := doc.(model.Identifiable); ok {
+		return identifiable.DocumentID()
+	}
 
-		if lastErr == nil {
-			return nil
-		}
+	return ""
+}
 
-		// Only retry on connection errors like port exhaustion
-		if !isRetryableError(lastErr) {
-			return lastErr
-		}
+// |=> Implementation
+func (r *solarRepo) BulkIndex(index string, docs []interface{}) (model.BulkResult, error) {
+	if err := r.CreateIndexIfNotExist(index); err != nil {
+		return model.BulkResult{Pending: allPending(len(docs))}, err
+	}
 
-		// Exponential backoff: 2s, 4s, 8s
-		if attempt < MaxRetryAttempts {
-			time.Sleep(BaseRetryDelay * time.Duration(1<<attempt))
+	requests := make([]elastic.BulkableRequest, 0, len(docs))
+	for _, doc := range docs {
+		request := elastic.NewBulkIndexRequest().Index(index).Doc(doc)
+		if id := documentID(doc); id != "" {
+			request.Id(id)
 		}
+		requests = append(requests, request)
 	}
 
-	return lastErr
+	return r.bulk(index, requests, docs)
 }
 
-func (r *solarRepo) UpsertSiteStation(docs []model.SiteItem) error {
+func (r *solarRepo) UpsertSiteStation(docs []model.SiteItem) (model.BulkResult, error) {
 	index := model.SiteStationIndex
-	err := r.CreateIndexIfNotExist(index)
-	if err != nil {
-		return err
+	if err := r.CreateIndexIfNotExist(index); err != nil {
+		return model.BulkResult{Pending: allPending(len(docs))}, err
 	}
 
-	bulk := r.elastic.Bulk()
+	requests := make([]elastic.BulkableRequest, 0, len(docs))
+	sources := make([]interface{}, 0, len(docs))
 	for _, doc := range docs {
-		bulk.Add(elastic.NewBulkUpdateRequest().Index(index).Id(doc.SiteID).Doc(doc).DocAsUpsert(true))
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
-	defer cancel()
-
-	_, err = bulk.Do(ctx)
-	if err != nil {
-		return err
+		requests = append(requests, elastic.NewBulkUpdateRequest().Index(index).Id(doc.SiteID).Doc(doc).DocAsUpsert(true))
+		sources = append(sources, doc)
 	}
 
-	return nil
+	return r.bulk(index, requests, sources)
 }
 
 func (r *solarRepo) GetPerformanceLow(duration int, efficiencyFactor float64, focusHour int, thresholdPct float64) ([]*elastic.AggregationBucketCompositeItem, error) {
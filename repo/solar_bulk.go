@@ -0,0 +1,264 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
+	"github.com/olivere/elastic/v7"
+)
+
+// isRetryableItem checks if a bulk item was rejected by a busy cluster and can
+// be sent again.
+func isRetryableItem(item *elastic.BulkResponseItem) bool {
+	if item.Status == http.StatusTooManyRequests {
+		return true
+	}
+
+	return item.Error != nil && item.Error.Type == "es_rejected_execution_exception"
+}
+
+// bulkBatch is a range of the requests sent in one bulk request.
+type bulkBatch struct {
+	start, end int
+}
+
+// bulk splits requests into batches bounded by the bulk config and sends them
+// on the bulk pool. The results of the batches are summed, a failed batch does
+// not stop the others. docs holds the source of each request, the pending
+// positions of the batches are translated to positions in docs.
+func (r *solarRepo) bulk(index string, requests []elastic.BulkableRequest, docs []interface{}) (model.BulkResult, error) {
+	batches, err := r.batches(requests)
+	if err != nil {
+		metrics.IncBulkIndexFailure(index)
+		return model.BulkResult{}, fmt.Errorf("bulk requests of index %s: %w", index, err)
+	}
+
+	var (
+		mu     sync.Mutex
+		result model.BulkResult
+		errs   []error
+	)
+
+	group := r.bulkPool.NewGroup()
+	for _, batch := range batches {
+		group.Go(func() {
+			batchResult, err := r.sendBatch(index, requests[batch.start:batch.end], docs[batch.start:batch.end])
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Add(batchResult)
+			for _, position := range batchResult.Pending {
+				result.Pending = append(result.Pending, batch.start+position)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		})
+	}
+	group.Wait()
+	sort.Ints(result.Pending)
+
+	return result, errors.Join(errs...)
+}
+
+// batches splits requests after Actions requests or before the payload
+// exceeds Bytes. A request larger than Bytes is sent on its own.
+func (r *solarRepo) batches(requests []elastic.BulkableRequest) ([]bulkBatch, error) {
+	batches := make([]bulkBatch, 0, len(requests)/r.bulkConf.Actions+1)
+	start, size := 0, 0
+	for i, request := range requests {
+		// Source caches the lines, they are not marshaled again on send.
+		lines, err := request.Source()
+		if err != nil {
+			return nil, err
+		}
+
+		requestSize := 0
+		for _, line := range lines {
+			requestSize += len(line) + 1
+		}
+
+		if i > start && (i-start >= r.bulkConf.Actions || size+requestSize > r.bulkConf.Bytes) {
+			batches = append(batches, bulkBatch{start: start, end: i})
+			start, size = i, 0
+		}
+		size += requestSize
+	}
+
+	if start < len(requests) {
+		batches = append(batches, bulkBatch{start: start, end: len(requests)})
+	}
+
+	return batches, nil
+}
+
+// sendBatch sends requests and inspects every item of the response. Connection
+// errors retry the whole request and items rejected by a busy cluster are
+// sent again, both with exponential backoff. Items failing for good, like
+// mapping conflicts, are written to the dead-letter index. docs holds the
+// source of each request for the dead letters. Requests not indexed when it
+// gives up, or not sent at all, are returned as Pending.
+func (r *solarRepo) sendBatch(index string, requests []elastic.BulkableRequest, docs []interface{}) (model.BulkResult, error) {
+	var result model.BulkResult
+	if len(requests) == 0 {
+		return result, nil
+	}
+
+	pending := allPending(len(requests))
+
+	deadLetters := make([]model.DeadLetterItem, 0)
+	var lastErr error
+	for attempt := 0; len(pending) > 0 && attempt <= MaxRetryAttempts; attempt++ {
+		if attempt > 0 {
+			// Exponential backoff: 2s, 4s, 8s
+			metrics.IncBulkIndexRetry(index)
+			result.Retried += len(pending)
+			time.Sleep(r.retryDelay * time.Duration(1<<(attempt-1)))
+		}
+
+		response, err := r.send(requests, pending)
+		if err != nil {
+			// Only retry on connection errors like port exhaustion
+			if !isRetryableError(err) {
+				metrics.IncBulkIndexFailure(index)
+				result.Pending = pending
+				return result, err
+			}
+
+			lastErr = err
+			continue
+		}
+
+		if len(response.Items) != len(pending) {
+			metrics.IncBulkIndexFailure(index)
+			result.Pending = pending
+			return result, fmt.Errorf("bulk response of index %s has %d items for %d requests", index, len(response.Items), len(pending))
+		}
+
+		rejected := make([]int, 0)
+		for i, items := range response.Items {
+			position := pending[i]
+			for _, item := range items {
+				switch {
+				case item.Error == nil && item.Status < http.StatusMultipleChoices:
+					result.Indexed++
+				case isRetryableItem(item):
+					rejected = append(rejected, position)
+					lastErr = fmt.Errorf("%d: %s", item.Status, errorReason(item))
+				default:
+					deadLetters = append(deadLetters, newDeadLetter(index, item, docs[position]))
+				}
+			}
+		}
+
+		pending = rejected
+	}
+
+	result.Failed = len(deadLetters)
+	var errs []error
+	if len(deadLetters) > 0 {
+		metrics.AddBulkIndexDeadLetters(index, len(deadLetters))
+		if err := r.deadLetter(deadLetters); err != nil {
+			errs = append(errs, fmt.Errorf("write %d dead letters of index %s: %w", len(deadLetters), index, err))
+		} else {
+			result.DeadLettered = len(deadLetters)
+		}
+	}
+
+	if len(pending) > 0 {
+		metrics.IncBulkIndexFailure(index)
+		result.Pending = pending
+		errs = append(errs, fmt.Errorf("%d of %d documents of index %s not indexed after %d retries: %w", len(pending), len(requests), index, MaxRetryAttempts, lastErr))
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// allPending returns the positions of n documents none of which was sent.
+func allPending(n int) []int {
+	pending := make([]int, n)
+	for i := range pending {
+		pending[i] = i
+	}
+
+	return pending
+}
+
+func (r *solarRepo) send(requests []elastic.BulkableRequest, positions []int) (*elastic.BulkResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
+	defer cancel()
+
+	bulk := r.elastic.Bulk()
+	for _, position := range positions {
+		bulk.Add(requests[position])
+	}
+
+	return bulk.Do(ctx)
+}
+
+// deadLetter indexes the dead letters into the daily dead-letter index.
+func (r *solarRepo) deadLetter(items []model.DeadLetterItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultESTimeout)
+	defer cancel()
+
+	index := fmt.Sprintf("%s-%s", model.DeadLetterIndex, time.Now().Format("2006.01.02"))
+	bulk := r.elastic.Bulk()
+	for _, item := range items {
+		bulk.Add(elastic.NewBulkIndexRequest().Index(index).Doc(item))
+	}
+
+	response, err := bulk.Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	if failed := response.Failed(); len(failed) > 0 {
+		return fmt.Errorf("%d of %d dead letters rejected: %s", len(failed), len(items), errorReason(failed[0]))
+	}
+
+	return nil
+}
+
+func newDeadLetter(index string, item *elastic.BulkResponseItem, doc interface{}) model.DeadLetterItem {
+	deadLetter := model.DeadLetterItem{
+		Timestamp:  time.Now(),
+		Index:      index,
+		DocumentID: item.Id,
+		Status:     item.Status,
+		Reason:     errorReason(item),
+	}
+
+	if item.Error != nil {
+		deadLetter.ErrorType = item.Error.Type
+	}
+
+	if source, err := json.Marshal(doc); err == nil {
+		deadLetter.Source = string(source)
+	}
+
+	return deadLetter
+}
+
+// errorReason returns the reason of a failed item including its cause, e.g.
+// the field of a mapper_parsing_exception.
+func errorReason(item *elastic.BulkResponseItem) string {
+	if item.Error == nil {
+		return http.StatusText(item.Status)
+	}
+
+	if item.Error.CausedBy != nil {
+		if cause, ok := item.Error.CausedBy["reason"].(string); ok && cause != "" {
+			return fmt.Sprintf("%s: %s", item.Error.Reason, cause)
+		}
+	}
+
+	return item.Error.Reason
+}
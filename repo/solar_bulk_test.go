@@ -0,0 +1,310 @@
+package repo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/pool"
+	"github.com/olivere/elastic/v7"
+)
+
+type bulkTestDoc struct {
+	ID      string `json:"id"`
+	Padding string `json:"padding,omitempty"`
+}
+
+func (d bulkTestDoc) DocumentID() string {
+	return d.ID
+}
+
+// bulkItemError is the answer of the cluster for one item, nil indexes it.
+type bulkItemError struct {
+	status int
+	kind   string
+}
+
+// bulkCluster serves the bulk API. respond answers the attempt-th request of
+// a document, counted from 0, and may be nil to index everything. Items of
+// the dead-letter index are always indexed.
+type bulkCluster struct {
+	respond func(id string, attempt int) *bulkItemError
+
+	mu          sync.Mutex
+	attempts    map[string]int
+	requests    [][]string
+	bodies      []int
+	deadLetters []model.DeadLetterItem
+}
+
+func newBulkCluster(t *testing.T, respond func(id string, attempt int) *bulkItemError) (*bulkCluster, *elastic.Client) {
+	t.Helper()
+	cluster := &bulkCluster{respond: respond, attempts: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodHead:
+			// The index exists.
+		case r.URL.Path == "/_bulk" && r.Method == http.MethodPost:
+			cluster.serveBulk(t, w, r)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		t.Fatalf("create elastic client: %v", err)
+	}
+
+	return cluster, client
+}
+
+func (c *bulkCluster) serveBulk(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	var body strings.Builder
+	scanner := bufio.NewScanner(r.Body)
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		body.WriteString(scanner.Text() + "\n")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]map[string]interface{}, 0, len(lines)/2)
+	ids := make([]string, 0, len(lines)/2)
+	for i := 0; i+1 < len(lines); i += 2 {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &action); err != nil {
+			t.Errorf("decode bulk action: %v", err)
+			return
+		}
+
+		meta := action["index"]
+		item := map[string]interface{}{"_index": meta.Index, "_id": meta.ID, "status": http.StatusCreated}
+		if strings.HasPrefix(meta.Index, model.DeadLetterIndex) {
+			var deadLetter model.DeadLetterItem
+			if err := json.Unmarshal([]byte(lines[i+1]), &deadLetter); err != nil {
+				t.Errorf("decode dead letter: %v", err)
+			}
+			c.deadLetters = append(c.deadLetters, deadLetter)
+			items = append(items, map[string]interface{}{"index": item})
+			continue
+		}
+
+		ids = append(ids, meta.ID)
+		attempt := c.attempts[meta.ID]
+		c.attempts[meta.ID]++
+		if c.respond != nil {
+			if failure := c.respond(meta.ID, attempt); failure != nil {
+				item["status"] = failure.status
+				item["error"] = map[string]interface{}{"type": failure.kind, "reason": failure.kind + " of " + meta.ID}
+			}
+		}
+		items = append(items, map[string]interface{}{"index": item})
+	}
+
+	if len(ids) > 0 {
+		c.requests = append(c.requests, ids)
+		c.bodies = append(c.bodies, body.Len())
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "errors": false, "items": items})
+}
+
+func (c *bulkCluster) sentRequests() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]string(nil), c.requests...)
+}
+
+func newBulkTestRepo(client *elastic.Client, conf config.BulkConfig) *solarRepo {
+	r := NewSolarRepo(client, conf, pool.New(2))
+	r.retryDelay = time.Millisecond
+	return r
+}
+
+func bulkTestDocs(n int, padding int) []interface{} {
+	docs := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, bulkTestDoc{ID: fmt.Sprintf("doc-%d", i), Padding: strings.Repeat("x", padding)})
+	}
+
+	return docs
+}
+
+func TestBulkIndexRetriesRejectedItems(t *testing.T) {
+	cluster, client := newBulkCluster(t, func(id string, attempt int) *bulkItemError {
+		switch {
+		case id == "doc-0" && attempt == 0:
+			return &bulkItemError{status: http.StatusTooManyRequests, kind: "circuit_breaking_exception"}
+		case id == "doc-1" && attempt < 2:
+			return &bulkItemError{status: http.StatusServiceUnavailable, kind: "es_rejected_execution_exception"}
+		}
+		return nil
+	})
+
+	result, err := newBulkTestRepo(client, config.BulkConfig{}).BulkIndex("solarcell-2024.01.15", bulkTestDocs(3, 0))
+	if err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	if result.Indexed != 3 || result.Retried != 3 || result.Failed != 0 || len(result.Pending) != 0 {
+		t.Errorf("BulkIndex() = %+v, want 3 indexed after 3 retries", result)
+	}
+
+	want := [][]string{{"doc-0", "doc-1", "doc-2"}, {"doc-0", "doc-1"}, {"doc-1"}}
+	if got := cluster.sentRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("bulk requests = %v, want %v", got, want)
+	}
+}
+
+func TestBulkIndexLeavesRejectedItemsPending(t *testing.T) {
+	cluster, client := newBulkCluster(t, func(id string, attempt int) *bulkItemError {
+		if id == "doc-3" {
+			return &bulkItemError{status: http.StatusTooManyRequests, kind: "es_rejected_execution_exception"}
+		}
+		return nil
+	})
+
+	// Two documents per batch, doc-3 is the second of the second batch.
+	result, err := newBulkTestRepo(client, config.BulkConfig{Actions: 2}).BulkIndex("solarcell-2024.01.15", bulkTestDocs(5, 0))
+	if err == nil || !strings.Contains(err.Error(), "not indexed after 3 retries") {
+		t.Fatalf("BulkIndex() error = %v, want the documents not indexed after the retries", err)
+	}
+
+	if result.Indexed != 4 || result.Retried != MaxRetryAttempts || !reflect.DeepEqual(result.Pending, []int{3}) {
+		t.Errorf("BulkIndex() = %+v, want 4 indexed and doc-3 pending", result)
+	}
+
+	if attempts := cluster.attempts["doc-3"]; attempts != MaxRetryAttempts+1 {
+		t.Errorf("doc-3 sent %d times, want %d", attempts, MaxRetryAttempts+1)
+	}
+}
+
+func TestBulkIndexDeadLettersFailedItems(t *testing.T) {
+	cluster, client := newBulkCluster(t, func(id string, attempt int) *bulkItemError {
+		if id == "doc-1" {
+			return &bulkItemError{status: http.StatusBadRequest, kind: "mapper_parsing_exception"}
+		}
+		return nil
+	})
+
+	result, err := newBulkTestRepo(client, config.BulkConfig{}).BulkIndex("solarcell-2024.01.15", bulkTestDocs(3, 0))
+	if err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	if result.Indexed != 2 || result.Retried != 0 || result.Failed != 1 || result.DeadLettered != 1 || len(result.Pending) != 0 {
+		t.Errorf("BulkIndex() = %+v, want 2 indexed and 1 dead-lettered without retries", result)
+	}
+
+	if len(cluster.deadLetters) != 1 {
+		t.Fatalf("dead letters = %+v, want one", cluster.deadLetters)
+	}
+
+	deadLetter := cluster.deadLetters[0]
+	if deadLetter.Index != "solarcell-2024.01.15" || deadLetter.DocumentID != "doc-1" || deadLetter.Status != http.StatusBadRequest ||
+		deadLetter.ErrorType != "mapper_parsing_exception" || !strings.Contains(deadLetter.Source, `"id":"doc-1"`) {
+		t.Errorf("dead letter = %+v, want the mapping failure of doc-1 with its source", deadLetter)
+	}
+}
+
+func TestBulkIndexSplitsBatches(t *testing.T) {
+	t.Run("by count", func(t *testing.T) {
+		cluster, client := newBulkCluster(t, nil)
+		result, err := newBulkTestRepo(client, config.BulkConfig{Actions: 2}).BulkIndex("solarcell-2024.01.15", bulkTestDocs(5, 0))
+		if err != nil || result.Indexed != 5 {
+			t.Fatalf("BulkIndex() = %+v, %v, want 5 indexed", result, err)
+		}
+
+		sizes := make(map[int]int)
+		for _, request := range cluster.sentRequests() {
+			sizes[len(request)]++
+		}
+
+		if !reflect.DeepEqual(sizes, map[int]int{2: 2, 1: 1}) {
+			t.Errorf("batch sizes = %v, want two of 2 and one of 1", sizes)
+		}
+	})
+
+	t.Run("by bytes", func(t *testing.T) {
+		cluster, client := newBulkCluster(t, nil)
+		conf := config.BulkConfig{Bytes: 500}
+		docs := append(bulkTestDocs(6, 100), bulkTestDoc{ID: "large", Padding: strings.Repeat("x", 1000)})
+		result, err := newBulkTestRepo(client, conf).BulkIndex("solarcell-2024.01.15", docs)
+		if err != nil || result.Indexed != len(docs) {
+			t.Fatalf("BulkIndex() = %+v, %v, want %d indexed", result, err, len(docs))
+		}
+
+		requests := cluster.sentRequests()
+		if len(requests) != 4 {
+			t.Fatalf("bulk requests = %v, want large alone and the others in pairs", requests)
+		}
+
+		for i, request := range requests {
+			if len(request) > 1 && cluster.bodies[i] > conf.Bytes {
+				t.Errorf("bulk request %v has %d bytes, want at most %d", request, cluster.bodies[i], conf.Bytes)
+			}
+
+			// The document larger than Bytes is sent on its own.
+			if len(request) > 1 && strings.Contains(strings.Join(request, ","), "large") {
+				t.Errorf("bulk request %v, want large alone", request)
+			}
+		}
+	})
+}
+
+func TestBulkProcessorFlushAndClose(t *testing.T) {
+	cluster, client := newBulkCluster(t, nil)
+	processor := NewBulkProcessor(newBulkTestRepo(client, config.BulkConfig{Actions: 2}), "solarcell-2024.01.15")
+
+	for _, doc := range bulkTestDocs(3, 0) {
+		if err := processor.Add(doc); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	// The first two documents are sent as a full batch, Flush sends the third.
+	processor.Flush()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(cluster.sentRequests()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if requests := cluster.sentRequests(); len(requests) != 2 {
+		t.Fatalf("bulk requests after Flush() = %v, want 2", requests)
+	}
+
+	if err := processor.Add(bulkTestDoc{ID: "doc-3"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	result, err := processor.Close()
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if result.Indexed != 4 {
+		t.Errorf("Close() = %+v, want 4 indexed", result)
+	}
+
+	if requests := cluster.sentRequests(); len(requests) != 3 || len(requests[2]) != 1 || requests[2][0] != "doc-3" {
+		t.Errorf("bulk requests = %v, want doc-3 sent by Close()", requests)
+	}
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"sync/atomic"
+
+	"github.com/HavvokLab/true-solar/model"
+)
+
+// countingSolarRepo wraps a SolarRepo and counts the documents and site
+// stations written successfully, so callers can report them in the job ledger
+// and metrics.
+type countingSolarRepo struct {
+	SolarRepo
+	documentsIndexed atomic.Int64
+	sitesUpserted    atomic.Int64
+	plants           atomic.Int64
+	devices          atomic.Int64
+	alarms           atomic.Int64
+}
+
+func NewCountingSolarRepo(solarRepo SolarRepo) *countingSolarRepo {
+	return &countingSolarRepo{SolarRepo: solarRepo}
+}
+
+// BulkIndex counts the indexed documents of the result, even when err is set
+// by a partial failure. Plants, devices and alarms are counted for successful
+// calls only, the result does not tell which items failed.
+func (r *countingSolarRepo) BulkIndex(index string, docs []interface{}) (model.BulkResult, error) {
+	result, err := r.SolarRepo.BulkIndex(index, docs)
+	r.documentsIndexed.Add(int64(result.Indexed))
+	if err != nil || result.Failed > 0 {
+		return result, err
+	}
+
+	for _, doc := range docs {
+		switch doc.(type) {
+		case model.PlantItem, *model.PlantItem:
+			r.plants.Add(1)
+		case model.DeviceItem, *model.DeviceItem:
+			r.devices.Add(1)
+		case model.AlarmItem, *model.AlarmItem:
+			r.alarms.Add(1)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *countingSolarRepo) UpsertSiteStation(docs []model.SiteItem) (model.BulkResult, error) {
+	result, err := r.SolarRepo.UpsertSiteStation(docs)
+	r.sitesUpserted.Add(int64(result.Indexed))
+	return result, err
+}
+
+func (r *countingSolarRepo) DocumentsIndexed() int64 {
+	return r.documentsIndexed.Load()
+}
+
+func (r *countingSolarRepo) SitesUpserted() int64 {
+	return r.sitesUpserted.Load()
+}
+
+func (r *countingSolarRepo) Plants() int64 {
+	return r.plants.Load()
+}
+
+func (r *countingSolarRepo) Devices() int64 {
+	return r.devices.Load()
+}
+
+func (r *countingSolarRepo) Alarms() int64 {
+	return r.alarms.Load()
+}
@@ -0,0 +1,478 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/olivere/elastic/v7"
+)
+
+// performanceHitFields and uniquePlantHitFields are the _source includes of
+// the top hits of the Elasticsearch queries.
+var (
+	performanceHitFields = []string{
+		"id", "name", "vendor_type", "node_type", "ac_phase", "plant_status",
+		"area", "site_id", "site_city_code", "site_city_name", "installed_capacity",
+	}
+	uniquePlantHitFields = []string{"name", "area", "vendor_type", "installed_capacity", "location", "owner"}
+)
+
+type memoryDocument struct {
+	index  string
+	id     string
+	doc    interface{}
+	source map[string]interface{}
+}
+
+// solarMemory keeps documents in memory and answers the SolarRepo queries
+// the way the Elasticsearch implementation does, so collectors and alarms
+// can be checked without a cluster. Documents are stored as their JSON
+// source, dates are bucketed and rounded in UTC like Elasticsearch does.
+type solarMemory struct {
+	mu      sync.RWMutex
+	now     func() time.Time
+	seq     int
+	indices map[string][]*memoryDocument
+	sites   map[string]model.SiteItem
+}
+
+func NewSolarMemoryRepo() *solarMemory {
+	return &solarMemory{
+		now:     time.Now,
+		indices: make(map[string][]*memoryDocument),
+		sites:   make(map[string]model.SiteItem),
+	}
+}
+
+// SetNow replaces the clock used to resolve the relative date ranges of
+// the performance queries.
+func (r *solarMemory) SetNow(now func() time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.now = now
+}
+
+// Documents returns the documents indexed into index, in indexing order. A
+// document replaced by one with the same _id keeps the position of the first.
+func (r *solarMemory) Documents(index string) []interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	docs := make([]interface{}, 0, len(r.indices[index]))
+	for _, doc := range r.indices[index] {
+		docs = append(docs, doc.doc)
+	}
+
+	return docs
+}
+
+// Indices returns the names of the indices that received documents, sorted.
+func (r *solarMemory) Indices() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	indices := make([]string, 0, len(r.indices))
+	for index := range r.indices {
+		indices = append(indices, index)
+	}
+
+	sort.Strings(indices)
+	return indices
+}
+
+// SiteStations returns the upserted site documents sorted by site ID.
+func (r *solarMemory) SiteStations() []model.SiteItem {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sites := make([]model.SiteItem, 0, len(r.sites))
+	for _, site := range r.sites {
+		sites = append(sites, site)
+	}
+
+	sort.Slice(sites, func(i, j int) bool { return sites[i].SiteID < sites[j].SiteID })
+	return sites
+}
+
+// |=> Implementation
+func (r *solarMemory) BulkIndex(index string, docs []interface{}) (model.BulkResult, error) {
+	stored := make([]*memoryDocument, 0, len(docs))
+	for _, doc := range docs {
+		buf, err := json.Marshal(doc)
+		if err != nil {
+			return model.BulkResult{}, err
+		}
+
+		source := make(map[string]interface{})
+		if err := json.Unmarshal(buf, &source); err != nil {
+			return model.BulkResult{}, fmt.Errorf("document of index %s is not an object: %w", index, err)
+		}
+
+		stored = append(stored, &memoryDocument{id: documentID(doc), doc: doc, source: source})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, doc := range stored {
+		doc.index = index
+		if doc.id == "" {
+			r.seq++
+			doc.id = fmt.Sprintf("memory-%d", r.seq)
+		}
+
+		if i := r.indexOf(index, doc.id); i >= 0 {
+			r.indices[index][i] = doc
+			continue
+		}
+
+		r.indices[index] = append(r.indices[index], doc)
+	}
+
+	return model.BulkResult{Indexed: len(stored)}, nil
+}
+
+// indexOf returns the position of the document with the given _id in index,
+// or -1. Like Elasticsearch, indexing an existing _id replaces the document.
+func (r *solarMemory) indexOf(index, id string) int {
+	for i, doc := range r.indices[index] {
+		if doc.id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// UpsertSiteStation replaces the site with the same SiteID. SiteItem has no
+// omitempty fields, so a partial update of Elasticsearch overwrites every
+// field as well.
+func (r *solarMemory) UpsertSiteStation(docs []model.SiteItem) (model.BulkResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, doc := range docs {
+		r.sites[doc.SiteID] = doc
+	}
+
+	return model.BulkResult{Indexed: len(docs)}, nil
+}
+
+// GetPerformanceLow returns the daily buckets of plants whose best daily
+// production is at most capacity * efficiencyFactor * focusHour * thresholdPct.
+// Buckets without a daily production or a capacity are dropped, as the
+// bucket selector of Elasticsearch skips gaps.
+func (r *solarMemory) GetPerformanceLow(duration int, efficiencyFactor float64, focusHour int, thresholdPct float64) ([]*elastic.AggregationBucketCompositeItem, error) {
+	buckets := r.dailyPlantBuckets(duration)
+
+	items := make([]*elastic.AggregationBucketCompositeItem, 0, len(buckets))
+	for _, bucket := range buckets {
+		if bucket.maxDaily == nil || bucket.avgCapacity == nil {
+			continue
+		}
+
+		threshold := *bucket.avgCapacity * efficiencyFactor * float64(focusHour) * thresholdPct
+		if *bucket.maxDaily > threshold {
+			continue
+		}
+
+		item, err := bucket.compositeItem(map[string]interface{}{"threshold_percentage": map[string]interface{}{"value": threshold}})
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (r *solarMemory) GetSumPerformanceLow(duration int) ([]*elastic.AggregationBucketCompositeItem, error) {
+	buckets := r.dailyPlantBuckets(duration)
+
+	items := make([]*elastic.AggregationBucketCompositeItem, 0, len(buckets))
+	for _, bucket := range buckets {
+		item, err := bucket.compositeItem(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetUniquePlantByIndex groups the plant documents of index by name, most
+// documents first, like a terms aggregation on name.keyword.
+func (r *solarMemory) GetUniquePlantByIndex(index string) ([]*elastic.AggregationBucketKeyItem, error) {
+	type group struct {
+		name  string
+		count int64
+		first *memoryDocument
+	}
+
+	groups := make(map[string]*group)
+	for _, doc := range r.search(index) {
+		if !isPlantDocument(doc) {
+			continue
+		}
+
+		name, ok := doc.source["name"].(string)
+		if !ok {
+			continue
+		}
+
+		if g, found := groups[name]; found {
+			g.count++
+		} else {
+			groups[name] = &group{name: name, count: 1, first: doc}
+		}
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+
+		return sorted[i].name < sorted[j].name
+	})
+
+	items := make([]*elastic.AggregationBucketKeyItem, 0, len(sorted))
+	for _, g := range sorted {
+		raw, err := json.Marshal(map[string]interface{}{
+			"key":       g.name,
+			"doc_count": g.count,
+			"data":      topHits(g.count, g.first, uniquePlantHitFields),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		item := new(elastic.AggregationBucketKeyItem)
+		if err := json.Unmarshal(raw, item); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// GetPerformanceAlarm returns every document of index. A missing index
+// yields no items, as the scroll of the Elasticsearch implementation does.
+func (r *solarMemory) GetPerformanceAlarm(index string) ([]*model.SnmpPerformanceAlarmItem, error) {
+	items := make([]*model.SnmpPerformanceAlarmItem, 0)
+	for _, doc := range r.search(index) {
+		item := &model.SnmpPerformanceAlarmItem{}
+		buf, _ := json.Marshal(doc.source)
+		if err := json.Unmarshal(buf, &item); err != nil {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// search returns the documents of the indices matching pattern, which may
+// contain wildcards like an Elasticsearch index expression.
+func (r *solarMemory) search(pattern string) []*memoryDocument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	indices := make([]string, 0)
+	for index := range r.indices {
+		if matched, _ := path.Match(pattern, index); matched {
+			indices = append(indices, index)
+		}
+	}
+	sort.Strings(indices)
+
+	docs := make([]*memoryDocument, 0)
+	for _, index := range indices {
+		docs = append(docs, r.indices[index]...)
+	}
+
+	return docs
+}
+
+type dailyPlantBucket struct {
+	date        string
+	vendorType  string
+	id          string
+	count       int64
+	maxDaily    *float64
+	avgCapacity *float64
+	first       *memoryDocument
+	capacitySum float64
+	capacityN   int
+}
+
+func (b *dailyPlantBucket) compositeItem(extra map[string]interface{}) (*elastic.AggregationBucketCompositeItem, error) {
+	bucket := map[string]interface{}{
+		"key":          map[string]interface{}{"date": b.date, "vendor_type": b.vendorType, "id": b.id},
+		"doc_count":    b.count,
+		"max_daily":    map[string]interface{}{"value": b.maxDaily},
+		"avg_capacity": map[string]interface{}{"value": b.avgCapacity},
+		"hits":         topHits(b.count, b.first, performanceHitFields),
+	}
+
+	for name, agg := range extra {
+		bucket[name] = agg
+	}
+
+	raw, err := json.Marshal(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggs elastic.Aggregations
+	if err := json.Unmarshal(raw, &aggs); err != nil {
+		return nil, err
+	}
+
+	return &elastic.AggregationBucketCompositeItem{
+		Aggregations: aggs,
+		Key:          bucket["key"].(map[string]interface{}),
+		DocCount:     b.count,
+	}, nil
+}
+
+// dailyPlantBuckets groups the plant documents of the solar indices from
+// duration days ago up to the end of yesterday by day, vendor type and plant
+// ID, ordered by those keys like a composite aggregation.
+func (r *solarMemory) dailyPlantBuckets(duration int) []*dailyPlantBucket {
+	r.mu.RLock()
+	today := r.now().UTC().Truncate(24 * time.Hour)
+	r.mu.RUnlock()
+
+	from := today.AddDate(0, 0, -duration)
+	buckets := make(map[string]*dailyPlantBucket)
+	for _, doc := range r.search(fmt.Sprintf("%v*", model.SolarIndex)) {
+		if !isPlantDocument(doc) {
+			continue
+		}
+
+		timestamp, ok := sourceTime(doc.source, "@timestamp")
+		if !ok || timestamp.Before(from) || !timestamp.Before(today) {
+			continue
+		}
+
+		vendorType, ok := doc.source["vendor_type"].(string)
+		if !ok {
+			continue
+		}
+
+		id, ok := doc.source["id"].(string)
+		if !ok {
+			continue
+		}
+
+		date := timestamp.UTC().Format("2006-01-02")
+		key := strings.Join([]string{date, vendorType, id}, "\x00")
+		bucket, found := buckets[key]
+		if !found {
+			bucket = &dailyPlantBucket{date: date, vendorType: vendorType, id: id, first: doc}
+			buckets[key] = bucket
+		}
+
+		bucket.count++
+		if daily, ok := doc.source["daily_production"].(float64); ok {
+			if bucket.maxDaily == nil || daily > *bucket.maxDaily {
+				bucket.maxDaily = &daily
+			}
+		}
+
+		if capacity, ok := doc.source["installed_capacity"].(float64); ok {
+			bucket.capacitySum += capacity
+			bucket.capacityN++
+			avg := bucket.capacitySum / float64(bucket.capacityN)
+			bucket.avgCapacity = &avg
+		}
+	}
+
+	sorted := make([]*dailyPlantBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		sorted = append(sorted, bucket)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.date != b.date {
+			return a.date < b.date
+		}
+
+		if a.vendorType != b.vendorType {
+			return a.vendorType < b.vendorType
+		}
+
+		return a.id < b.id
+	})
+
+	return sorted
+}
+
+func isPlantDocument(doc *memoryDocument) bool {
+	dataType, _ := doc.source["data_type"].(string)
+	return strings.EqualFold(dataType, model.DataTypePlant)
+}
+
+func sourceTime(source map[string]interface{}, field string) (time.Time, bool) {
+	value, ok := source[field].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return parsed, true
+}
+
+// topHits renders a size 1 top hits aggregation of doc restricted to fields.
+func topHits(total int64, doc *memoryDocument, fields []string) map[string]interface{} {
+	source := make(map[string]interface{})
+	for _, field := range fields {
+		if value, ok := doc.source[field]; ok {
+			source[field] = value
+		}
+	}
+
+	score := 1.0
+	return map[string]interface{}{
+		"hits": map[string]interface{}{
+			"total":     map[string]interface{}{"value": total, "relation": "eq"},
+			"max_score": score,
+			"hits": []map[string]interface{}{{
+				"_index":  doc.index,
+				"_id":     doc.id,
+				"_score":  score,
+				"_source": source,
+			}},
+		},
+	}
+}
+
+// BulkConfig returns the default bounds, the memory repo does not split
+// batches itself.
+func (r *solarMemory) BulkConfig() config.BulkConfig {
+	return config.BulkConfig{}.WithDefaults()
+}
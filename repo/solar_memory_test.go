@@ -0,0 +1,264 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/olivere/elastic/v7"
+	"go.openly.dev/pointy"
+)
+
+// memoryNow is the clock of the memory repo in the tests, the performance
+// queries cover the days before 2024-01-15.
+var memoryNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func memoryPlant(day int, vendorType, id, name string, daily, capacity *float64) model.PlantItem {
+	return model.PlantItem{
+		Timestamp:         time.Date(2024, 1, day, 9, 0, 0, 0, time.UTC),
+		VendorType:        vendorType,
+		DataType:          model.DataTypePlant,
+		ID:                pointy.String(id),
+		Name:              pointy.String(name),
+		Area:              "BMA",
+		DailyProduction:   daily,
+		InstalledCapacity: capacity,
+		Owner:             string(model.OwnerTrue),
+	}
+}
+
+func newPerformanceMemoryRepo(t *testing.T) *solarMemory {
+	t.Helper()
+	r := NewSolarMemoryRepo()
+	r.SetNow(func() time.Time { return memoryNow })
+
+	docs := map[int][]interface{}{
+		11: {memoryPlant(11, model.VendorTypeGrowatt, "G1", "BKK01", pointy.Float64(5), pointy.Float64(10))},
+		12: {
+			memoryPlant(12, model.VendorTypeGrowatt, "G1", "BKK01", pointy.Float64(5), pointy.Float64(10)),
+			memoryPlant(12, model.VendorTypeKstar, "K1", "CNX01", pointy.Float64(30), pointy.Float64(10)),
+		},
+		13: {
+			memoryPlant(13, model.VendorTypeGrowatt, "G1", "BKK01", pointy.Float64(19.2), pointy.Float64(10)),
+			memoryPlant(13, model.VendorTypeKstar, "K1", "CNX01", pointy.Float64(12), pointy.Float64(10)),
+			memoryPlant(13, model.VendorTypeHuawei, "H1", "KKN01", nil, pointy.Float64(20)),
+			model.DeviceItem{
+				Timestamp:  time.Date(2024, 1, 13, 9, 0, 0, 0, time.UTC),
+				VendorType: model.VendorTypeGrowatt,
+				DataType:   model.DataTypeDevice,
+				ID:         pointy.String("INV1"),
+				PlantID:    pointy.String("G1"),
+			},
+		},
+		15: {memoryPlant(15, model.VendorTypeGrowatt, "G1", "BKK01", pointy.Float64(1), pointy.Float64(10))},
+	}
+
+	for day, items := range docs {
+		index := fmt.Sprintf("%s-2024.01.%02d", model.SolarIndex, day)
+		if _, err := r.BulkIndex(index, items); err != nil {
+			t.Fatalf("BulkIndex(%s) error = %v", index, err)
+		}
+	}
+
+	return r
+}
+
+// compositeBucket is the part of a composite bucket the alarms read.
+type compositeBucket struct {
+	Key       map[string]interface{}
+	DocCount  int64
+	MaxDaily  *float64
+	Capacity  *float64
+	Threshold *float64
+	Name      string
+}
+
+func readCompositeBuckets(t *testing.T, items []*elastic.AggregationBucketCompositeItem) []compositeBucket {
+	t.Helper()
+	buckets := make([]compositeBucket, 0, len(items))
+	for _, item := range items {
+		bucket := compositeBucket{Key: item.Key, DocCount: item.DocCount}
+		if agg, ok := item.ValueCount("max_daily"); ok {
+			bucket.MaxDaily = agg.Value
+		}
+
+		if agg, ok := item.ValueCount("avg_capacity"); ok {
+			bucket.Capacity = agg.Value
+		}
+
+		if agg, ok := item.ValueCount("threshold_percentage"); ok {
+			bucket.Threshold = agg.Value
+		}
+
+		hits, ok := item.TopHits("hits")
+		if !ok || hits.Hits == nil || len(hits.Hits.Hits) != 1 {
+			t.Fatalf("bucket %v has no top hit", item.Key)
+		}
+
+		var plant model.PlantItem
+		if err := json.Unmarshal(hits.Hits.Hits[0].Source, &plant); err != nil {
+			t.Fatalf("top hit of bucket %v: %v", item.Key, err)
+		}
+
+		bucket.Name = pointy.StringValue(plant.Name, "")
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets
+}
+
+func bucketKey(date, vendorType, id string) map[string]interface{} {
+	return map[string]interface{}{"date": date, "vendor_type": vendorType, "id": id}
+}
+
+func TestSolarMemoryGetPerformanceLow(t *testing.T) {
+	r := newPerformanceMemoryRepo(t)
+
+	// capacity 10 * efficiency 0.8 * 4 hours * 60% allows at most 19.2 kWh.
+	items, err := r.GetPerformanceLow(3, 0.8, 4, 0.6)
+	if err != nil {
+		t.Fatalf("GetPerformanceLow() error = %v", err)
+	}
+
+	want := []compositeBucket{
+		{Key: bucketKey("2024-01-12", model.VendorTypeGrowatt, "G1"), DocCount: 1, MaxDaily: pointy.Float64(5), Capacity: pointy.Float64(10), Threshold: pointy.Float64(19.2), Name: "BKK01"},
+		{Key: bucketKey("2024-01-13", model.VendorTypeGrowatt, "G1"), DocCount: 1, MaxDaily: pointy.Float64(19.2), Capacity: pointy.Float64(10), Threshold: pointy.Float64(19.2), Name: "BKK01"},
+		{Key: bucketKey("2024-01-13", model.VendorTypeKstar, "K1"), DocCount: 1, MaxDaily: pointy.Float64(12), Capacity: pointy.Float64(10), Threshold: pointy.Float64(19.2), Name: "CNX01"},
+	}
+
+	if got := readCompositeBuckets(t, items); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPerformanceLow() = %s, want %s", formatBuckets(got), formatBuckets(want))
+	}
+}
+
+func TestSolarMemoryGetSumPerformanceLow(t *testing.T) {
+	r := newPerformanceMemoryRepo(t)
+
+	items, err := r.GetSumPerformanceLow(3)
+	if err != nil {
+		t.Fatalf("GetSumPerformanceLow() error = %v", err)
+	}
+
+	want := []compositeBucket{
+		{Key: bucketKey("2024-01-12", model.VendorTypeGrowatt, "G1"), DocCount: 1, MaxDaily: pointy.Float64(5), Capacity: pointy.Float64(10), Name: "BKK01"},
+		{Key: bucketKey("2024-01-12", model.VendorTypeKstar, "K1"), DocCount: 1, MaxDaily: pointy.Float64(30), Capacity: pointy.Float64(10), Name: "CNX01"},
+		{Key: bucketKey("2024-01-13", model.VendorTypeGrowatt, "G1"), DocCount: 1, MaxDaily: pointy.Float64(19.2), Capacity: pointy.Float64(10), Name: "BKK01"},
+		{Key: bucketKey("2024-01-13", model.VendorTypeHuawei, "H1"), DocCount: 1, Capacity: pointy.Float64(20), Name: "KKN01"},
+		{Key: bucketKey("2024-01-13", model.VendorTypeKstar, "K1"), DocCount: 1, MaxDaily: pointy.Float64(12), Capacity: pointy.Float64(10), Name: "CNX01"},
+	}
+
+	if got := readCompositeBuckets(t, items); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSumPerformanceLow() = %s, want %s", formatBuckets(got), formatBuckets(want))
+	}
+}
+
+func TestSolarMemoryGetSumPerformanceLowMergesIndices(t *testing.T) {
+	r := NewSolarMemoryRepo()
+	r.SetNow(func() time.Time { return memoryNow })
+
+	// A late collect of the 14th lands in the index of the 15th, the bucket
+	// keeps the best production and averages the capacity.
+	docs := map[string]model.PlantItem{
+		"solarcell-2024.01.14": memoryPlant(14, model.VendorTypeGrowatt, "G1", "BKK01", pointy.Float64(8), pointy.Float64(10)),
+		"solarcell-2024.01.15": memoryPlant(14, model.VendorTypeGrowatt, "G1", "BKK01", pointy.Float64(6), pointy.Float64(20)),
+	}
+	for index, doc := range docs {
+		if _, err := r.BulkIndex(index, []interface{}{doc}); err != nil {
+			t.Fatalf("BulkIndex(%s) error = %v", index, err)
+		}
+	}
+
+	items, err := r.GetSumPerformanceLow(1)
+	if err != nil {
+		t.Fatalf("GetSumPerformanceLow() error = %v", err)
+	}
+
+	want := []compositeBucket{
+		{Key: bucketKey("2024-01-14", model.VendorTypeGrowatt, "G1"), DocCount: 2, MaxDaily: pointy.Float64(8), Capacity: pointy.Float64(15), Name: "BKK01"},
+	}
+
+	if got := readCompositeBuckets(t, items); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSumPerformanceLow() = %s, want %s", formatBuckets(got), formatBuckets(want))
+	}
+}
+
+func TestSolarMemoryGetUniquePlantByIndex(t *testing.T) {
+	r := NewSolarMemoryRepo()
+	index := "solarcell-2024.01.14"
+	docs := []interface{}{
+		memoryPlant(14, model.VendorTypeSolarman, "S2", "CNX02", nil, pointy.Float64(5)),
+		memoryPlant(14, model.VendorTypeGrowatt, "G1", "BKK01", nil, pointy.Float64(10)),
+		memoryPlant(14, model.VendorTypeGrowatt, "G2", "BKK01", nil, pointy.Float64(10)),
+		memoryPlant(14, model.VendorTypeHuawei, "H1", "AYA01", nil, pointy.Float64(20)),
+		model.DeviceItem{Timestamp: time.Date(2024, 1, 14, 9, 0, 0, 0, time.UTC), DataType: model.DataTypeDevice, ID: pointy.String("INV1"), Name: pointy.String("BKK01")},
+	}
+	if _, err := r.BulkIndex(index, docs); err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	if _, err := r.BulkIndex("solarcell-2024.01.13", []interface{}{memoryPlant(13, model.VendorTypeKstar, "K1", "CNX01", nil, nil)}); err != nil {
+		t.Fatalf("BulkIndex() error = %v", err)
+	}
+
+	items, err := r.GetUniquePlantByIndex(index)
+	if err != nil {
+		t.Fatalf("GetUniquePlantByIndex() error = %v", err)
+	}
+
+	type plant struct {
+		Key      interface{}
+		DocCount int64
+		Source   map[string]interface{}
+	}
+
+	got := make([]plant, 0, len(items))
+	for _, item := range items {
+		hits, ok := item.TopHits("data")
+		if !ok || hits.Hits == nil || len(hits.Hits.Hits) != 1 {
+			t.Fatalf("bucket %v has no top hit", item.Key)
+		}
+
+		source := make(map[string]interface{})
+		if err := json.Unmarshal(hits.Hits.Hits[0].Source, &source); err != nil {
+			t.Fatalf("top hit of bucket %v: %v", item.Key, err)
+		}
+
+		got = append(got, plant{Key: item.Key, DocCount: item.DocCount, Source: source})
+	}
+
+	// Most documents first then by name, the hit keeps the first document
+	// of the group and only the fields ClearAlarm reads.
+	want := []plant{
+		{Key: "BKK01", DocCount: 2, Source: map[string]interface{}{"name": "BKK01", "area": "BMA", "vendor_type": model.VendorTypeGrowatt, "installed_capacity": 10.0, "location": nil, "owner": string(model.OwnerTrue)}},
+		{Key: "AYA01", DocCount: 1, Source: map[string]interface{}{"name": "AYA01", "area": "BMA", "vendor_type": model.VendorTypeHuawei, "installed_capacity": 20.0, "location": nil, "owner": string(model.OwnerTrue)}},
+		{Key: "CNX02", DocCount: 1, Source: map[string]interface{}{"name": "CNX02", "area": "BMA", "vendor_type": model.VendorTypeSolarman, "installed_capacity": 5.0, "location": nil, "owner": string(model.OwnerTrue)}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUniquePlantByIndex() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSolarMemoryGetUniquePlantByIndexMissing(t *testing.T) {
+	items, err := NewSolarMemoryRepo().GetUniquePlantByIndex("solarcell-2024.01.14")
+	if err != nil {
+		t.Fatalf("GetUniquePlantByIndex() error = %v", err)
+	}
+
+	if len(items) != 0 {
+		t.Errorf("GetUniquePlantByIndex() = %v, want no buckets", items)
+	}
+}
+
+func formatBuckets(buckets []compositeBucket) string {
+	out := ""
+	for _, b := range buckets {
+		out += fmt.Sprintf("\n\t%v count=%d max=%v capacity=%v threshold=%v name=%s",
+			b.Key, b.DocCount, pointy.Float64Value(b.MaxDaily, -1), pointy.Float64Value(b.Capacity, -1), pointy.Float64Value(b.Threshold, -1), b.Name)
+	}
+
+	return out
+}
@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"github.com/HavvokLab/true-solar/config"
 	"github.com/HavvokLab/true-solar/model"
 	"github.com/olivere/elastic/v7"
 )
@@ -12,12 +13,16 @@ func NewSolarMockRepo() *solarMock {
 	return &solarMock{}
 }
 
-func (r *solarMock) BulkIndex(index string, docs []interface{}) error {
-	return nil
+func (r *solarMock) BulkConfig() config.BulkConfig {
+	return config.BulkConfig{}.WithDefaults()
 }
 
-func (r *solarMock) UpsertSiteStation(docs []model.SiteItem) error {
-	return nil
+func (r *solarMock) BulkIndex(index string, docs []interface{}) (model.BulkResult, error) {
+	return model.BulkResult{Indexed: len(docs)}, nil
+}
+
+func (r *solarMock) UpsertSiteStation(docs []model.SiteItem) (model.BulkResult, error) {
+	return model.BulkResult{Indexed: len(docs)}, nil
 }
 
 func (r *solarMock) GetPerformanceLow(duration int, efficiencyFactor float64, focusHour int, thresholdPct float64) ([]*elastic.AggregationBucketCompositeItem, error) {
@@ -2,22 +2,25 @@ package repo
 
 import (
 	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/secret"
 	"gorm.io/gorm"
 )
 
 type SolarmanCredentialRepo interface {
 	FindAll() ([]model.SolarmanCredential, error)
+	FindByID(id int64) (*model.SolarmanCredential, error)
 	Create(credential *model.SolarmanCredential) error
 	Update(id int64, credential *model.SolarmanCredential) error
 	Delete(id int64) error
 }
 
 type solarmanCredentialRepo struct {
-	db *gorm.DB
+	db     *gorm.DB
+	cipher *secret.Cipher
 }
 
-func NewSolarmanCredentialRepo(db *gorm.DB) SolarmanCredentialRepo {
-	return &solarmanCredentialRepo{db: db}
+func NewSolarmanCredentialRepo(db *gorm.DB, cipher *secret.Cipher) SolarmanCredentialRepo {
+	return &solarmanCredentialRepo{db: db, cipher: cipher}
 }
 
 func (r *solarmanCredentialRepo) FindAll() ([]model.SolarmanCredential, error) {
@@ -27,10 +30,36 @@ func (r *solarmanCredentialRepo) FindAll() ([]model.SolarmanCredential, error) {
 		return nil, err
 	}
 
+	for i := range credentials {
+		if err := decryptSecrets(r.cipher, &credentials[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	return credentials, nil
 }
 
+func (r *solarmanCredentialRepo) FindByID(id int64) (*model.SolarmanCredential, error) {
+	var credential model.SolarmanCredential
+	tx := r.db.Session(&gorm.Session{})
+	if err := tx.First(&credential, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := decryptSecrets(r.cipher, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
 func (r *solarmanCredentialRepo) Create(credential *model.SolarmanCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
 	if err := tx.Create(credential).Error; err != nil {
 		return err
@@ -39,9 +68,18 @@ func (r *solarmanCredentialRepo) Create(credential *model.SolarmanCredential) er
 	return nil
 }
 
+// Update replaces the credential with the given id, emptied fields included.
 func (r *solarmanCredentialRepo) Update(id int64, credential *model.SolarmanCredential) error {
+	restore, err := encryptSecrets(r.cipher, credential)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
 	tx := r.db.Session(&gorm.Session{})
-	if err := tx.Where("id = ?", id).Updates(credential).Error; err != nil {
+	if err := tx.Model(&model.SolarmanCredential{}).Where("id = ?", id).
+		Select("username", "password", "app_secret", "app_id", "owner", "updated_at").
+		Updates(credential).Error; err != nil {
 		return err
 	}
 
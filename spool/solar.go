@@ -0,0 +1,42 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+// spoolingSolarRepo wraps a SolarRepo and spools the documents it fails to
+// index. The error is still returned, so the job is recorded as failed while
+// its documents wait for the next replay.
+type spoolingSolarRepo struct {
+	repo.SolarRepo
+	spool *Spool
+}
+
+func NewSpoolingSolarRepo(solarRepo repo.SolarRepo, spool *Spool) *spoolingSolarRepo {
+	return &spoolingSolarRepo{SolarRepo: solarRepo, spool: spool}
+}
+
+// BulkIndex spools the documents left pending by connection errors or a busy
+// cluster. Documents rejected for good are in the dead-letter index already
+// and are not spooled, sending them again would fail the same way.
+func (r *spoolingSolarRepo) BulkIndex(index string, docs []interface{}) (model.BulkResult, error) {
+	result, err := r.SolarRepo.BulkIndex(index, docs)
+	if err == nil || len(result.Pending) == 0 {
+		return result, err
+	}
+
+	pending := make([]interface{}, 0, len(result.Pending))
+	for _, position := range result.Pending {
+		pending = append(pending, docs[position])
+	}
+
+	if spoolErr := r.spool.Write(index, pending); spoolErr != nil {
+		return result, errors.Join(err, spoolErr)
+	}
+
+	return result, fmt.Errorf("%w (%d documents spooled)", err, len(pending))
+}
@@ -0,0 +1,460 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/pkg/logger"
+	"github.com/HavvokLab/true-solar/pkg/metrics"
+	"github.com/HavvokLab/true-solar/repo"
+	"github.com/rs/zerolog"
+)
+
+// Spool file suffixes. Documents are appended to <index>.jsonl, a replay
+// renames the file to <index>.jsonl.<unix nano>.replay first so writes during
+// the replay go to a new file. Lines failing their checksum are moved to
+// <index>.jsonl.corrupt for inspection, a replay file that cannot be replayed
+// is renamed to <name>.corrupt as a whole. A replay file is rewritten through
+// <name>.tmp.
+const (
+	spoolSuffix   = ".jsonl"
+	replaySuffix  = ".replay"
+	corruptSuffix = ".corrupt"
+	tmpSuffix     = ".tmp"
+)
+
+// replayBatchSize is the number of documents indexed per BulkIndex call.
+const replayBatchSize = 1000
+
+// maxLineSize is the longest spool line read back, one document.
+const maxLineSize = 16 << 20
+
+var ErrFull = errors.New("spool is full")
+
+// errQuarantine marks a replay file holding documents that were neither
+// indexed, dead-lettered nor left pending, replaying it again would not help.
+var errQuarantine = errors.New("spool file quarantined")
+
+// record is one line of a spool file. ID is the deterministic _id of the
+// document, if any, and Checksum the SHA-256 of Doc.
+type record struct {
+	ID        string          `json:"id,omitempty"`
+	Checksum  string          `json:"checksum"`
+	SpooledAt time.Time       `json:"spooled_at"`
+	Doc       json.RawMessage `json:"doc"`
+}
+
+// document replays a spooled source under its original _id.
+type document struct {
+	id     string
+	source json.RawMessage
+}
+
+func (d document) DocumentID() string {
+	return d.id
+}
+
+func (d document) MarshalJSON() ([]byte, error) {
+	return d.source, nil
+}
+
+// File is a spool file on disk.
+type File struct {
+	Name    string
+	Index   string
+	Bytes   int64
+	ModTime time.Time
+	Corrupt bool
+}
+
+// ReplayResult counts the lines of the replayed files. Kept counts the
+// documents left pending by Elasticsearch, kept for the next replay, and
+// Quarantined the files renamed to .corrupt.
+type ReplayResult struct {
+	Files       int
+	Documents   int
+	Duplicates  int
+	Corrupt     int
+	Kept        int
+	Quarantined int
+}
+
+// Spool keeps batches of documents that could not be indexed in JSONL files,
+// one per index, until they are replayed. Writes are appended and synced, a
+// line cut short by a crash fails its checksum on replay.
+type Spool struct {
+	conf     config.SpoolConfig
+	mu       sync.Mutex
+	replayMu sync.Mutex
+	logger   zerolog.Logger
+}
+
+func NewSpool(conf config.SpoolConfig) *Spool {
+	return &Spool{
+		conf:   conf.WithDefaults(),
+		logger: zerolog.New(logger.NewWriter("spool.log")).With().Timestamp().Caller().Logger(),
+	}
+}
+
+// Write appends docs to the spool file of index. It fails with ErrFull when
+// the batch would grow the spool beyond MaxBytes, the batch is then dropped.
+func (s *Spool) Write(index string, docs []interface{}) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	buf, err := encode(index, docs, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size, err := s.size()
+	if err != nil {
+		return err
+	}
+
+	if size+int64(buf.Len()) > s.conf.MaxBytes {
+		metrics.AddSpoolDocuments(index, metrics.SpoolResultDropped, len(docs))
+		s.logger.Error().Str("index", index).Int("documents", len(docs)).Int64("size", size).Int64("max_bytes", s.conf.MaxBytes).Msg("Spool::Write() - spool is full, documents dropped")
+		return fmt.Errorf("%w: %d documents of index %s dropped", ErrFull, len(docs), index)
+	}
+
+	if err := os.MkdirAll(s.conf.Dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.conf.Dir, index+spoolSuffix), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	size += int64(buf.Len())
+	metrics.SetSpoolSize(size)
+	metrics.AddSpoolDocuments(index, metrics.SpoolResultSpooled, len(docs))
+	s.logger.Info().Str("index", index).Int("documents", len(docs)).Int64("size", size).Msg("Spool::Write() - documents spooled")
+	if size > s.conf.WarnBytes {
+		s.logger.Warn().Int64("size", size).Int64("warn_bytes", s.conf.WarnBytes).Msg("Spool::Write() - spool is growing, check elasticsearch")
+	}
+
+	return nil
+}
+
+// Replay indexes the spooled documents with solarRepo, which must not spool
+// itself, and removes the files replayed completely. Documents spooled several
+// times are indexed once, the last spooled version wins. A failing file does
+// not stop the replay: the documents left pending are rewritten to it for the
+// next replay, documents rejected for good are in the dead-letter index, and
+// a file that cannot be read or whose documents would be lost otherwise is
+// quarantined as .corrupt. The errors of the files are returned joined.
+// Replays do not overlap, a call during a replay returns right away.
+func (s *Spool) Replay(solarRepo repo.SolarRepo) (ReplayResult, error) {
+	var result ReplayResult
+	if !s.replayMu.TryLock() {
+		return result, nil
+	}
+	defer s.replayMu.Unlock()
+
+	files, err := s.claim()
+	if err != nil {
+		return result, err
+	}
+
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if size, err := s.size(); err == nil {
+			metrics.SetSpoolSize(size)
+		}
+	}()
+
+	var errs []error
+	for _, file := range files {
+		err := s.replayFile(solarRepo, file, &result)
+		if err == nil {
+			continue
+		}
+
+		s.logger.Error().Err(err).Str("file", file).Msg("Spool::Replay() - failed to replay file")
+		if errors.Is(err, errQuarantine) {
+			if renameErr := os.Rename(file, file+corruptSuffix); renameErr != nil {
+				err = errors.Join(err, renameErr)
+			} else {
+				result.Quarantined++
+			}
+		}
+
+		errs = append(errs, fmt.Errorf("replay %s: %w", filepath.Base(file), err))
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// Files lists the spool files, pending, being replayed, corrupt and
+// quarantined.
+func (s *Spool) Files() ([]File, error) {
+	entries, err := os.ReadDir(s.conf.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(entries))
+	for _, entry := range entries {
+		index, ok := indexOf(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, File{
+			Name:    entry.Name(),
+			Index:   index,
+			Bytes:   info.Size(),
+			ModTime: info.ModTime(),
+			Corrupt: strings.HasSuffix(entry.Name(), corruptSuffix),
+		})
+	}
+
+	return files, nil
+}
+
+// claim renames the pending files for replay and returns them together with
+// those left by earlier replays, sorted by name so the files of an index are
+// replayed oldest first.
+func (s *Spool) claim() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, err := filepath.Glob(filepath.Join(s.conf.Dir, "*"+spoolSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range pending {
+		if err := os.Rename(file, fmt.Sprintf("%s.%d%s", file, time.Now().UnixNano(), replaySuffix)); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.conf.Dir, "*"+spoolSuffix+".*"+replaySuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *Spool) replayFile(solarRepo repo.SolarRepo, file string, result *ReplayResult) error {
+	index, _ := indexOf(filepath.Base(file))
+	docs, corruptLines, duplicates, err := s.read(file)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errQuarantine, err)
+	}
+
+	kept := make([]interface{}, 0)
+	var errs []error
+	for start := 0; start < len(docs); start += replayBatchSize {
+		end := min(start+replayBatchSize, len(docs))
+		batchResult, err := solarRepo.BulkIndex(index, docs[start:end])
+		if err != nil {
+			if batchResult.Failed > batchResult.DeadLettered || (len(batchResult.Pending) == 0 && batchResult.Failed == 0) {
+				return fmt.Errorf("%w: %w", errQuarantine, err)
+			}
+
+			for _, position := range batchResult.Pending {
+				kept = append(kept, docs[start+position])
+			}
+			errs = append(errs, err)
+		}
+
+		metrics.AddSpoolDocuments(index, metrics.SpoolResultReplayed, end-start-len(batchResult.Pending))
+	}
+
+	// Corrupt lines are moved once the file is replayed, a file retried later
+	// would move them again.
+	corrupt := bytes.Count(corruptLines, []byte{'\n'})
+	if corrupt > 0 {
+		s.logger.Warn().Str("file", file).Int("lines", corrupt).Msg("Spool::Replay() - corrupt lines skipped")
+		if err := appendFile(filepath.Join(s.conf.Dir, index+spoolSuffix+corruptSuffix), corruptLines); err != nil {
+			return err
+		}
+	}
+
+	result.Documents += len(docs) - len(kept)
+	result.Duplicates += duplicates
+	result.Corrupt += corrupt
+	if len(kept) > 0 {
+		if err := s.rewrite(file, index, kept); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		result.Kept += len(kept)
+		s.logger.Warn().Str("file", file).Str("index", index).Int("documents", len(kept)).Msg("Spool::Replay() - documents kept for the next replay")
+		return fmt.Errorf("%d documents kept for the next replay: %w", len(kept), errors.Join(errs...))
+	}
+
+	result.Files++
+	s.logger.Info().Str("file", file).Str("index", index).Int("documents", len(docs)).Int("duplicates", duplicates).Int("corrupt", corrupt).Msg("Spool::Replay() - file replayed")
+	return os.Remove(file)
+}
+
+// rewrite replaces the content of a replay file with docs, through a
+// temporary file so a crash leaves either version.
+func (s *Spool) rewrite(file string, index string, docs []interface{}) error {
+	buf, err := encode(index, docs, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tmp := file + tmpSuffix
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// read returns the documents of a spool file deduplicated by ID, or by
+// checksum for documents without one, and the lines failing their checksum.
+func (s *Spool) read(file string) ([]interface{}, []byte, int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer f.Close()
+
+	var corruptLines bytes.Buffer
+	positions := make(map[string]int)
+	docs := make([]interface{}, 0)
+	duplicates := 0
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Checksum != checksum(rec.Doc) {
+			corruptLines.Write(line)
+			corruptLines.WriteByte('\n')
+			continue
+		}
+
+		key := "id:" + rec.ID
+		if rec.ID == "" {
+			key = "checksum:" + rec.Checksum
+		}
+
+		doc := document{id: rec.ID, source: rec.Doc}
+		if position, ok := positions[key]; ok {
+			docs[position] = doc
+			duplicates++
+			continue
+		}
+
+		positions[key] = len(docs)
+		docs = append(docs, doc)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, 0, err
+	}
+
+	return docs, corruptLines.Bytes(), duplicates, nil
+}
+
+// encode returns the spool lines of docs.
+func encode(index string, docs []interface{}, now time.Time) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, doc := range docs {
+		source, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("marshal document of index %s: %w", index, err)
+		}
+
+		line := record{Checksum: checksum(source), SpooledAt: now, Doc: source}
+		if identifiable, ok := doc.(model.Identifiable); ok {
+			line.ID = identifiable.DocumentID()
+		}
+
+		if err := encoder.Encode(line); err != nil {
+			return nil, err
+		}
+	}
+
+	return &buf, nil
+}
+
+// size is the total size of the spool files. The caller holds mu.
+func (s *Spool) size() (int64, error) {
+	files, err := s.Files()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, file := range files {
+		size += file.Bytes
+	}
+
+	return size, nil
+}
+
+// indexOf returns the index a spool file belongs to.
+func indexOf(name string) (string, bool) {
+	index, _, ok := strings.Cut(name, spoolSuffix)
+	return index, ok && index != ""
+}
+
+func checksum(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
+
+func appendFile(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
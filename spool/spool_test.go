@@ -0,0 +1,319 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HavvokLab/true-solar/config"
+	"github.com/HavvokLab/true-solar/model"
+	"github.com/HavvokLab/true-solar/repo"
+)
+
+type testDoc struct {
+	ID    string `json:"id"`
+	Value int    `json:"value"`
+}
+
+func (d testDoc) DocumentID() string {
+	return d.ID
+}
+
+// fakeSolarRepo records the IDs of the documents indexed per index, all but
+// the pending ones of the result of index when set.
+type fakeSolarRepo struct {
+	repo.SolarRepo
+	index func(index string, docs []interface{}) (model.BulkResult, error)
+
+	mu      sync.Mutex
+	indexed map[string][]string
+}
+
+func newFakeSolarRepo() *fakeSolarRepo {
+	return &fakeSolarRepo{SolarRepo: repo.NewSolarMockRepo(), indexed: make(map[string][]string)}
+}
+
+func (r *fakeSolarRepo) BulkIndex(index string, docs []interface{}) (model.BulkResult, error) {
+	result := model.BulkResult{Indexed: len(docs)}
+	var err error
+	if r.index != nil {
+		result, err = r.index(index, docs)
+	}
+
+	if err != nil && len(result.Pending) == 0 {
+		return result, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := make(map[int]bool, len(result.Pending))
+	for _, position := range result.Pending {
+		pending[position] = true
+	}
+
+	for i, doc := range docs {
+		if !pending[i] {
+			r.indexed[index] = append(r.indexed[index], doc.(model.Identifiable).DocumentID())
+		}
+	}
+
+	return result, err
+}
+
+func newTestSpool(t *testing.T, maxBytes int64) *Spool {
+	t.Helper()
+	return NewSpool(config.SpoolConfig{Dir: t.TempDir(), MaxBytes: maxBytes})
+}
+
+func docs(ids ...string) []interface{} {
+	list := make([]interface{}, 0, len(ids))
+	for i, id := range ids {
+		list = append(list, testDoc{ID: id, Value: i})
+	}
+
+	return list
+}
+
+func fileNames(t *testing.T, s *Spool) []string {
+	t.Helper()
+	files, err := s.Files()
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func readIDs(t *testing.T, s *Spool, file string) []string {
+	t.Helper()
+	read, corrupt, _, err := s.read(file)
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+
+	if len(corrupt) > 0 {
+		t.Fatalf("read() corrupt lines %q", corrupt)
+	}
+
+	ids := make([]string, 0, len(read))
+	for _, doc := range read {
+		ids = append(ids, doc.(model.Identifiable).DocumentID())
+	}
+
+	return ids
+}
+
+func TestSpoolWriteAndClaim(t *testing.T) {
+	s := newTestSpool(t, 0)
+	if err := s.Write("solarcell-2024.01.15", docs("a", "b")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	first, err := s.claim()
+	if err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+
+	if len(first) != 1 || !strings.HasSuffix(first[0], replaySuffix) {
+		t.Fatalf("claim() = %v, want one replay file", first)
+	}
+
+	// Writes after the claim go to a new file, claimed together with the
+	// replay file left behind.
+	if err := s.Write("solarcell-2024.01.15", docs("c")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if names := fileNames(t, s); len(names) != 2 || names[0] != "solarcell-2024.01.15.jsonl" {
+		t.Fatalf("Files() = %v, want the new file and the replay file", names)
+	}
+
+	second, err := s.claim()
+	if err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+
+	if len(second) != 2 || second[0] != first[0] {
+		t.Fatalf("claim() = %v, want %s first", second, first[0])
+	}
+
+	if ids := readIDs(t, s, second[1]); len(ids) != 1 || ids[0] != "c" {
+		t.Fatalf("second file holds %v, want c", ids)
+	}
+}
+
+func TestSpoolWriteFullDropsBatch(t *testing.T) {
+	s := newTestSpool(t, 400)
+	if err := s.Write("solarcell-2024.01.15", docs("a")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	before := fileNames(t, s)
+	err := s.Write("solarcell-2024.01.15", docs("b", "c", "d", "e"))
+	if !errors.Is(err, ErrFull) {
+		t.Fatalf("Write() error = %v, want ErrFull", err)
+	}
+
+	files, err := s.claim()
+	if err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+
+	if len(before) != 1 || len(files) != 1 {
+		t.Fatalf("files = %v, want the first file only", files)
+	}
+
+	if ids := readIDs(t, s, files[0]); len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("spool holds %v, want a", ids)
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	s := newTestSpool(t, 0)
+	if err := s.Write("solarcell-2024.01.15", docs("a", "b")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if err := s.Write("solarcell-2024.01.15", docs("b", "c")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	solarRepo := newFakeSolarRepo()
+	result, err := s.Replay(solarRepo)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if result.Files != 1 || result.Documents != 3 || result.Duplicates != 1 {
+		t.Errorf("Replay() = %+v, want 1 file, 3 documents, 1 duplicate", result)
+	}
+
+	if got := strings.Join(solarRepo.indexed["solarcell-2024.01.15"], ","); got != "a,b,c" {
+		t.Errorf("indexed %s, want a,b,c", got)
+	}
+
+	if names := fileNames(t, s); len(names) != 0 {
+		t.Errorf("Files() = %v, want none", names)
+	}
+}
+
+func TestSpoolReplayResumesAfterFailingFile(t *testing.T) {
+	s := newTestSpool(t, 0)
+	for _, index := range []string{"a-2024.01.15", "b-2024.01.15", "c-2024.01.15"} {
+		if err := s.Write(index, docs(index+"-1", index+"-2")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	solarRepo := newFakeSolarRepo()
+	solarRepo.index = func(index string, docs []interface{}) (model.BulkResult, error) {
+		switch index {
+		case "a-2024.01.15":
+			// The second document is left pending by a busy cluster.
+			return model.BulkResult{Indexed: 1, Pending: []int{1}}, errors.New("429: rejected")
+		case "b-2024.01.15":
+			// A failure leaving no document to send again.
+			return model.BulkResult{}, errors.New("marshal document")
+		}
+		return model.BulkResult{Indexed: len(docs)}, nil
+	}
+
+	result, err := s.Replay(solarRepo)
+	if err == nil {
+		t.Fatal("Replay() error = nil, want the errors of a and b")
+	}
+
+	if result.Files != 1 || result.Kept != 1 || result.Quarantined != 1 {
+		t.Errorf("Replay() = %+v, want 1 file replayed, 1 document kept, 1 file quarantined", result)
+	}
+
+	if got := strings.Join(solarRepo.indexed["c-2024.01.15"], ","); got != "c-2024.01.15-1,c-2024.01.15-2" {
+		t.Errorf("indexed %s of c, want both documents", got)
+	}
+
+	files, err := s.Files()
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+
+	kept, quarantined := "", ""
+	for _, file := range files {
+		switch {
+		case file.Index == "a-2024.01.15" && !file.Corrupt:
+			kept = file.Name
+		case file.Index == "b-2024.01.15" && file.Corrupt:
+			quarantined = file.Name
+		default:
+			t.Errorf("unexpected file %+v", file)
+		}
+	}
+
+	if kept == "" || quarantined == "" {
+		t.Fatalf("Files() = %+v, want the kept file of a and the quarantined file of b", files)
+	}
+
+	if ids := readIDs(t, s, filepath.Join(s.conf.Dir, kept)); len(ids) != 1 || ids[0] != "a-2024.01.15-2" {
+		t.Errorf("kept file holds %v, want the pending document only", ids)
+	}
+
+	// The next replay sends the kept document and leaves the quarantined file.
+	solarRepo.index = nil
+	result, err = s.Replay(solarRepo)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if result.Files != 1 || result.Documents != 1 {
+		t.Errorf("Replay() = %+v, want the kept file with 1 document", result)
+	}
+
+	if got := strings.Join(solarRepo.indexed["a-2024.01.15"], ","); got != "a-2024.01.15-1,a-2024.01.15-2" {
+		t.Errorf("indexed %s of a, want both documents", got)
+	}
+
+	if names := fileNames(t, s); len(names) != 1 || names[0] != quarantined {
+		t.Errorf("Files() = %v, want %s", names, quarantined)
+	}
+
+	if _, err := os.Stat(filepath.Join(s.conf.Dir, quarantined)); err != nil {
+		t.Errorf("quarantined file: %v", err)
+	}
+}
+
+func TestSpoolingSolarRepoSpoolsPendingDocuments(t *testing.T) {
+	s := newTestSpool(t, 0)
+	solarRepo := newFakeSolarRepo()
+	solarRepo.index = func(index string, docs []interface{}) (model.BulkResult, error) {
+		// a is indexed, b dead-lettered and c left pending.
+		return model.BulkResult{Indexed: 1, Failed: 1, DeadLettered: 1, Pending: []int{2}}, errors.New("503: unavailable")
+	}
+
+	_, err := NewSpoolingSolarRepo(solarRepo, s).BulkIndex("solarcell-2024.01.15", docs("a", "b", "c"))
+	if err == nil || !strings.Contains(err.Error(), "1 documents spooled") {
+		t.Fatalf("BulkIndex() error = %v, want 1 document spooled", err)
+	}
+
+	files, err := s.claim()
+	if err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("claim() = %v, want one file", files)
+	}
+
+	if ids := readIDs(t, s, files[0]); len(ids) != 1 || ids[0] != "c" {
+		t.Errorf("spool holds %v, want c", ids)
+	}
+}
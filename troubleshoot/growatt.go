@@ -34,12 +34,19 @@ func NewGrowattTroubleshoot(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRe
 }
 
 func (g *GrowattTroubleshoot) ExecuteByRange(
-	credential *model.GrowattCredential,
+	cred model.Credential,
 	start, end time.Time,
-) {
+) error {
+	credential, ok := cred.(*model.GrowattCredential)
+	if !ok {
+		return invalidCredentialError("GrowattTroubleshoot", cred)
+	}
+
 	for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
 		g.Execute(credential, date)
 	}
+
+	return nil
 }
 
 func (g *GrowattTroubleshoot) Execute(
@@ -79,12 +86,13 @@ DONE:
 	}
 
 	collectorIndex := fmt.Sprintf("%s-%s", model.SolarIndex, date.Format("2006.01.02"))
-	if err := g.solarRepo.BulkIndex(collectorIndex, documents); err != nil {
+	result, err := g.solarRepo.BulkIndex(collectorIndex, documents)
+	if err != nil {
 		g.logger.Error().Err(err).Msg("GrowattTroubleshoot::Execute() - failed to bulk index documents")
 		return
 	}
 
-	g.logger.Info().Int("count", len(documents)).Msg("GrowattTroubleshoot::Execute() - bulk index documents success")
+	g.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("GrowattTroubleshoot::Execute() - bulk index documents success")
 	g.logger.Info().Msg("GrowattTroubleshoot::Execute() - all goroutines finished")
 
 	close(docCh)
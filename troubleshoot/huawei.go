@@ -35,12 +35,19 @@ func NewHuaweiTroubleshoot(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteReg
 }
 
 func (k *HuaweiTroubleshoot) ExecuteByRange(
-	credential *model.HuaweiCredential,
+	cred model.Credential,
 	start, end time.Time,
-) {
+) error {
+	credential, ok := cred.(*model.HuaweiCredential)
+	if !ok {
+		return invalidCredentialError("HuaweiTroubleshoot", cred)
+	}
+
 	for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
 		k.Execute(credential, date)
 	}
+
+	return nil
 }
 
 func (h *HuaweiTroubleshoot) Execute(
@@ -79,12 +86,13 @@ DONE:
 	}
 
 	collectorIndex := fmt.Sprintf("%s-%s", model.SolarIndex, date.Format("2006.01.02"))
-	if err := h.solarRepo.BulkIndex(collectorIndex, documents); err != nil {
+	result, err := h.solarRepo.BulkIndex(collectorIndex, documents)
+	if err != nil {
 		h.logger.Error().Err(err).Msg("HuaweiTroubleshoot::Execute() - failed to bulk index documents")
 		return
 	}
 
-	h.logger.Info().Int("count", len(documents)).Msg("HuaweiTroubleshoot::Execute() - bulk index documents success")
+	h.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("HuaweiTroubleshoot::Execute() - bulk index documents success")
 	h.logger.Info().Msg("HuaweiTroubleshoot::Execute() - all goroutines finished")
 
 	close(docCh)
@@ -33,12 +33,19 @@ func NewKstarTroubleshoot(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteRegi
 }
 
 func (k *KstarTroubleshoot) ExecuteByRange(
-	credential *model.KstarCredential,
+	cred model.Credential,
 	start, end time.Time,
-) {
+) error {
+	credential, ok := cred.(*model.KstarCredential)
+	if !ok {
+		return invalidCredentialError("KstarTroubleshoot", cred)
+	}
+
 	for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
 		k.Execute(credential, date)
 	}
+
+	return nil
 }
 
 func (k *KstarTroubleshoot) Execute(
@@ -77,12 +84,13 @@ DONE:
 	}
 
 	collectorIndex := fmt.Sprintf("%s-%s", model.SolarIndex, date.Format("2006.01.02"))
-	if err := k.solarRepo.BulkIndex(collectorIndex, documents); err != nil {
+	result, err := k.solarRepo.BulkIndex(collectorIndex, documents)
+	if err != nil {
 		k.logger.Error().Err(err).Msg("KstarTroubleshoot::Execute() - failed to bulk index documents")
 		return
 	}
 
-	k.logger.Info().Int("count", len(documents)).Msg("KstarTroubleshoot::Execute() - bulk index documents success")
+	k.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("KstarTroubleshoot::Execute() - bulk index documents success")
 	k.logger.Info().Msg("KstarTroubleshoot::Execute() - all goroutines finished")
 
 	close(docCh)
@@ -35,12 +35,19 @@ func NewSolarmanTroubleshoot(solarRepo repo.SolarRepo, siteRegionRepo repo.SiteR
 }
 
 func (s *SolarmanTroubleshoot) ExecuteByRange(
-	credential *model.SolarmanCredential,
+	cred model.Credential,
 	start, end time.Time,
-) {
+) error {
+	credential, ok := cred.(*model.SolarmanCredential)
+	if !ok {
+		return invalidCredentialError("SolarmanTroubleshoot", cred)
+	}
+
 	for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
 		s.Execute(credential, date)
 	}
+
+	return nil
 }
 
 func (s *SolarmanTroubleshoot) Execute(
@@ -80,12 +87,13 @@ DONE:
 	}
 
 	collectorIndex := fmt.Sprintf("%s-%s", model.SolarIndex, date.Format("2006.01.02"))
-	if err := s.solarRepo.BulkIndex(collectorIndex, documents); err != nil {
+	result, err := s.solarRepo.BulkIndex(collectorIndex, documents)
+	if err != nil {
 		s.logger.Error().Err(err).Msg("SolarmanTroubleshoot::Execute() - failed to bulk index documents")
 		return
 	}
 
-	s.logger.Info().Int("count", len(documents)).Msg("SolarmanTroubleshoot::Execute() - bulk index documents success")
+	s.logger.Info().Int("count", result.Indexed).Int("failed", result.Failed).Msg("SolarmanTroubleshoot::Execute() - bulk index documents success")
 	s.logger.Info().Msg("SolarmanTroubleshoot::Execute() - all goroutines finished")
 
 	close(documentCh)
@@ -0,0 +1,18 @@
+package troubleshoot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HavvokLab/true-solar/model"
+)
+
+// Troubleshooter re-collects historical data of a single credential
+// day by day for the range [start, end).
+type Troubleshooter interface {
+	ExecuteByRange(credential model.Credential, start, end time.Time) error
+}
+
+func invalidCredentialError(troubleshooter string, credential model.Credential) error {
+	return fmt.Errorf("%s: unsupported credential type %T", troubleshooter, credential)
+}